@@ -0,0 +1,105 @@
+package main
+
+import (
+	"net/http"
+)
+
+// page access levels, set by the access key of a page's frontmatter
+// public pages can be seen by anyone, internal pages by signed in users and
+// private pages only by their owner and admins
+const (
+	accessPublic   = "public"
+	accessInternal = "internal"
+	accessPrivate  = "private"
+)
+
+// accessRanks orders the access levels from least to most restricted,
+// no access key meaning public
+var accessRanks = map[string]int{"": 0, accessPublic: 0, accessInternal: 1, accessPrivate: 2}
+
+// rank is how restricted the page is, an unknown access level counting as private
+func (m *pageMeta) rank() int {
+	if r, ok := accessRanks[m.Access]; ok {
+		return r
+	}
+	return accessRanks[accessPrivate]
+}
+
+// public reports whether anyone may see the page
+func (m *pageMeta) public() bool {
+	return m.rank() == accessRanks[accessPublic]
+}
+
+// owner is who a private page belongs to, its owner key or else its author
+func (m *pageMeta) owner() string {
+	if m.Owner != "" {
+		return m.Owner
+	}
+	return m.Author
+}
+
+// canView reports whether the requester behind r may see p,
+// admins may see every page
+func canView(r *http.Request, p *Page) bool {
+	switch p.Meta.rank() {
+	case accessRanks[accessPublic]:
+		return true
+	case accessRanks[accessInternal]:
+		return signedInUser(r) != "" || isAdmin(r)
+	}
+	if isAdmin(r) {
+		return true
+	}
+	user := signedInUser(r)
+	return user != "" && user == p.Meta.owner()
+}
+
+// denyView writes an error response and returns true if the requester behind r
+// may not see p, 403 Forbidden for internal pages and 404 Not Found for
+// private ones, whose existence is not given away
+func denyView(w http.ResponseWriter, r *http.Request, p *Page) bool {
+	if canView(r, p) {
+		return false
+	}
+	if p.Meta.rank() == accessRanks[accessInternal] {
+		errorHandler(w, r, http.StatusForbidden, "sign in to see "+p.Title)
+	} else {
+		notFound(w, r)
+	}
+	return true
+}
+
+// visiblePages returns the titles that the requester behind r may see
+// pages that cannot be loaded are left out
+func visiblePages(r *http.Request, titles []string) []string {
+	visible := titles[:0:0]
+	for _, title := range titles {
+		if p, err := loadPage(title); err == nil && canView(r, p) {
+			visible = append(visible, title)
+		}
+	}
+	return visible
+}
+
+// visibleChanges filters changes down to those of pages the requester
+// behind r may see
+func visibleChanges(r *http.Request, changes []pageChange) []pageChange {
+	visible := changes[:0:0]
+	for _, c := range changes {
+		if p, err := loadPage(c.Title); err == nil && canView(r, p) {
+			visible = append(visible, c)
+		}
+	}
+	return visible
+}
+
+// mayInclude reports whether a page with the metadata outer may include
+// one with the metadata inner without showing it to readers who could not
+// see it, inner being no more restricted than outer and private pages
+// only being included by pages of the same owner
+func mayInclude(outer, inner *pageMeta) bool {
+	if inner.rank() > outer.rank() {
+		return false
+	}
+	return inner.rank() < accessRanks[accessPrivate] || inner.owner() == outer.owner()
+}
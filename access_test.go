@@ -0,0 +1,98 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// requesters are the kinds of client access levels are checked against
+var requesters = []struct {
+	name string
+	as   func(*http.Request) *http.Request
+}{
+	{"anonymous", func(r *http.Request) *http.Request { return r }},
+	{"user", func(r *http.Request) *http.Request { return asUser(r, "alice") }},
+	{"owner", func(r *http.Request) *http.Request { return asUser(r, "bob") }},
+	{"admin", asAdmin},
+}
+
+// newAccessWiki is a wiki with sessions and an admin holding a public,
+// an internal and a private page, the last owned by bob
+func newAccessWiki(t *testing.T, flags ...string) http.Handler {
+	t.Helper()
+	h := newTestWiki(t, append([]string{"session-secret=secret", "admin-user=admin", "admin-pass=pass"}, flags...)...)
+	writePage(t, "Open", "For everyone")
+	writePage(t, "Team", "---\naccess: internal\n---\nFor the team")
+	writePage(t, "Diary", "---\naccess: private\nowner: bob\n---\nFor bob")
+	return h
+}
+
+// visibleTo lists the pages each requester of requesters may see, in order
+var visibleTo = map[string][]string{
+	"anonymous": {"Open"},
+	"user":      {"Open", "Team"},
+	"owner":     {"Diary", "Open", "Team"},
+	"admin":     {"Diary", "Open", "Team"},
+}
+
+func TestAccessLevels(t *testing.T) {
+	h := newAccessWiki(t)
+	want := map[string]map[string]int{
+		"Open":  {"anonymous": http.StatusOK, "user": http.StatusOK, "owner": http.StatusOK, "admin": http.StatusOK},
+		"Team":  {"anonymous": http.StatusForbidden, "user": http.StatusOK, "owner": http.StatusOK, "admin": http.StatusOK},
+		"Diary": {"anonymous": http.StatusNotFound, "user": http.StatusNotFound, "owner": http.StatusOK, "admin": http.StatusOK},
+	}
+	for title, codes := range want {
+		for _, req := range requesters {
+			for _, path := range []string{"/view/", "/api/meta/", "/history/"} {
+				if w := do(h, req.as(get(path+title))); w.Code != codes[req.name] {
+					t.Errorf("%s%s as %s: status %d, want %d", path, title, req.name, w.Code, codes[req.name])
+				}
+			}
+		}
+	}
+}
+
+func TestAccessLevelsInListings(t *testing.T) {
+	h := newAccessWiki(t)
+	for _, req := range requesters {
+		w := do(h, req.as(get("/api/recent")))
+		wantStatus(t, w, http.StatusOK)
+		var changes []pageChange
+		if err := json.NewDecoder(w.Body).Decode(&changes); err != nil {
+			t.Fatal(err)
+		}
+		var titles []string
+		for _, c := range changes {
+			titles = append(titles, c.Title)
+		}
+		sort.Strings(titles)
+		if got, want := strings.Join(titles, ","), strings.Join(visibleTo[req.name], ","); got != want {
+			t.Errorf("/api/recent as %s lists %s, want %s", req.name, got, want)
+		}
+	}
+}
+
+func TestStatsMostViewedIsPublic(t *testing.T) {
+	h := newAccessWiki(t, "stats=true", "count-views=true")
+	for _, title := range []string{"Open", "Team", "Diary"} {
+		do(h, asAdmin(get("/view/"+title)))
+	}
+	for _, req := range requesters {
+		statsCache.Lock()
+		statsCache.doc = nil
+		statsCache.Unlock()
+		w := do(h, req.as(get("/stats.json")))
+		wantStatus(t, w, http.StatusOK)
+		var st wikiStats
+		if err := json.NewDecoder(w.Body).Decode(&st); err != nil {
+			t.Fatal(err)
+		}
+		if len(st.MostViewed) != 1 || st.MostViewed[0].Title != "Open" {
+			t.Errorf("/stats.json as %s lists %v as most viewed, want only Open", req.name, st.MostViewed)
+		}
+	}
+}
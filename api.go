@@ -0,0 +1,213 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// outlinePath matches /api/outline/{Page.Title}
+var outlinePath = regexp.MustCompile("^/api/outline/([a-zA-Z0-9]+)$")
+
+// outlineNode is a heading in a page outline together with the
+// lower level headings of its section
+type outlineNode struct {
+	Level    int            `json:"level"`
+	Text     string         `json:"text"`
+	Anchor   string         `json:"anchor"`
+	Children []*outlineNode `json:"children"`
+}
+
+// outline nests the flat list of headings hs by level, a heading becoming a child
+// of the closest preceding heading with a lower level
+func outline(hs []heading) []*outlineNode {
+	roots := []*outlineNode{}
+	var stack []*outlineNode
+	for _, h := range hs {
+		n := &outlineNode{Level: h.Level, Text: h.Text, Anchor: h.ID, Children: []*outlineNode{}}
+		for len(stack) > 0 && stack[len(stack)-1].Level >= h.Level {
+			stack = stack[:len(stack)-1]
+		}
+		if len(stack) == 0 {
+			roots = append(roots, n)
+		} else {
+			parent := stack[len(stack)-1]
+			parent.Children = append(parent.Children, n)
+		}
+		stack = append(stack, n)
+	}
+	return roots
+}
+
+// outlineHandler returns the heading structure of a page as nested JSON,
+// with the same anchors as the page's table of contents
+func outlineHandler(w http.ResponseWriter, r *http.Request) {
+	m := outlinePath.FindStringSubmatch(r.URL.Path)
+	if m == nil {
+		notFound(w, r)
+		return
+	}
+	p, err := loadPage(m[1])
+	if err != nil {
+		notFound(w, r)
+		return
+	}
+	if denyView(w, r, p) {
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(outline(pageHeadings(p.Content)))
+}
+
+// metaPath matches /api/meta/{Page.Title}
+var metaPath = regexp.MustCompile("^/api/meta/([a-zA-Z0-9]+)$")
+
+// metaJSON is the frontmatter of a page as returned by /api/meta/,
+// keys the page does not set being left out
+type metaJSON struct {
+	Title    string   `json:"title,omitempty"`
+	Summary  string   `json:"summary,omitempty"`
+	Tags     []string `json:"tags,omitempty"`
+	Author   string   `json:"author,omitempty"`
+	Date     string   `json:"date,omitempty"`
+	Expires  string   `json:"expires,omitempty"`
+	Archived bool     `json:"archived,omitempty"`
+	Access   string   `json:"access,omitempty"`
+	Owner    string   `json:"owner,omitempty"`
+	Slug     string   `json:"slug,omitempty"`
+	Warning  string   `json:"warning,omitempty"`
+}
+
+// metaHandler returns the frontmatter of a page as JSON without its body,
+// an empty object for a page without frontmatter
+// responses carry Last-Modified and the page's Cache-Control, and a request
+// made with If-Modified-Since gets 304 Not Modified while the page is unchanged
+func metaHandler(w http.ResponseWriter, r *http.Request) {
+	m := metaPath.FindStringSubmatch(r.URL.Path)
+	if m == nil {
+		notFound(w, r)
+		return
+	}
+	p, err := loadPage(m[1])
+	if err != nil {
+		notFound(w, r)
+		return
+	}
+	if denyView(w, r, p) {
+		return
+	}
+	setPageCache(w, p)
+	if notModified(w, r, p.ModTime, "") {
+		return
+	}
+	meta := p.Meta
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(metaJSON{
+		Title:    meta.Title,
+		Summary:  meta.Summary,
+		Tags:     meta.Tags,
+		Author:   meta.Author,
+		Date:     meta.Date,
+		Expires:  meta.Expires,
+		Archived: meta.Archived,
+		Access:   meta.Access,
+		Owner:    meta.Owner,
+		Slug:     meta.Slug,
+		Warning:  p.Warning,
+	})
+}
+
+// pageChange is a page together with the time it was last modified
+// and the edit summary and editor of that change, and for listings its excerpt
+type pageChange struct {
+	Title    string    `json:"title"`
+	Modified time.Time `json:"modified"`
+	Summary  string    `json:"summary"`
+	Editor   string    `json:"editor"`
+	Excerpt  string    `json:"excerpt,omitempty"`
+}
+
+// recentChanges lists the pages modified after since, least recently modified first
+func recentChanges(since time.Time) ([]pageChange, error) {
+	titles, err := listPages()
+	if err != nil {
+		return nil, err
+	}
+	changes := []pageChange{}
+	for _, title := range titles {
+		fi, err := os.Stat(pageFile(title))
+		if err != nil {
+			continue
+		}
+		if !fi.ModTime().After(since) {
+			continue
+		}
+		c := pageChange{Title: title, Modified: fi.ModTime().UTC(), Editor: readEditor(title)}
+		if vs, err := versions(title); err == nil && len(vs) > 0 {
+			c.Summary = vs[len(vs)-1].Summary
+		}
+		changes = append(changes, c)
+	}
+	sort.SliceStable(changes, func(i, j int) bool { return changes[i].Modified.Before(changes[j].Modified) })
+	return changes, nil
+}
+
+// recentHandler returns the pages changed after ?since=, an RFC 3339 timestamp,
+// as JSON in the order they were changed, at most ?limit= of them
+// a client can poll with the modified time of the last page it saw
+// to fetch only what changed since then
+func recentHandler(w http.ResponseWriter, r *http.Request) {
+	var since time.Time
+	if v := r.URL.Query().Get("since"); v != "" {
+		t, err := time.Parse(time.RFC3339Nano, v)
+		if err != nil {
+			errorHandler(w, r, http.StatusBadRequest, "since must be an RFC 3339 timestamp")
+			return
+		}
+		since = t
+	}
+	limit := 0
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			errorHandler(w, r, http.StatusBadRequest, "limit must be a positive number")
+			return
+		}
+		limit = n
+	}
+
+	changes, err := recentChanges(since)
+	if err != nil {
+		errorHandler(w, r, http.StatusInternalServerError, err.Error())
+		return
+	}
+	changes = visibleChanges(r, changes)
+	if limit > 0 && len(changes) > limit {
+		changes = changes[:limit]
+	}
+	changes = withExcerpts(changes)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(changes)
+}
+
+// recentPageLimit is the number of changes shown on /recent
+const recentPageLimit = 50
+
+// recentPageHandler shows the most recently changed pages, newest first
+func recentPageHandler(w http.ResponseWriter, r *http.Request) {
+	changes, err := recentChanges(time.Time{})
+	if err != nil {
+		errorHandler(w, r, http.StatusInternalServerError, err.Error())
+		return
+	}
+	changes = visibleChanges(r, changes)
+	var newest []pageChange
+	for i := len(changes) - 1; i >= 0 && len(newest) < recentPageLimit; i-- {
+		newest = append(newest, changes[i])
+	}
+	renderTemplate(w, r, "recent", withExcerpts(newest))
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestMeta(t *testing.T) {
+	h := newTestWiki(t)
+	for _, tc := range []struct {
+		name, body, want string
+	}{
+		{"no frontmatter", "just text", "{}\n"},
+		{"empty frontmatter", "---\n---\ntext", "{}\n"},
+		{
+			"frontmatter",
+			"---\ntitle: Getting Started\nsummary: How to begin\ntags: [guide, intro]\nauthor: jane\ndate: 2024-01-31\narchived: true\n---\nThe content",
+			`{"title":"Getting Started","summary":"How to begin","tags":["guide","intro"],"author":"jane","date":"2024-01-31","archived":true}` + "\n",
+		},
+		{
+			"unparsed frontmatter",
+			"---\ntitle: Notes\ncolour: red\n---\ntext",
+			`{"title":"Notes","warning":"frontmatter could not be fully parsed: unknown key \"colour\""}` + "\n",
+		},
+	} {
+		writePage(t, "Notes", tc.body)
+		w := do(h, get("/api/meta/Notes"))
+		wantStatus(t, w, http.StatusOK)
+		if got := w.Header().Get("Content-Type"); got != "application/json" {
+			t.Errorf("%s: Content-Type %q", tc.name, got)
+		}
+		if got := w.Body.String(); got != tc.want {
+			t.Errorf("%s: meta %s, want %s", tc.name, got, tc.want)
+		}
+	}
+	wantStatus(t, do(h, get("/api/meta/Missing")), http.StatusNotFound)
+}
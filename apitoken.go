@@ -0,0 +1,118 @@
+package main
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// apiTokensFile lists the bearer tokens accepted on /api/, one per line
+// followed by the scopes it grants, "read", "write" or both, e.g.
+//
+//	# token                          scopes
+//	6f1c0d3a9b7e42d18c5a0e7f3b2d9c41 read
+//	b93e07a2c4d6f8e1a3c5e7092b4d6f8a read,write
+//
+// a token given without scopes may only read
+var apiTokensFile = flag.String("api-tokens", "", "file of bearer tokens and their scopes required on /api/ (empty leaves the API open)")
+
+// API token scopes
+const (
+	scopeRead  = "read"
+	scopeWrite = "write"
+)
+
+// apiTokens maps the SHA-256 digest of each accepted token to its scopes,
+// digests rather than tokens so that lookups do not leak timing information
+var apiTokens map[[sha256.Size]byte]map[string]bool
+
+// loadAPITokens reads -api-tokens into apiTokens
+func loadAPITokens() error {
+	f, err := os.Open(*apiTokensFile)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	tokens := map[[sha256.Size]byte]map[string]bool{}
+	s := bufio.NewScanner(f)
+	for n := 1; s.Scan(); n++ {
+		fields := strings.Fields(s.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		if len(fields) > 2 {
+			return fmt.Errorf("%s:%d: want a token and its scopes", *apiTokensFile, n)
+		}
+		scopes := map[string]bool{scopeRead: true}
+		if len(fields) == 2 {
+			scopes = map[string]bool{}
+			for _, scope := range strings.Split(fields[1], ",") {
+				if scope != scopeRead && scope != scopeWrite {
+					return fmt.Errorf("%s:%d: unknown scope %q", *apiTokensFile, n, scope)
+				}
+				scopes[scope] = true
+			}
+		}
+		tokens[sha256.Sum256([]byte(fields[0]))] = scopes
+	}
+	if err := s.Err(); err != nil {
+		return err
+	}
+	if len(tokens) == 0 {
+		return fmt.Errorf("%s: no tokens", *apiTokensFile)
+	}
+	apiTokens = tokens
+	return nil
+}
+
+// requireToken only lets requests with an "Authorization: Bearer" token
+// granting scope through to fn, when -api-tokens is set
+// a missing or unknown token gets 401 Unauthorized, a token lacking
+// the scope 403 Forbidden
+func requireToken(scope string, fn http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if apiTokens == nil {
+			fn(w, r)
+			return
+		}
+		auth := r.Header.Get("Authorization")
+		const prefix = "Bearer "
+		if len(auth) <= len(prefix) || !strings.EqualFold(auth[:len(prefix)], prefix) {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="gowiki api"`)
+			errorHandler(w, r, http.StatusUnauthorized, "missing bearer token")
+			return
+		}
+		scopes, ok := apiTokens[sha256.Sum256([]byte(strings.TrimSpace(auth[len(prefix):])))]
+		if !ok {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="gowiki api", error="invalid_token"`)
+			errorHandler(w, r, http.StatusUnauthorized, "invalid token")
+			return
+		}
+		if !scopes[scope] {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="gowiki api", error="insufficient_scope", scope="`+scope+`"`)
+			errorHandler(w, r, http.StatusForbidden, "token lacks the "+scope+" scope")
+			return
+		}
+		fn(w, r)
+	}
+}
+
+// requireTokenOrSession guards changes made through the API: with -api-tokens
+// set requests need a token granting scope, as with requireToken, and without
+// it they need a session, as with requireSession, so that the API is never a
+// way around logging in
+func requireTokenOrSession(scope string, fn http.HandlerFunc) http.HandlerFunc {
+	token, session := requireToken(scope, fn), requireSession(fn)
+	return func(w http.ResponseWriter, r *http.Request) {
+		if apiTokens != nil {
+			token(w, r)
+			return
+		}
+		session(w, r)
+	}
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"net/http"
+	"os"
+	"testing"
+)
+
+// useTokens loads tokens as the -api-tokens file
+func useTokens(t *testing.T, tokens string) {
+	t.Helper()
+	if err := os.WriteFile("tokens", []byte(tokens), 0600); err != nil {
+		t.Fatal(err)
+	}
+	setFlag(t, "api-tokens", "tokens")
+	if err := loadAPITokens(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAPITokens(t *testing.T) {
+	h := newTestWiki(t)
+	writePage(t, "Notes", "text")
+	useTokens(t, "# token scopes\nreader\nwriter read,write\nblind write\n")
+
+	for _, tc := range []struct {
+		name, auth, challenge string
+		want                  int
+	}{
+		{"missing", "", `Bearer realm="gowiki api"`, http.StatusUnauthorized},
+		{"not bearer", "Basic dXNlcjpwYXNz", `Bearer realm="gowiki api"`, http.StatusUnauthorized},
+		{"invalid", "Bearer nope", `Bearer realm="gowiki api", error="invalid_token"`, http.StatusUnauthorized},
+		{"insufficient scope", "Bearer blind", `Bearer realm="gowiki api", error="insufficient_scope", scope="read"`, http.StatusForbidden},
+		{"read scope", "Bearer reader", "", http.StatusOK},
+		{"read and write scopes", "bearer writer", "", http.StatusOK},
+	} {
+		r := get("/api/meta/Notes")
+		if tc.auth != "" {
+			r.Header.Set("Authorization", tc.auth)
+		}
+		w := do(h, r)
+		if w.Code != tc.want || w.Header().Get("WWW-Authenticate") != tc.challenge {
+			t.Errorf("%s: status %d with challenge %q, want %d with %q", tc.name, w.Code, w.Header().Get("WWW-Authenticate"), tc.want, tc.challenge)
+		}
+	}
+}
+
+func TestAPIOpenWithoutTokens(t *testing.T) {
+	h := newTestWiki(t)
+	writePage(t, "Notes", "text")
+	wantStatus(t, do(h, get("/api/meta/Notes")), http.StatusOK)
+}
+
+func TestLoadAPITokensErrors(t *testing.T) {
+	newTestWiki(t)
+	setFlag(t, "api-tokens", "tokens")
+	for _, tokens := range []string{"", "# only a comment\n", "token admin\n", "token read extra\n"} {
+		os.WriteFile("tokens", []byte(tokens), 0600)
+		if err := loadAPITokens(); err == nil {
+			t.Errorf("tokens file %q loaded without error", tokens)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"time"
+)
+
+// settings for the idle page archival job
+// pages are only archived when -archive-after-days is set
+var (
+	archiveAfterDays = flag.Int("archive-after-days", 0, "mark pages untouched for this many days as archived (0 disables archival)")
+	archiveInterval  = flag.Duration("archive-interval", time.Hour, "how often to scan for idle pages to archive")
+)
+
+// runArchiver periodically archives idle pages until ctx is done
+// it returns immediately when archival is disabled
+func runArchiver(ctx context.Context) {
+	if *archiveAfterDays <= 0 {
+		return
+	}
+	ticker := time.NewTicker(*archiveInterval)
+	defer ticker.Stop()
+	for {
+		if !isReadOnly() {
+			if err := archiveIdle(time.Now().AddDate(0, 0, -*archiveAfterDays)); err != nil {
+				log.Printf("archive: %v", err)
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// archiveIdle marks every page last modified before cutoff as archived
+// by setting "archived: true" in its frontmatter
+// archived pages stay viewable but are left out of the published index
+func archiveIdle(cutoff time.Time) error {
+	titles, err := listPages()
+	if err != nil {
+		return err
+	}
+	for _, title := range titles {
+		if err := archivePage(title, cutoff); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// archivePage archives title if it is idle, holding its lock so that
+// an edit saved concurrently is neither lost nor archived
+func archivePage(title string, cutoff time.Time) error {
+	unlock := lockPage(title)
+	defer unlock()
+	p, err := loadPage(title)
+	if err != nil {
+		return err
+	}
+	if p.Meta.Archived || !p.ModTime.Before(cutoff) {
+		return nil
+	}
+	p = newPage(title, setFrontmatter(p.Body, "archived", "true"))
+	if err := p.write(); err != nil {
+		return err
+	}
+	log.Printf("archived idle page %s", title)
+	return nil
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// auditLogPath is the append-only JSON lines file recording page changes
+var auditLogPath = flag.String("audit-log", "audit.log", "path of the append-only audit log of page changes")
+
+// audit actions recorded by the handlers
+const (
+	auditCreate  = "create"
+	auditEdit    = "edit"
+	auditReplace = "replace"
+)
+
+// auditEntry is a single line of the audit log
+type auditEntry struct {
+	Time   time.Time `json:"time"`
+	Action string    `json:"action"`
+	Title  string    `json:"title"`
+	Client string    `json:"client"`
+}
+
+// auditMu serializes writes to the audit log
+var auditMu sync.Mutex
+
+// recordAudit appends an entry for action on title by the client behind r
+// and sends out the change notification for it,
+// failures are logged rather than failing the request that caused them
+func recordAudit(r *http.Request, action, title string) {
+	notifyChange(r, action, title)
+	line, err := json.Marshal(auditEntry{Time: time.Now().UTC(), Action: action, Title: title, Client: clientID(r)})
+	if err != nil {
+		log.Printf("audit: %v", err)
+		return
+	}
+
+	auditMu.Lock()
+	defer auditMu.Unlock()
+	f, err := os.OpenFile(*auditLogPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		log.Printf("audit: %v", err)
+		return
+	}
+	defer f.Close()
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		log.Printf("audit: %v", err)
+	}
+}
+
+// clientID identifies who made a request, the signed in user if there is
+// one and the client's IP address otherwise
+func clientID(r *http.Request) string {
+	if user := signedInUser(r); user != "" {
+		return user
+	}
+	if ip := clientIP(r); ip != nil {
+		return ip.String()
+	}
+	return r.RemoteAddr
+}
+
+// tailAudit returns the most recent n entries of the audit log, oldest first
+func tailAudit(n int) ([]auditEntry, error) {
+	auditMu.Lock()
+	defer auditMu.Unlock()
+	f, err := os.Open(*auditLogPath)
+	if os.IsNotExist(err) {
+		return []auditEntry{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	entries := []auditEntry{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var e auditEntry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			continue
+		}
+		entries = append(entries, e)
+		if len(entries) > n {
+			entries = entries[1:]
+		}
+	}
+	return entries, scanner.Err()
+}
+
+// auditHandler reports the most recent audit log entries as JSON
+// via the url pattern: /admin/audit?n={count}, n defaults to 50
+func auditHandler(w http.ResponseWriter, r *http.Request) {
+	n := 50
+	if v := r.FormValue("n"); v != "" {
+		var err error
+		if n, err = strconv.Atoi(v); err != nil || n < 1 {
+			errorHandler(w, r, http.StatusBadRequest, "n must be a positive integer")
+			return
+		}
+	}
+	entries, err := tailAudit(n)
+	if err != nil {
+		errorHandler(w, r, http.StatusInternalServerError, err.Error())
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(entries)
+}
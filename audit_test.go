@@ -0,0 +1,36 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+)
+
+func TestClientID(t *testing.T) {
+	newTestWiki(t, "session-secret=secret", "admin-user=admin", "admin-pass=pass")
+	forged := get("/view/Notes")
+	forged.SetBasicAuth("admin", "guess")
+	for _, tc := range []struct {
+		name string
+		r    *http.Request
+		want string
+	}{
+		{"anonymous", get("/view/Notes"), "192.0.2.1"},
+		{"unverified Basic Auth", forged, "192.0.2.1"},
+		{"admin", asAdmin(get("/view/Notes")), "admin"},
+		{"session", asUser(get("/view/Notes"), "alice"), "alice"},
+	} {
+		if got := clientID(tc.r); got != tc.want {
+			t.Errorf("%s request identified as %q, want %q", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestAuditRecordsSignedInUser(t *testing.T) {
+	h := newTestWiki(t, "session-secret=secret")
+	wantStatus(t, do(h, asUser(postForm("/save/Notes", url.Values{"body": {"text"}}), "alice")), http.StatusFound)
+	entries, err := tailAudit(1)
+	if err != nil || len(entries) != 1 || entries[0].Client != "alice" {
+		t.Errorf("audit log %v, %v", entries, err)
+	}
+}
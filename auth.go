@@ -0,0 +1,43 @@
+package main
+
+import (
+	"flag"
+	"net/http"
+)
+
+// credentials for the /admin/ endpoints
+// admin endpoints are disabled unless both are set
+var (
+	adminUser = flag.String("admin-user", "", "user name for HTTP Basic Auth on /admin/ endpoints (empty disables them)")
+	adminPass = flag.String("admin-pass", "", "password for HTTP Basic Auth on /admin/ endpoints")
+)
+
+// isAdmin reports whether r carries the configured admin credentials,
+// or the session of the admin user when logging in is enabled
+func isAdmin(r *http.Request) bool {
+	if *adminUser == "" || *adminPass == "" {
+		return false
+	}
+	if user := sessionUser(r); user != "" {
+		return user == *adminUser
+	}
+	user, pass, ok := r.BasicAuth()
+	return ok && validLogin(user, pass)
+}
+
+// requireAdmin only lets requests carrying the admin credentials through to fn
+// if no admin credentials are configured, an HTTP Not Found error is returned
+func requireAdmin(fn http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if *adminUser == "" || *adminPass == "" {
+			notFound(w, r)
+			return
+		}
+		if !isAdmin(r) {
+			w.Header().Set("WWW-Authenticate", `Basic realm="gowiki admin", charset="UTF-8"`)
+			errorHandler(w, r, http.StatusUnauthorized, "unauthorized")
+			return
+		}
+		fn(w, r)
+	}
+}
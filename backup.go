@@ -0,0 +1,131 @@
+package main
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// backup settings
+var (
+	backupOnStart = flag.Bool("backup-on-start", false, "snapshot the data directory into -backup-dir at startup, before -migrate-pages or -help-pages change it")
+	backupDir     = flag.String("backup-dir", "backups", "directory holding the snapshots made by -backup-on-start")
+	backupKeep    = flag.Int("backup-keep", 7, "number of snapshots kept in -backup-dir, older ones are deleted (0 = keep all)")
+)
+
+// backupPrefix starts the name of every snapshot file, which is followed
+// by the time it was taken so that the names sort oldest first
+const backupPrefix = "gowiki-"
+
+// writeBackup writes a gzipped tarball of the data directory, pages,
+// history and all, to w
+// pages saved while it runs may or may not be included
+func writeBackup(w io.Writer) error {
+	zw := gzip.NewWriter(w)
+	tw := tar.NewWriter(zw)
+	err := filepath.Walk("data", func(path string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !fi.IsDir() && !fi.Mode().IsRegular() {
+			return nil
+		}
+		hdr, err := tar.FileInfoHeader(fi, "")
+		if err != nil {
+			return err
+		}
+		hdr.Name = filepath.ToSlash(path)
+		if fi.IsDir() {
+			hdr.Name += "/"
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if fi.IsDir() {
+			return nil
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		_, err = io.Copy(tw, f)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+// backupName is the file name of a snapshot taken at t
+func backupName(t time.Time) string {
+	return backupPrefix + t.UTC().Format("20060102-150405") + ".tar.gz"
+}
+
+// snapshot writes a backup of the data directory into -backup-dir,
+// then prunes it to the newest -backup-keep snapshots
+// it returns the path of the new snapshot
+func snapshot() (string, error) {
+	if err := os.MkdirAll(*backupDir, 0700); err != nil {
+		return "", err
+	}
+	path := filepath.Join(*backupDir, backupName(time.Now()))
+	tmp := path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return "", err
+	}
+	err = writeBackup(f)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp, path)
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return "", err
+	}
+	return path, pruneBackups(*backupKeep)
+}
+
+// pruneBackups deletes all but the newest keep snapshots in -backup-dir
+func pruneBackups(keep int) error {
+	if keep <= 0 {
+		return nil
+	}
+	names, err := filepath.Glob(filepath.Join(*backupDir, backupPrefix+"*.tar.gz"))
+	if err != nil {
+		return err
+	}
+	sort.Strings(names)
+	for len(names) > keep {
+		if err := os.Remove(names[0]); err != nil {
+			return err
+		}
+		names = names[1:]
+	}
+	return nil
+}
+
+// backupHandler downloads a gzipped tarball of the data directory
+func backupHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/gzip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", backupName(time.Now())))
+	if err := writeBackup(w); err != nil {
+		// the headers are gone by now, so the download is just cut short
+		log.Printf("backup: %v", err)
+	}
+}
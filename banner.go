@@ -0,0 +1,86 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// bannerPage is the page whose text is shown as the site-wide banner
+// unless a banner has been set through /admin/banner
+const bannerPage = "BannerNotice"
+
+// siteBanner is the announcement shown at the top of every page
+// ID identifies its text, so that dismissing one banner does not hide the next
+type siteBanner struct {
+	Text string
+	ID   string
+}
+
+// banner holds the banner set by an admin and the cached text of bannerPage
+var banner = struct {
+	sync.Mutex
+	admin    string
+	page     string
+	pageTime time.Time
+}{}
+
+// currentBanner returns the banner to show, nil if there is none
+// the text set by an admin wins over the text of bannerPage
+func currentBanner() *siteBanner {
+	banner.Lock()
+	defer banner.Unlock()
+	text := banner.admin
+	if text == "" {
+		text = bannerPageText()
+	}
+	if text == "" {
+		return nil
+	}
+	sum := sha256.Sum256([]byte(text))
+	return &siteBanner{Text: text, ID: hex.EncodeToString(sum[:6])}
+}
+
+// bannerPageText returns the text of bannerPage, rereading it only when it has
+// been saved since it was last read, banner must be locked
+func bannerPageText() string {
+	fi, err := os.Stat(pageFile(bannerPage))
+	if err != nil {
+		banner.page, banner.pageTime = "", time.Time{}
+		return ""
+	}
+	if !fi.ModTime().Equal(banner.pageTime) {
+		p, err := loadPage(bannerPage)
+		if err != nil {
+			return banner.page
+		}
+		banner.page = strings.Join(strings.Fields(string(p.Content)), " ")
+		banner.pageTime = fi.ModTime()
+	}
+	return banner.page
+}
+
+// bannerHandler sets the banner text with POST text=, an empty text clearing it,
+// and reports the banner currently shown as JSON
+func bannerHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodPost {
+		banner.Lock()
+		banner.admin = strings.Join(strings.Fields(r.FormValue("text")), " ")
+		banner.Unlock()
+		log.Printf("banner set by %s", clientID(r))
+	}
+	text := ""
+	if b := currentBanner(); b != nil {
+		text = b.Text
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(struct {
+		Banner string `json:"banner"`
+	}{text})
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// baseURL is the canonical address of the wiki, used for every absolute url
+// it generates rather than guessing it from each request's headers
+var baseURL = flag.String("base-url", "", "canonical external URL of the wiki used in absolute links, e.g. https://wiki.example.com (empty = inferred from each request)")
+
+// checkBaseURL validates -base-url, normalizing it to have no trailing slash
+func checkBaseURL() error {
+	if *baseURL == "" {
+		return nil
+	}
+	u, err := url.Parse(*baseURL)
+	if err != nil {
+		return err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" || u.Host == "" {
+		return errors.New("-base-url must be an absolute http or https URL")
+	}
+	if u.RawQuery != "" || u.Fragment != "" || u.User != nil {
+		return errors.New("-base-url must not have credentials, a query or a fragment")
+	}
+	*baseURL = strings.TrimRight(u.String(), "/")
+	return nil
+}
+
+// viaTrustedProxy reports whether r was passed on by one of -trusted-proxy
+func viaTrustedProxy(r *http.Request) bool {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && trustedProxies.contains(ip)
+}
+
+// requestOrigin is the scheme and host r was sent to, as seen by the client
+// X-Forwarded-Proto and X-Forwarded-Host are only believed from trusted proxies
+func requestOrigin(r *http.Request) string {
+	scheme, host := "http", r.Host
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	if viaTrustedProxy(r) {
+		if proto := r.Header.Get("X-Forwarded-Proto"); proto == "http" || proto == "https" {
+			scheme = proto
+		}
+		if fwd := r.Header.Get("X-Forwarded-Host"); fwd != "" {
+			host = strings.TrimSpace(strings.Split(fwd, ",")[0])
+		}
+	}
+	return scheme + "://" + host
+}
+
+// absoluteURL turns path, which starts with a slash, into an absolute url
+// under -base-url, or under the origin of r when that is not set
+func absoluteURL(r *http.Request, path string) string {
+	if *baseURL != "" {
+		return *baseURL + path
+	}
+	return requestOrigin(r) + path
+}
+
+// secureOrigin reports whether the wiki is reached over https by r's client
+func secureOrigin(r *http.Request) bool {
+	return strings.HasPrefix(absoluteURL(r, "/"), "https://")
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"flag"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// blog mode settings, which turn the front page into a list of posts
+var (
+	blogMode     = flag.Bool("blog", false, "show the newest posts on the front page instead of FrontPage")
+	blogTag      = flag.String("blog-tag", "post", "frontmatter tag that makes a page a blog post (empty for none)")
+	blogPrefix   = flag.String("blog-prefix", "", "title prefix that makes a page a blog post, e.g. Post (empty for none)")
+	blogExcerpt  = flag.Int("blog-excerpt", 300, "maximum length in characters of the excerpt shown for each post")
+	blogPageSize = flag.Int("blog-page-size", 10, "number of posts shown on each page of the blog")
+)
+
+// blogPost is a post listed on the blog front page
+type blogPost struct {
+	Title        string
+	DisplayTitle string
+	Modified     time.Time
+	Excerpt      string
+}
+
+// blogPage is the data for the blog template, one page of posts
+// Prev and Next are the neighbouring page numbers, 0 if there are none
+type blogPage struct {
+	Posts []blogPost
+	Page  int
+	Prev  int
+	Next  int
+}
+
+// isPost reports whether p is a blog post, being tagged -blog-tag
+// or having a title starting with -blog-prefix, ignoring case
+func isPost(p *Page) bool {
+	if *blogPrefix != "" && strings.HasPrefix(strings.ToLower(p.Title), strings.ToLower(*blogPrefix)) {
+		return true
+	}
+	if *blogTag != "" {
+		for _, tag := range p.Meta.Tags {
+			if strings.EqualFold(tag, *blogTag) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// blogPosts lists every post the requester behind r may see,
+// most recently modified first
+func blogPosts(r *http.Request) ([]blogPost, error) {
+	changes, err := recentChanges(time.Time{})
+	if err != nil {
+		return nil, err
+	}
+	var posts []blogPost
+	for i := len(changes) - 1; i >= 0; i-- {
+		p, err := loadPage(changes[i].Title)
+		if err != nil || !isPost(p) || p.Meta.Archived || p.Meta.expired(time.Now()) || !canView(r, p) {
+			continue
+		}
+		posts = append(posts, blogPost{
+			Title:        p.Title,
+			DisplayTitle: p.DisplayTitle(),
+			Modified:     changes[i].Modified,
+			Excerpt:      pageExcerpt(p, *blogExcerpt),
+		})
+	}
+	return posts, nil
+}
+
+// blogHandler shows one page of posts, newest first, ?page= choosing which
+func blogHandler(w http.ResponseWriter, r *http.Request) {
+	n := 1
+	if v := r.FormValue("page"); v != "" {
+		var err error
+		if n, err = strconv.Atoi(v); err != nil || n < 1 {
+			errorHandler(w, r, http.StatusBadRequest, "page must be a positive number")
+			return
+		}
+	}
+	posts, err := blogPosts(r)
+	if err != nil {
+		errorHandler(w, r, http.StatusInternalServerError, err.Error())
+		return
+	}
+	size := *blogPageSize
+	if size < 1 {
+		size = 1
+	}
+	start := (n - 1) * size
+	if start > 0 && start >= len(posts) {
+		notFound(w, r)
+		return
+	}
+	end := start + size
+	if end > len(posts) {
+		end = len(posts)
+	}
+	b := &blogPage{Posts: posts[start:end], Page: n}
+	if n > 1 {
+		b.Prev = n - 1
+	}
+	if end < len(posts) {
+		b.Next = n + 1
+	}
+	renderTemplate(w, r, "blog", b)
+}
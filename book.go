@@ -0,0 +1,205 @@
+package main
+
+import (
+	"bytes"
+	"html/template"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// bookPath matches /book and /book/{Page.Title}, the latter being a page
+// that lists the pages of the book with a wikilink each, in order
+var bookPath = regexp.MustCompile("^/book(?:/([a-zA-Z0-9]+))?$")
+
+// maxBookPages caps how many pages a book may be made of
+const maxBookPages = 200
+
+// bookChapter is one page of a book rendered for inclusion in it
+type bookChapter struct {
+	*Page
+	ID   string
+	HTML template.HTML
+}
+
+// book is the data for the book template
+// its table of contents lists the chapters at level 1 with their headings below
+type book struct {
+	Title    string
+	Chapters []bookChapter
+	TOC      []heading
+}
+
+// bookChapterID is the anchor of the chapter made of the page title
+func bookChapterID(title string) string {
+	return "chapter-" + title
+}
+
+// bookPrefix is the prefix of the heading and footnote ids of the chapter
+// made of the page title, keeping those of different chapters apart
+func bookPrefix(title string) string {
+	return title + "-"
+}
+
+// newBook renders pages, in order, as the chapters of a book named title
+// each chapter starts with the page's title as a level 1 heading, the page's
+// own headings moving a level down, and wikilinks between pages of the book
+// point within it
+func newBook(title string, pages []*Page) *book {
+	b := &book{Title: title}
+	prefixes := map[string]string{}
+	for _, p := range pages {
+		prefixes[p.Title] = bookPrefix(p.Title)
+	}
+	for _, p := range pages {
+		rd := newRenderer(p.Title, bookPrefix(p.Title))
+		rd.book, rd.shift = prefixes, 1
+		c := bookChapter{Page: p, ID: bookChapterID(p.Title), HTML: rd.render(p.Content)}
+		b.Chapters = append(b.Chapters, c)
+		b.TOC = append(b.TOC, heading{Level: 1, Text: p.DisplayTitle(), ID: c.ID})
+		for _, h := range pageHeadings(p.Content) {
+			if h.Level++; h.Level > 6 {
+				h.Level = 6
+			}
+			h.ID = bookPrefix(p.Title) + h.ID
+			b.TOC = append(b.TOC, h)
+		}
+	}
+	return b
+}
+
+// linkedTitles lists the pages body links to in the order they are first
+// linked, the contents of a book definition page
+func linkedTitles(body []byte) []string {
+	var titles []string
+	seen := map[string]bool{}
+	for _, line := range proseLines(body) {
+		line = markdownLink.ReplaceAllString(codeSpan.ReplaceAllString(line, ""), "")
+		for _, m := range wikiLink.FindAllStringSubmatch(line, -1) {
+			if m[1] != "" && !seen[m[1]] {
+				seen[m[1]] = true
+				titles = append(titles, m[1])
+			}
+		}
+	}
+	return titles
+}
+
+// taggedPages lists the pages tagged tag, ignoring case, sorted by title
+func taggedPages(tag string) ([]string, error) {
+	all, err := listPages()
+	if err != nil {
+		return nil, err
+	}
+	var titles []string
+	for _, title := range all {
+		p, err := loadPage(title)
+		if err != nil {
+			continue
+		}
+		for _, t := range p.Meta.Tags {
+			if strings.EqualFold(t, tag) {
+				titles = append(titles, title)
+				break
+			}
+		}
+	}
+	return titles, nil
+}
+
+// bookHandler serves several pages stitched together into a single standalone
+// HTML document with a combined table of contents, for printing or saving
+// the pages are those listed in order by ?pages=A,B,C, those starting with
+// ?prefix=, those tagged ?tag=, or those linked in order from the page
+// given as /book/{Page.Title}, which also names the book
+// with ?async=1 the book is rendered as a background job, see startJob
+func bookHandler(w http.ResponseWriter, r *http.Request) {
+	m := bookPath.FindStringSubmatch(r.URL.Path)
+	if m == nil {
+		notFound(w, r)
+		return
+	}
+	name := "Book"
+	var titles []string
+	var err error
+	switch {
+	case m[1] != "":
+		def, err := loadPage(m[1])
+		if err != nil {
+			notFound(w, r)
+			return
+		}
+		if denyView(w, r, def) {
+			return
+		}
+		name, titles = def.DisplayTitle(), linkedTitles(def.Content)
+	case r.FormValue("pages") != "":
+		titles = strings.FieldsFunc(r.FormValue("pages"), func(c rune) bool { return c == ',' || c == ' ' })
+	case r.FormValue("prefix") != "":
+		if titles, err = pagesWithPrefix(r.FormValue("prefix")); err == nil {
+			titles = visiblePages(r, titles)
+		}
+	case r.FormValue("tag") != "":
+		if titles, err = taggedPages(r.FormValue("tag")); err == nil {
+			titles = visiblePages(r, titles)
+		}
+	default:
+		errorHandler(w, r, http.StatusBadRequest, "choose the pages of the book with ?pages=, ?prefix= or ?tag=, or a page listing them as /book/{title}")
+		return
+	}
+	if err != nil {
+		errorHandler(w, r, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if len(titles) > maxBookPages {
+		errorHandler(w, r, http.StatusBadRequest, "a book may be made of at most "+strconv.Itoa(maxBookPages)+" pages")
+		return
+	}
+
+	var pages []*Page
+	seen := map[string]bool{}
+	now := time.Now()
+	for _, title := range titles {
+		if seen[title] {
+			continue
+		}
+		seen[title] = true
+		if err := validateTitle(title); err != nil {
+			errorHandler(w, r, http.StatusBadRequest, err.Error())
+			return
+		}
+		p, err := loadPage(title)
+		if err != nil {
+			errorHandler(w, r, http.StatusNotFound, "page "+title+" does not exist")
+			return
+		}
+		if denyView(w, r, p) {
+			return
+		}
+		if !p.Meta.expired(now) {
+			pages = append(pages, p)
+		}
+	}
+	if len(pages) == 0 {
+		errorHandler(w, r, http.StatusNotFound, "the book has no pages")
+		return
+	}
+	if r.FormValue("async") == "" {
+		renderTemplate(w, r, "book", newBook(name, pages))
+		return
+	}
+	t := requestTemplate(w, r, "book.html")
+	if t == nil {
+		errorHandler(w, r, http.StatusInternalServerError, "template book not found")
+		return
+	}
+	startJob(w, r, func() (jobResult, error) {
+		var buf bytes.Buffer
+		if err := t.Execute(&buf, newBook(name, pages)); err != nil {
+			return jobResult{}, err
+		}
+		return jobResult{contentType: "text/html; charset=utf-8", body: buf.Bytes()}, nil
+	})
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestBookPages(t *testing.T) {
+	h := newTestWiki(t)
+	writePage(t, "A", "first chapter")
+	writePage(t, "B", "second chapter")
+	w := do(h, get("/book?pages=A,B"))
+	wantStatus(t, w, http.StatusOK)
+	body := w.Body.String()
+	if i, j := strings.Index(body, "first chapter"), strings.Index(body, "second chapter"); i < 0 || j < i {
+		t.Errorf("book does not hold A then B:\n%s", body)
+	}
+	wantStatus(t, do(h, get("/book?pages=A,Missing")), http.StatusNotFound)
+}
+
+func TestBookRejectsPathTitles(t *testing.T) {
+	h := newTestWiki(t)
+	writePage(t, "A", "first chapter")
+	if err := os.WriteFile("secret.txt", []byte("the secret"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	for _, pages := range []string{"A,../secret", "../secret", "A,data/../../secret"} {
+		w := do(h, get("/book?pages="+url.QueryEscape(pages)))
+		wantStatus(t, w, http.StatusBadRequest)
+		if strings.Contains(w.Body.String(), "the secret") {
+			t.Errorf("?pages=%s shows a file outside data/", pages)
+		}
+	}
+}
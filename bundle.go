@@ -0,0 +1,84 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+)
+
+// bundleSources lists the static files concatenated into each bundle, keyed by
+// the bundle's extension, in the order they are concatenated
+// only stylesheets and scripts that are harmless on pages without the elements
+// they style or hook into belong here, scripts configured through their own
+// <script> tag such as tasks.js must keep being served on their own
+var bundleSources = map[string][]string{
+	"css": {"headings.css", "callouts.css", "preview.css"},
+	"js":  {"headings.js", "editor.js", "paste.js", "preview.js"},
+}
+
+// assetBundle is the concatenated content of a bundle and its fingerprint
+type assetBundle struct {
+	content []byte
+	hash    string
+	modTime time.Time
+}
+
+// bundles holds the bundles built by loadBundles, keyed by extension
+var bundles = map[string]*assetBundle{}
+
+// loadBundles concatenates the sources of every bundle, fingerprinting each one
+// by its content so that its url changes whenever one of its sources does
+func loadBundles() error {
+	built := map[string]*assetBundle{}
+	for ext, names := range bundleSources {
+		var buf bytes.Buffer
+		for _, name := range names {
+			data, err := ioutil.ReadFile(path.Join("static", name))
+			if err != nil {
+				return err
+			}
+			buf.Write(data)
+			buf.WriteString("\n")
+		}
+		sum := sha256.Sum256(buf.Bytes())
+		built[ext] = &assetBundle{content: buf.Bytes(), hash: hex.EncodeToString(sum[:6]), modTime: time.Now()}
+	}
+	bundles = built
+	return nil
+}
+
+// bundleURL is the fingerprinted url of the bundle with extension ext, used
+// in templates as {{bundle "css"}}, which serves it as /static/bundle.{hash}.css
+func bundleURL(ext string) string {
+	b, ok := bundles[ext]
+	if !ok {
+		return ""
+	}
+	return "/static/bundle." + b.hash + "." + ext
+}
+
+// serveBundle serves the static file name if it is a bundle, reporting whether it was
+// the current fingerprint is cacheable for good, an outdated one still gets the
+// current bundle so that pages cached with the old url keep working
+func serveBundle(w http.ResponseWriter, r *http.Request, name string) bool {
+	parts := strings.Split(name, ".")
+	if len(parts) != 3 || parts[0] != "bundle" {
+		return false
+	}
+	b, ok := bundles[parts[2]]
+	if !ok {
+		return false
+	}
+	if parts[1] == b.hash {
+		w.Header().Set("Cache-Control", cacheControl(immutableMaxAge)+", immutable")
+	} else {
+		w.Header().Set("Cache-Control", cacheControl(*staticMaxAge))
+	}
+	http.ServeContent(w, r, name, b.modTime, bytes.NewReader(b.content))
+	return true
+}
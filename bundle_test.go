@@ -0,0 +1,82 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeBundleSources replaces the static files of the bundles with
+// "/* name */" in the directory of the current test wiki
+func writeBundleSources(t *testing.T) {
+	t.Helper()
+	if err := os.Remove("static"); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir("static", 0700); err != nil {
+		t.Fatal(err)
+	}
+	for _, names := range bundleSources {
+		for _, name := range names {
+			if err := os.WriteFile(filepath.Join("static", name), []byte("/* "+name+" */"), 0600); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+}
+
+func TestBundleURL(t *testing.T) {
+	h := newTestWiki(t)
+	saved := bundles
+	t.Cleanup(func() { bundles = saved })
+	writeBundleSources(t)
+	if err := loadBundles(); err != nil {
+		t.Fatal(err)
+	}
+
+	var content strings.Builder
+	for _, name := range bundleSources["css"] {
+		content.WriteString("/* " + name + " */\n")
+	}
+	sum := sha256.Sum256([]byte(content.String()))
+	old := bundleURL("css")
+	if want := "/static/bundle." + hex.EncodeToString(sum[:6]) + ".css"; old != want {
+		t.Fatalf("bundle url %q, want %q", old, want)
+	}
+	w := do(h, get(old))
+	wantStatus(t, w, http.StatusOK)
+	if got := w.Body.String(); got != content.String() {
+		t.Errorf("bundle is %q, want %q", got, content.String())
+	}
+	if got := w.Header().Get("Cache-Control"); !strings.Contains(got, "immutable") {
+		t.Errorf("current bundle served with Cache-Control %q", got)
+	}
+
+	if err := os.WriteFile(filepath.Join("static", bundleSources["css"][0]), []byte("h1 { color: red }"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := loadBundles(); err != nil {
+		t.Fatal(err)
+	}
+	current := bundleURL("css")
+	if current == old {
+		t.Fatalf("bundle url %q did not change with its content", current)
+	}
+
+	// pages cached with the old url get the current bundle, but not for good
+	w = do(h, get(old))
+	wantStatus(t, w, http.StatusOK)
+	if got := w.Body.String(); !strings.HasPrefix(got, "h1 { color: red }\n") {
+		t.Errorf("old url served %q", got)
+	}
+	if got := w.Header().Get("Cache-Control"); strings.Contains(got, "immutable") {
+		t.Errorf("outdated bundle url served with Cache-Control %q", got)
+	}
+	if other := bundleURL("js"); !strings.HasPrefix(other, "/static/bundle.") || !strings.HasSuffix(other, ".js") {
+		t.Errorf("js bundle url %q", other)
+	}
+}
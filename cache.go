@@ -0,0 +1,124 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// cache lifetimes given to browsers and proxies
+var (
+	pageMaxAge   = flag.Duration("page-max-age", 0, "how long page views may be cached before revalidating, pages change whenever they are saved (0 = always revalidate)")
+	staticMaxAge = flag.Duration("static-max-age", time.Hour, "how long static files requested without a fingerprint may be cached")
+)
+
+// immutableMaxAge is the lifetime of fingerprinted static files,
+// whose content never changes for a given url
+const immutableMaxAge = 365 * 24 * time.Hour
+
+// cacheControl is the Cache-Control header value for a lifetime of d
+func cacheControl(d time.Duration) string {
+	if d <= 0 {
+		return "no-cache"
+	}
+	return "max-age=" + strconv.Itoa(int(d.Seconds()))
+}
+
+// setPageCache sets the Cache-Control header for a response showing p, which is
+// still revalidated against Last-Modified once it expires
+// pages that are not public may only be cached by the browser
+func setPageCache(w http.ResponseWriter, p *Page) {
+	cc := cacheControl(*pageMaxAge)
+	if !p.Meta.public() {
+		cc = "private, " + cc
+	}
+	w.Header().Set("Cache-Control", cc)
+}
+
+// viewETag is the validator of the view of p as served to r, which besides
+// the page itself depends on the theme and locale, who is signed in and the
+// backlinks they may see, the banner, and which of the linked pages exist
+// and where the links to them point
+func viewETag(r *http.Request, p *Page) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%d\n%s\n%s\n%s\n%t\n", p.ModTime.UnixNano(), pickedTheme(r), requestLocale(r), signedInUser(r), isAdmin(r))
+	if b := currentBanner(); b != nil {
+		fmt.Fprintln(h, b.ID)
+	}
+	fmt.Fprintln(h, strings.Join(visiblePages(r, backlinks(p.Title)), ","))
+	for _, l := range pageLinks(p.Content) {
+		fmt.Fprintln(h, l.Title, l.Exists, l.Href(p.Title))
+	}
+	return `"` + hex.EncodeToString(h.Sum(nil)[:12]) + `"`
+}
+
+// etagMatches reports whether the If-None-Match header value list holds etag,
+// comparing weakly as GET requests may
+func etagMatches(list, etag string) bool {
+	for _, tag := range strings.Split(list, ",") {
+		tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+		if tag == "*" || tag == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// staticHashes caches the content hash of each static file by name
+var staticHashes = struct {
+	sync.Mutex
+	sums map[string]string
+}{sums: map[string]string{}}
+
+// staticHash returns a short hash of the content of static/name,
+// or "" if the file cannot be read
+func staticHash(name string) string {
+	staticHashes.Lock()
+	defer staticHashes.Unlock()
+	if sum, ok := staticHashes.sums[name]; ok {
+		return sum
+	}
+	data, err := ioutil.ReadFile(path.Join("static", path.Clean("/"+name)))
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(data)
+	staticHashes.sums[name] = hex.EncodeToString(sum[:6])
+	return staticHashes.sums[name]
+}
+
+// staticURL is the fingerprinted url of static/name, used in templates as
+// {{static "editor.js"}} so that it can be cached for good
+func staticURL(name string) string {
+	if sum := staticHash(name); sum != "" {
+		return "/static/" + name + "?v=" + sum
+	}
+	return "/static/" + name
+}
+
+// staticHandler serves the files in static/ under /static/, forever cacheable
+// when requested with the fingerprint of their current content and for
+// -static-max-age otherwise, along with the bundles of bundle.go
+func staticHandler() http.Handler {
+	files := http.StripPrefix("/static/", http.FileServer(http.Dir("static")))
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := r.URL.Path[len("/static/"):]
+		if serveBundle(w, r, name) {
+			return
+		}
+		if v := r.URL.Query().Get("v"); v != "" && v == staticHash(name) {
+			w.Header().Set("Cache-Control", cacheControl(immutableMaxAge)+", immutable")
+		} else {
+			w.Header().Set("Cache-Control", cacheControl(*staticMaxAge))
+		}
+		files.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+)
+
+// revalidate is a GET of target carrying the validators etag and modified
+// of an earlier response, each left out if it is ""
+func revalidate(target, etag, modified string) *http.Request {
+	r := get(target)
+	if etag != "" {
+		r.Header.Set("If-None-Match", etag)
+	}
+	if modified != "" {
+		r.Header.Set("If-Modified-Since", modified)
+	}
+	return r
+}
+
+func TestViewNotModified(t *testing.T) {
+	h := newTestWiki(t, "session-secret=secret", "admin-user=admin", "admin-pass=pass")
+	writePage(t, "Notes", "see [Other]")
+	w := do(h, get("/view/Notes"))
+	wantStatus(t, w, http.StatusOK)
+	etag, modified := w.Header().Get("ETag"), w.Header().Get("Last-Modified")
+	if etag == "" || modified == "" || w.Header().Get("Vary") == "" {
+		t.Fatalf("view headers %v", w.Header())
+	}
+
+	wantStatus(t, do(h, revalidate("/view/Notes", etag, modified)), http.StatusNotModified)
+	wantStatus(t, do(h, revalidate("/view/Notes", "W/"+etag, "")), http.StatusNotModified)
+	wantStatus(t, do(h, revalidate("/view/Notes", `"other", `+etag, "")), http.StatusNotModified)
+	wantStatus(t, do(h, revalidate("/view/Notes", "", modified)), http.StatusOK)
+
+	themed := revalidate("/view/Notes", etag, modified)
+	themed.AddCookie(&http.Cookie{Name: themeCookie, Value: "modern"})
+	wantStatus(t, do(h, themed), http.StatusOK)
+	wantStatus(t, do(h, asUser(revalidate("/view/Notes", etag, modified), "alice")), http.StatusOK)
+	wantStatus(t, do(h, asAdmin(revalidate("/view/Notes", etag, modified))), http.StatusOK)
+
+	writePage(t, "Other", "now it exists")
+	wantStatus(t, do(h, revalidate("/view/Notes", etag, modified)), http.StatusOK)
+	etag = do(h, get("/view/Notes")).Header().Get("ETag")
+	writePage(t, "Linking", "back to [Notes]")
+	wantStatus(t, do(h, revalidate("/view/Notes", etag, modified)), http.StatusOK)
+	etag = do(h, get("/view/Notes")).Header().Get("ETag")
+	wantStatus(t, do(h, asAdmin(postForm("/admin/banner", url.Values{"text": {"down at noon"}}))), http.StatusOK)
+	t.Cleanup(func() { banner.admin = "" })
+	wantStatus(t, do(h, revalidate("/view/Notes", etag, modified)), http.StatusOK)
+	etag = do(h, get("/view/Notes")).Header().Get("ETag")
+	wantStatus(t, do(h, revalidate("/view/Notes", etag, modified)), http.StatusNotModified)
+}
+
+func TestViewNotModifiedCountingViews(t *testing.T) {
+	h := newTestWiki(t, "count-views=true")
+	writePage(t, "Notes", "text")
+	w := do(h, get("/view/Notes"))
+	wantStatus(t, do(h, revalidate("/view/Notes", w.Header().Get("ETag"), w.Header().Get("Last-Modified"))), http.StatusOK)
+}
+
+func TestIfModifiedSince(t *testing.T) {
+	h := newTestWiki(t)
+	writePage(t, "Notes", "text")
+	p, _ := loadPage("Notes")
+	past := p.ModTime.Add(-time.Hour).UTC().Format(http.TimeFormat)
+	future := p.ModTime.Add(time.Hour).UTC().Format(http.TimeFormat)
+	wantStatus(t, do(h, revalidate("/api/meta/Notes", "", past)), http.StatusOK)
+	wantStatus(t, do(h, revalidate("/api/meta/Notes", "", future)), http.StatusNotModified)
+	wantStatus(t, do(h, revalidate("/api/meta/Notes", "", "not a date")), http.StatusOK)
+}
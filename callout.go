@@ -0,0 +1,69 @@
+package main
+
+import (
+	"html/template"
+	"regexp"
+	"strings"
+)
+
+// calloutMarker matches the first line of a callout, "> [!NOTE]" in a
+// blockquote, optionally followed by a title to use instead of the type's
+var calloutMarker = regexp.MustCompile(`^\[!([A-Za-z]+)\]\s*(.*)$`)
+
+// calloutTypes are the callout types with their own style, keyed by
+// their lower case name, other types are shown as a plain callout
+var calloutTypes = map[string]string{
+	"note":      "Note",
+	"tip":       "Tip",
+	"important": "Important",
+	"warning":   "Warning",
+	"caution":   "Caution",
+	"danger":    "Danger",
+}
+
+// quoteLine reports whether line belongs to a blockquote, returning its text
+// with the "> " marker removed
+func quoteLine(line string) (string, bool) {
+	if line == ">" {
+		return "", true
+	}
+	if strings.HasPrefix(line, "> ") {
+		return line[2:], true
+	}
+	return "", false
+}
+
+// quote renders the lines of a blockquote, whose paragraphs are separated by
+// empty quote lines, as a <blockquote>, or as a callout box when its first
+// line is a callout marker such as [!WARNING]
+func (rd *renderer) quote(lines []string) {
+	open, close := "<blockquote>\n", "</blockquote>\n"
+	if m := calloutMarker.FindStringSubmatch(strings.TrimSpace(lines[0])); m != nil {
+		kind := strings.ToLower(m[1])
+		label, ok := calloutTypes[kind]
+		if !ok {
+			label = strings.ToUpper(kind[:1]) + kind[1:]
+			kind = "generic"
+		}
+		title := template.HTMLEscapeString(label)
+		if m[2] != "" {
+			title = rd.inline(m[2])
+		}
+		open = `<div class="callout callout-` + kind + `" role="note">` + "\n" + `<p class="callout-title">` + title + "</p>\n"
+		close = "</div>\n"
+		lines = lines[1:]
+	}
+	rd.out.WriteString(open)
+	var para []string
+	for _, line := range append(lines, "") {
+		if strings.TrimSpace(line) != "" {
+			para = append(para, line)
+			continue
+		}
+		if len(para) > 0 {
+			rd.out.WriteString("<p>" + rd.inline(strings.Join(para, "\n")) + "</p>\n")
+			para = nil
+		}
+	}
+	rd.out.WriteString(close)
+}
@@ -0,0 +1,275 @@
+package main
+
+import (
+	"flag"
+	"html"
+	"io/ioutil"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// noPasteMarkdown turns off converting rich text pasted into the edit form
+var noPasteMarkdown = flag.Bool("no-paste-markdown", false, "paste rich text into the edit form as it is instead of converting its HTML to Markdown")
+
+// patterns used to convert an HTML fragment to Markdown
+var (
+	htmlDropped = regexp.MustCompile(`(?is)<(script|style|head|template|svg)\b[^>]*>.*?</(?:script|style|head|template|svg)>|<!--.*?-->`)
+	htmlToken   = regexp.MustCompile(`(?s)<(/?)([a-zA-Z][a-zA-Z0-9]*)([^<>]*)>`)
+	htmlHref    = regexp.MustCompile(`(?is)\bhref\s*=\s*(?:"([^"]*)"|'([^']*)'|([^\s"'>]+))`)
+	htmlAlt     = regexp.MustCompile(`(?is)\balt\s*=\s*(?:"([^"]*)"|'([^']*)'|([^\s"'>]+))`)
+	htmlNormal  = regexp.MustCompile(`(?i)font-weight\s*:\s*(?:normal|400)`)
+	blankLines  = regexp.MustCompile(`\n{3,}`)
+)
+
+// attr returns the value of the attribute matched by re in the attributes of a tag
+func attr(re *regexp.Regexp, attrs string) string {
+	m := re.FindStringSubmatch(attrs)
+	if m == nil {
+		return ""
+	}
+	return html.UnescapeString(m[1] + m[2] + m[3])
+}
+
+// openList is a list being converted, with the number of its last item
+type openList struct {
+	ordered bool
+	n       int
+}
+
+// openLink is a link being converted, start is where its text begins in the output
+type openLink struct {
+	href  string
+	start int
+}
+
+// markdownWriter builds Markdown from the tags and text of an HTML fragment
+type markdownWriter struct {
+	out   []byte
+	lists []openList
+	links []openLink
+	bold  []bool // whether each open <b> is shown as bold
+	pre   int
+}
+
+// trim drops spaces at the end of the output
+func (mw *markdownWriter) trim() {
+	for len(mw.out) > 0 && mw.out[len(mw.out)-1] == ' ' {
+		mw.out = mw.out[:len(mw.out)-1]
+	}
+}
+
+// line ends the current line, unless the output is already at the start of one
+func (mw *markdownWriter) line() {
+	mw.trim()
+	if len(mw.out) > 0 && mw.out[len(mw.out)-1] != '\n' {
+		mw.out = append(mw.out, '\n')
+	}
+}
+
+// block ends the current paragraph, except within a list item
+// where paragraphs are run together
+func (mw *markdownWriter) block() {
+	if len(mw.lists) > 0 {
+		mw.line()
+		return
+	}
+	mw.line()
+	if n := len(mw.out); n > 0 && (n < 2 || mw.out[n-2] != '\n') {
+		mw.out = append(mw.out, '\n')
+	}
+}
+
+// text writes the text s, collapsing its white space outside of <pre>
+func (mw *markdownWriter) text(s string) {
+	s = html.UnescapeString(s)
+	if mw.pre > 0 {
+		mw.out = append(mw.out, s...)
+		return
+	}
+	if strings.TrimSpace(s) == "" {
+		if s != "" && len(mw.out) > 0 && mw.out[len(mw.out)-1] != '\n' {
+			mw.trim()
+			mw.out = append(mw.out, ' ')
+		}
+		return
+	}
+	words := strings.Join(strings.Fields(s), " ")
+	if strings.TrimLeft(s, " \t\r\n") != s && len(mw.out) > 0 && mw.out[len(mw.out)-1] != '\n' {
+		mw.trim()
+		words = " " + words
+	}
+	if strings.TrimRight(s, " \t\r\n") != s {
+		words += " "
+	}
+	mw.out = append(mw.out, words...)
+}
+
+// mark writes an inline marker such as ** or `, keeping it next to the text
+// it marks when closing so that "<b>word </b>" becomes "**word** "
+func (mw *markdownWriter) mark(m string, closing bool) {
+	if mw.pre > 0 {
+		return
+	}
+	if !closing {
+		mw.out = append(mw.out, m...)
+		return
+	}
+	n := len(mw.out)
+	mw.trim()
+	mw.out = append(mw.out, m...)
+	if len(mw.out)-len(m) < n {
+		mw.out = append(mw.out, ' ')
+	}
+}
+
+// tag converts a single opening or closing tag
+// elements without a Markdown equivalent are left out, keeping their text
+func (mw *markdownWriter) tag(name string, closing bool, attrs string) {
+	switch name {
+	case "h1", "h2", "h3", "h4", "h5", "h6":
+		mw.block()
+		if !closing {
+			level, _ := strconv.Atoi(name[1:])
+			mw.out = append(mw.out, strings.Repeat("#", level)+" "...)
+		}
+	case "p", "div", "section", "article", "header", "footer", "blockquote", "table", "tr", "hr", "dl", "figure":
+		mw.block()
+	case "br", "dt", "dd":
+		mw.line()
+	case "td", "th":
+		mw.text(" ")
+	case "pre":
+		if closing {
+			if mw.pre > 0 {
+				mw.line()
+				mw.pre--
+				mw.out = append(mw.out, "```"...)
+				mw.block()
+			}
+			return
+		}
+		mw.block()
+		mw.out = append(mw.out, "```\n"...)
+		mw.pre++
+	case "code", "kbd", "samp", "tt":
+		mw.mark("`", closing)
+	case "strong":
+		mw.mark("**", closing)
+	case "b":
+		// editors such as Google Docs wrap whole documents in <b style="font-weight:normal">
+		if !closing {
+			bold := !htmlNormal.MatchString(attrs)
+			mw.bold = append(mw.bold, bold)
+			if bold {
+				mw.mark("**", false)
+			}
+		} else if n := len(mw.bold); n > 0 {
+			if mw.bold[n-1] {
+				mw.mark("**", true)
+			}
+			mw.bold = mw.bold[:n-1]
+		}
+	case "em", "i", "cite":
+		mw.mark("*", closing)
+	case "a":
+		if !closing {
+			mw.links = append(mw.links, openLink{href: attr(htmlHref, attrs), start: len(mw.out)})
+			return
+		}
+		if len(mw.links) == 0 {
+			return
+		}
+		l := mw.links[len(mw.links)-1]
+		mw.links = mw.links[:len(mw.links)-1]
+		text := strings.TrimSpace(string(mw.out[l.start:]))
+		if l.href == "" || strings.ContainsAny(l.href, " ()") || strings.HasPrefix(strings.ToLower(l.href), "javascript:") || strings.Contains(text, "\n") {
+			return
+		}
+		mw.out = mw.out[:l.start]
+		if text == "" || text == l.href {
+			mw.out = append(mw.out, l.href...)
+		} else {
+			mw.out = append(mw.out, "["+text+"]("+l.href+")"...)
+		}
+	case "img":
+		mw.text(attr(htmlAlt, attrs))
+	case "ul", "ol":
+		if closing {
+			if len(mw.lists) > 0 {
+				mw.lists = mw.lists[:len(mw.lists)-1]
+			}
+			mw.block()
+			return
+		}
+		mw.line()
+		if len(mw.lists) == 0 {
+			mw.block()
+		}
+		mw.lists = append(mw.lists, openList{ordered: name == "ol"})
+	case "li":
+		mw.line()
+		if closing || len(mw.lists) == 0 {
+			return
+		}
+		l := &mw.lists[len(mw.lists)-1]
+		mw.out = append(mw.out, strings.Repeat("  ", len(mw.lists)-1)...)
+		if l.ordered {
+			l.n++
+			mw.out = append(mw.out, strconv.Itoa(l.n)+". "...)
+		} else {
+			mw.out = append(mw.out, "- "...)
+		}
+	}
+}
+
+// htmlToMarkdown converts an HTML fragment, such as rich text copied from
+// a web page or a word processor, to Markdown
+// headings, paragraphs, lists, links, bold, italic, inline code and <pre>
+// blocks are converted and any other element is reduced to its text
+func htmlToMarkdown(src string) string {
+	src = htmlDropped.ReplaceAllString(src, "")
+	mw := &markdownWriter{}
+	last := 0
+	for _, m := range htmlToken.FindAllStringSubmatchIndex(src, -1) {
+		mw.text(src[last:m[0]])
+		last = m[1]
+		mw.tag(strings.ToLower(src[m[4]:m[5]]), m[3] > m[2], src[m[6]:m[7]])
+	}
+	mw.text(src[last:])
+	for mw.pre > 0 {
+		mw.tag("pre", true, "")
+	}
+	md := blankLines.ReplaceAllString(string(mw.out), "\n\n")
+	return strings.TrimSpace(md)
+}
+
+// convertHandler returns the Markdown for an HTML fragment POSTed to
+// /convert/html-to-markdown, which the edit form uses when rich text is pasted
+// the fragment is the form value html, or the whole request body when it is
+// sent as text/html
+func convertHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		errorHandler(w, r, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	var src string
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "text/html") {
+		data, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, *maxBodySize))
+		if err != nil {
+			bodyTooLarge(w, r)
+			return
+		}
+		src = string(data)
+	} else {
+		if !parseBody(w, r) {
+			return
+		}
+		src = r.PostFormValue("html")
+	}
+	w.Header().Set("Content-Type", "text/markdown; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Write([]byte(htmlToMarkdown(src)))
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestHTMLToMarkdown(t *testing.T) {
+	for _, tc := range []struct {
+		name, html, want string
+	}{
+		{"text", "plain  text\n here", "plain text here"},
+		{"headings", "<h1>Title</h1><h3>Part</h3>", "# Title\n\n### Part"},
+		{"paragraphs", "<p>one</p><p>two<br>three</p>", "one\n\ntwo\nthree"},
+		{"inline marks", "<p><strong>bold</strong> <em>it</em> <code>x()</code></p>", "**bold** *it* `x()`"},
+		{"space inside a mark", "<b>word </b>next", "**word** next"},
+		{"regular weight b", `<b style="font-weight:normal"><p>not bold</p></b>`, "not bold"},
+		{"link", `<a href="https://example.com/">Example</a>`, "[Example](https://example.com/)"},
+		{"bare link", `<a href="https://example.com/">https://example.com/</a>`, "https://example.com/"},
+		{"javascript link", `<a href="javascript:alert(1)">click</a>`, "click"},
+		{"unordered list", "<ul><li>a</li><li>b</li></ul>", "- a\n- b"},
+		{"ordered list", "<ol><li>a</li><li>b</li></ol>", "1. a\n2. b"},
+		{"nested list", "<ul><li>a<ol><li>b</li></ol></li></ul>", "- a\n  1. b"},
+		{"pre", "<pre>if x {\n  y()\n}</pre>", "```\nif x {\n  y()\n}\n```"},
+		{"unclosed pre", "<pre>code", "```\ncode\n```"},
+		{"entities", "<p>a &amp; b &lt;c&gt;</p>", "a & b <c>"},
+		{"image", `<img src="x.png" alt="a cat">`, "a cat"},
+		{"dropped elements", "<style>p{}</style><!-- note --><p>kept</p><script>alert(1)</script>", "kept"},
+		{"unknown elements", "<span><font>text</font></span>", "text"},
+	} {
+		if got := htmlToMarkdown(tc.html); got != tc.want {
+			t.Errorf("%s: converted to\n%q\nwant\n%q", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestConvertHandler(t *testing.T) {
+	h := newTestWiki(t)
+	w := do(h, postForm("/convert/html-to-markdown", url.Values{"html": {"<h2>Hi</h2>"}}))
+	wantStatus(t, w, http.StatusOK)
+	if got := w.Body.String(); got != "## Hi" {
+		t.Errorf("form conversion %q", got)
+	}
+
+	r := httptest.NewRequest(http.MethodPost, "/convert/html-to-markdown", strings.NewReader("<em>hi</em>"))
+	r.Header.Set("Content-Type", "text/html; charset=utf-8")
+	w = do(h, r)
+	wantStatus(t, w, http.StatusOK)
+	if got := w.Body.String(); got != "*hi*" {
+		t.Errorf("text/html conversion %q", got)
+	}
+
+	wantStatus(t, do(h, get("/convert/html-to-markdown")), http.StatusMethodNotAllowed)
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// editCooldown is the minimum time between two saves of the same page
+var editCooldown = flag.Duration("edit-cooldown", 0, "minimum time between saves of the same page, admins are exempt (0 disables the cooldown)")
+
+// lastSaves records when each page was last saved, keyed by title
+var lastSaves = struct {
+	sync.Mutex
+	at map[string]time.Time
+}{at: map[string]time.Time{}}
+
+// cooldownRemaining returns how long until title may be saved again,
+// zero if it may be saved now
+func cooldownRemaining(title string) time.Duration {
+	if *editCooldown <= 0 {
+		return 0
+	}
+	lastSaves.Lock()
+	defer lastSaves.Unlock()
+	remaining := *editCooldown - time.Since(lastSaves.at[title])
+	if remaining < 0 {
+		return 0
+	}
+	return remaining
+}
+
+// recordSave notes that title has just been saved
+func recordSave(title string) {
+	if *editCooldown <= 0 {
+		return
+	}
+	lastSaves.Lock()
+	defer lastSaves.Unlock()
+	lastSaves.at[title] = time.Now()
+}
+
+// coolingDown rejects the request with HTTP Too Many Requests if title was
+// saved within -edit-cooldown and r is not from an admin, reporting whether it did so
+func coolingDown(w http.ResponseWriter, r *http.Request, title string) bool {
+	wait := cooldownRemaining(title)
+	if wait <= 0 || isAdmin(r) {
+		return false
+	}
+	w.Header().Set("Retry-After", retryAfter(wait))
+	errorHandler(w, r, http.StatusTooManyRequests, fmt.Sprintf("%s was saved moments ago, please wait %s seconds before saving again", title, retryAfter(wait)))
+	return true
+}
+
+// retryAfter formats d as the whole number of seconds of a Retry-After header
+func retryAfter(d time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"html/template"
+	"net/http"
+	"reflect"
+	"regexp"
+	"time"
+)
+
+// devMode enables endpoints that help with developing templates and themes,
+// which expose page internals and must never be turned on in production
+var devMode = flag.Bool("dev", false, "enable the /debug/ endpoints for template development (never use in production)")
+
+// pageDataPath matches /debug/pagedata/{Page.Title}
+var pageDataPath = regexp.MustCompile("^/debug/pagedata/([a-zA-Z0-9]+)$")
+
+// templateData converts v into the fields and methods a template can use
+// on it, keyed by the names templates refer to them by
+// fields of embedded structs are promoted as they are in templates,
+// byte slices and template.HTML become strings
+func templateData(v reflect.Value) interface{} {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+	switch {
+	case v.Type() == reflect.TypeOf(time.Time{}):
+		return v.Interface()
+	case v.Type() == reflect.TypeOf(template.HTML("")):
+		return v.String()
+	case v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.Uint8:
+		return string(v.Bytes())
+	case v.Kind() == reflect.Slice:
+		items := make([]interface{}, v.Len())
+		for i := range items {
+			items[i] = templateData(v.Index(i))
+		}
+		return items
+	case v.Kind() == reflect.Map:
+		m := map[string]interface{}{}
+		for _, k := range v.MapKeys() {
+			m[k.String()] = templateData(v.MapIndex(k))
+		}
+		return m
+	case v.Kind() != reflect.Struct:
+		return v.Interface()
+	}
+
+	m := map[string]interface{}{}
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" {
+			continue
+		}
+		value := templateData(v.Field(i))
+		if embedded, ok := value.(map[string]interface{}); ok && f.Anonymous {
+			for k, x := range embedded {
+				if _, taken := m[k]; !taken {
+					m[k] = x
+				}
+			}
+			continue
+		}
+		m[f.Name] = value
+	}
+	if v.CanAddr() {
+		v = v.Addr()
+	}
+	for i := 0; i < v.NumMethod(); i++ {
+		if mt := v.Type().Method(i); mt.Type.NumIn() == 1 && mt.Type.NumOut() == 1 {
+			m[mt.Name] = templateData(v.Method(i).Call(nil)[0])
+		}
+	}
+	return m
+}
+
+// pageDataHandler returns the data the view template is given for a page as JSON,
+// showing theme developers what their templates can use
+// it is only registered with -dev
+func pageDataHandler(w http.ResponseWriter, r *http.Request) {
+	m := pageDataPath.FindStringSubmatch(r.URL.Path)
+	if m == nil {
+		notFound(w, r)
+		return
+	}
+	p, err := loadPage(m[1])
+	if err != nil {
+		notFound(w, r)
+		return
+	}
+	if denyView(w, r, p) {
+		return
+	}
+	v := newViewPage(p)
+	if *metaSidebar {
+		v.Info = newPageInfo(v)
+	}
+	if *countViews {
+		viewCounts.Lock()
+		v.Views = viewCounts.counts[p.Title]
+		viewCounts.Unlock()
+	}
+	if _, v.HasPrevious, err = previousVersion(p.Title, p.Body); err != nil {
+		errorHandler(w, r, http.StatusInternalServerError, err.Error())
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.SetEscapeHTML(false)
+	enc.Encode(templateData(reflect.ValueOf(v)))
+}
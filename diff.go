@@ -0,0 +1,122 @@
+package main
+
+import (
+	"html/template"
+	"strings"
+)
+
+// diff operations
+const (
+	diffEqual = iota
+	diffInsert
+	diffDelete
+)
+
+// diffLine is one line of a line-by-line diff
+type diffLine struct {
+	Op   int
+	Text string
+}
+
+// diffLines computes a line diff turning a into b using the longest
+// common subsequence of their lines
+func diffLines(a, b []string) []diffLine {
+	// lcs[i][j] is the length of the longest common subsequence of a[i:] and b[j:]
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	var d []diffLine
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			d = append(d, diffLine{diffEqual, a[i]})
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			d = append(d, diffLine{diffDelete, a[i]})
+			i++
+		default:
+			d = append(d, diffLine{diffInsert, b[j]})
+			j++
+		}
+	}
+	for ; i < len(a); i++ {
+		d = append(d, diffLine{diffDelete, a[i]})
+	}
+	for ; j < len(b); j++ {
+		d = append(d, diffLine{diffInsert, b[j]})
+	}
+	return d
+}
+
+// diffSize measures how much changed from old to new, as the number of lines
+// changed and the number of characters between the longest common prefix
+// and suffix of the two
+func diffSize(old, new []byte) (lines, chars int) {
+	ins, del := 0, 0
+	for _, l := range diffLines(bodyLines(old), bodyLines(new)) {
+		switch l.Op {
+		case diffInsert:
+			ins++
+		case diffDelete:
+			del++
+		}
+	}
+	lines = ins
+	if del > lines {
+		lines = del
+	}
+
+	prefix := 0
+	for prefix < len(old) && prefix < len(new) && old[prefix] == new[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(old)-prefix && suffix < len(new)-prefix && old[len(old)-1-suffix] == new[len(new)-1-suffix] {
+		suffix++
+	}
+	chars = len(old) - prefix - suffix
+	if n := len(new) - prefix - suffix; n > chars {
+		chars = n
+	}
+	return lines, chars
+}
+
+// renderDiff renders the diff from old to new as a <pre> block,
+// marking removed lines with <del> and added lines with <ins>
+// an empty old, such as a page yet to be created, has no lines at all
+func renderDiff(old, new []byte) template.HTML {
+	var oldLines []string
+	if len(old) > 0 {
+		oldLines = bodyLines(old)
+	}
+	var out strings.Builder
+	out.WriteString(`<pre class="diff">`)
+	for _, l := range diffLines(oldLines, bodyLines(new)) {
+		text := template.HTMLEscapeString(l.Text)
+		switch l.Op {
+		case diffInsert:
+			out.WriteString("<ins>+ " + text + "</ins>\n")
+		case diffDelete:
+			out.WriteString("<del>- " + text + "</del>\n")
+		default:
+			out.WriteString("  " + text + "\n")
+		}
+	}
+	out.WriteString("</pre>")
+	return template.HTML(out.String())
+}
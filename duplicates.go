@@ -0,0 +1,235 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"hash/fnv"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// duplicateThreshold is the Jaccard similarity of their word shingles
+// from which two pages are reported as near duplicates
+var duplicateThreshold = flag.Float64("duplicate-threshold", 0.8, "similarity between 0 and 1 from which /admin/duplicates reports two pages as duplicates")
+
+// near duplicate detection settings: pages are compared by their sets of
+// shingles, runs of shingleWords words, and candidate pairs are found by
+// MinHash with minhashBands bands of minhashRows rows, which catches pairs
+// above a similarity of about (1/bands)^(1/rows), 0.5 here, almost surely
+// before their similarity is computed exactly
+const (
+	shingleWords = 5
+	minhashBands = 16
+	minhashRows  = 4
+)
+
+// shingles returns the hashes of the distinct shingles of the words of content,
+// texts shorter than a shingle having the single shingle of all their words
+func shingles(content []byte) map[uint64]bool {
+	words := strings.FieldsFunc(strings.ToLower(string(content)), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	set := map[uint64]bool{}
+	add := func(shingle []string) {
+		h := fnv.New64a()
+		h.Write([]byte(strings.Join(shingle, " ")))
+		set[h.Sum64()] = true
+	}
+	if len(words) > 0 && len(words) < shingleWords {
+		add(words)
+	}
+	for i := 0; i+shingleWords <= len(words); i++ {
+		add(words[i : i+shingleWords])
+	}
+	return set
+}
+
+// minhashSeeds are the multipliers of the hash functions of the MinHash
+// signatures, odd so that each one permutes the 64 bit hashes
+var minhashSeeds = func() []uint64 {
+	seeds := make([]uint64, minhashBands*minhashRows)
+	x := uint64(0x9e3779b97f4a7c15)
+	for i := range seeds {
+		// splitmix64
+		x += 0x9e3779b97f4a7c15
+		z := x
+		z = (z ^ z>>30) * 0xbf58476d1ce4e5b9
+		z = (z ^ z>>27) * 0x94d049bb133111eb
+		seeds[i] = (z ^ z>>31) | 1
+	}
+	return seeds
+}()
+
+// minhash is the MinHash signature of a shingle set
+func minhash(set map[uint64]bool) []uint64 {
+	sig := make([]uint64, len(minhashSeeds))
+	for i := range sig {
+		sig[i] = ^uint64(0)
+	}
+	for s := range set {
+		for i, seed := range minhashSeeds {
+			if h := (s ^ s>>29) * seed; h < sig[i] {
+				sig[i] = h
+			}
+		}
+	}
+	return sig
+}
+
+// jaccard is the similarity of two shingle sets
+func jaccard(a, b map[uint64]bool) float64 {
+	if len(a) > len(b) {
+		a, b = b, a
+	}
+	shared := 0
+	for s := range a {
+		if b[s] {
+			shared++
+		}
+	}
+	return float64(shared) / float64(len(a)+len(b)-shared)
+}
+
+// duplicatePair is two pages and the similarity of their bodies
+type duplicatePair struct {
+	A          string  `json:"a"`
+	B          string  `json:"b"`
+	Similarity float64 `json:"similarity"`
+}
+
+// duplicateGroup is a set of pages each similar to another one of the group
+type duplicateGroup struct {
+	Pages []string        `json:"pages"`
+	Pairs []duplicatePair `json:"pairs"`
+}
+
+// duplicateReport is the result of /admin/duplicates, groups with the
+// most similar pairs first
+type duplicateReport struct {
+	Threshold float64          `json:"threshold"`
+	Pages     int              `json:"pages"`
+	Groups    []duplicateGroup `json:"groups"`
+}
+
+// findDuplicates compares the bodies of the pages titles, leaving out
+// their frontmatter, and groups those at least threshold similar
+func findDuplicates(titles []string, threshold float64) (duplicateReport, error) {
+	report := duplicateReport{Threshold: threshold, Pages: len(titles), Groups: []duplicateGroup{}}
+	sets := map[string]map[uint64]bool{}
+	buckets := map[[2]uint64][]string{}
+	for _, title := range titles {
+		p, err := loadPage(title)
+		if err != nil {
+			return report, err
+		}
+		set := shingles(p.Content)
+		if len(set) == 0 {
+			continue
+		}
+		sets[title] = set
+		sig := minhash(set)
+		for band := 0; band < minhashBands; band++ {
+			h := fnv.New64a()
+			for _, v := range sig[band*minhashRows : (band+1)*minhashRows] {
+				for i := 0; i < 8; i++ {
+					h.Write([]byte{byte(v >> (8 * i))})
+				}
+			}
+			key := [2]uint64{uint64(band), h.Sum64()}
+			buckets[key] = append(buckets[key], title)
+		}
+	}
+
+	// union-find over the pairs that are similar enough
+	parent := map[string]string{}
+	var root func(string) string
+	root = func(t string) string {
+		if parent[t] == "" || parent[t] == t {
+			return t
+		}
+		parent[t] = root(parent[t])
+		return parent[t]
+	}
+	compared := map[[2]string]bool{}
+	var pairs []duplicatePair
+	for _, bucket := range buckets {
+		for i := 0; i < len(bucket); i++ {
+			for j := i + 1; j < len(bucket); j++ {
+				a, b := bucket[i], bucket[j]
+				if b < a {
+					a, b = b, a
+				}
+				if compared[[2]string{a, b}] {
+					continue
+				}
+				compared[[2]string{a, b}] = true
+				if sim := jaccard(sets[a], sets[b]); sim >= threshold {
+					pairs = append(pairs, duplicatePair{A: a, B: b, Similarity: float64(int(sim*1000+0.5)) / 1000})
+					parent[root(b)] = root(a)
+				}
+			}
+		}
+	}
+
+	groups := map[string]*duplicateGroup{}
+	sort.Slice(pairs, func(i, j int) bool {
+		if pairs[i].Similarity != pairs[j].Similarity {
+			return pairs[i].Similarity > pairs[j].Similarity
+		}
+		return pairs[i].A+" "+pairs[i].B < pairs[j].A+" "+pairs[j].B
+	})
+	var order []string
+	for _, pair := range pairs {
+		r := root(pair.A)
+		g := groups[r]
+		if g == nil {
+			g = &duplicateGroup{}
+			groups[r] = g
+			order = append(order, r)
+		}
+		g.Pairs = append(g.Pairs, pair)
+	}
+	for _, r := range order {
+		g := groups[r]
+		seen := map[string]bool{}
+		for _, pair := range g.Pairs {
+			for _, t := range []string{pair.A, pair.B} {
+				if !seen[t] {
+					seen[t] = true
+					g.Pages = append(g.Pages, t)
+				}
+			}
+		}
+		sort.Strings(g.Pages)
+		report.Groups = append(report.Groups, *g)
+	}
+	return report, nil
+}
+
+// duplicatesHandler reports the groups of pages with near identical bodies,
+// ?threshold= overriding -duplicate-threshold
+func duplicatesHandler(w http.ResponseWriter, r *http.Request) {
+	threshold := *duplicateThreshold
+	if v := r.FormValue("threshold"); v != "" {
+		var err error
+		if threshold, err = strconv.ParseFloat(v, 64); err != nil || threshold < 0 || threshold > 1 {
+			errorHandler(w, r, http.StatusBadRequest, "threshold must be a number between 0 and 1")
+			return
+		}
+	}
+	titles, err := listPages()
+	if err != nil {
+		errorHandler(w, r, http.StatusInternalServerError, err.Error())
+		return
+	}
+	report, err := findDuplicates(titles, threshold)
+	if err != nil {
+		errorHandler(w, r, http.StatusInternalServerError, err.Error())
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
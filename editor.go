@@ -0,0 +1,85 @@
+package main
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// maxEditorLen caps the length of an editor name entered in the edit form
+const maxEditorLen = 40
+
+// editorCookie remembers the name an anonymous editor last entered
+const editorCookie = "editor"
+
+// editorPath returns the file recording who last edited title,
+// kept next to the page file
+func editorPath(title string) string {
+	return filepath.Join(pageDir(title), title+".editor")
+}
+
+// signedInUser is the user r is authenticated as, by session or Basic Auth,
+// or "" for anonymous requests
+func signedInUser(r *http.Request) string {
+	if user := sessionUser(r); user != "" {
+		return user
+	}
+	if user, pass, ok := r.BasicAuth(); ok && validLogin(user, pass) {
+		return user
+	}
+	return ""
+}
+
+// editorName is the name a save made by r is attributed to, the signed in user
+// or else the name entered in the edit form, "" if there is neither
+func editorName(r *http.Request) string {
+	if user := signedInUser(r); user != "" {
+		return user
+	}
+	name := strings.Join(strings.Fields(strings.ToValidUTF8(r.FormValue("editor"), "")), " ")
+	if n := []rune(name); len(n) > maxEditorLen {
+		name = string(n[:maxEditorLen])
+	}
+	return name
+}
+
+// rememberEditor keeps the name an anonymous editor entered in a cookie
+// so that the edit form can offer it again next time
+func rememberEditor(w http.ResponseWriter, r *http.Request, name string) {
+	if name == "" || signedInUser(r) != "" {
+		return
+	}
+	http.SetCookie(w, &http.Cookie{Name: editorCookie, Value: url.QueryEscape(name), Path: "/", MaxAge: int(365 * 24 * time.Hour / time.Second), HttpOnly: true, SameSite: http.SameSiteLaxMode})
+}
+
+// rememberedEditor is the name r's editor cookie holds, if any
+func rememberedEditor(r *http.Request) string {
+	c, err := r.Cookie(editorCookie)
+	if err != nil {
+		return ""
+	}
+	name, _ := url.QueryUnescape(c.Value)
+	return name
+}
+
+// readEditor returns who last edited title, "" if that is not known
+func readEditor(title string) string {
+	name, _ := ioutil.ReadFile(editorPath(title))
+	return string(name)
+}
+
+// writeEditor records editor as having last edited title,
+// forgetting the previous editor if editor is ""
+func writeEditor(title, editor string) error {
+	if editor == "" {
+		if err := os.Remove(editorPath(title)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+	return ioutil.WriteFile(editorPath(title), []byte(editor), 0600)
+}
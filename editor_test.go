@@ -0,0 +1,76 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// editorSave is a save of the page Notes naming editor in the edit form
+func editorSave(editor string) *http.Request {
+	return postForm("/save/Notes", url.Values{"body": {"text " + editor}, "editor": {editor}})
+}
+
+// wantEditor fails the test unless Notes was last edited by want
+func wantEditor(t *testing.T, name, want string) {
+	t.Helper()
+	if got := readEditor("Notes"); got != want {
+		t.Errorf("%s: editor %q, want %q", name, got, want)
+	}
+	vs, _ := versions("Notes")
+	if got := vs[len(vs)-1].Editor; got != want {
+		t.Errorf("%s: version saved by %q, want %q", name, got, want)
+	}
+}
+
+func TestEditorAttribution(t *testing.T) {
+	h := newTestWiki(t, "admin-user=admin", "admin-pass=pass")
+	for _, tc := range []struct {
+		name string
+		r    *http.Request
+		want string
+	}{
+		{"admin", asAdmin(editorSave("mallory")), "admin"},
+		{"anonymous with a name", editorSave("  Jane   Doe "), "Jane Doe"},
+		{"anonymous with a long name", editorSave(strings.Repeat("x", 50)), strings.Repeat("x", maxEditorLen)},
+		{"anonymous without a name", editorSave(""), ""},
+	} {
+		wantStatus(t, do(h, tc.r), http.StatusFound)
+		wantEditor(t, tc.name, tc.want)
+	}
+
+	h = newTestWiki(t, "session-secret=secret")
+	wantStatus(t, do(h, asUser(editorSave("mallory"), "alice")), http.StatusFound)
+	wantEditor(t, "session", "alice")
+}
+
+func TestRememberedEditor(t *testing.T) {
+	h := newTestWiki(t, "admin-user=admin", "admin-pass=pass")
+	w := do(h, postForm("/save/Notes", url.Values{"body": {"text"}, "editor": {"Jane"}}))
+	wantStatus(t, w, http.StatusFound)
+	var cookie *http.Cookie
+	for _, c := range w.Result().Cookies() {
+		if c.Name == editorCookie {
+			cookie = c
+		}
+	}
+	if cookie == nil {
+		t.Fatal("an anonymous editor's name was not remembered")
+	}
+	if body := do(h, get("/view/Notes")).Body.String(); !strings.Contains(body, "Last edited by Jane") {
+		t.Errorf("view does not credit Jane:\n%s", body)
+	}
+	r := get("/edit/Other")
+	r.AddCookie(cookie)
+	if body := do(h, r).Body.String(); !strings.Contains(body, `name="editor" maxlength="40" size="30" value="Jane"`) {
+		t.Errorf("edit form does not offer the remembered name:\n%s", body)
+	}
+
+	w = do(h, asAdmin(editorSave("Jane")))
+	for _, c := range w.Result().Cookies() {
+		if c.Name == editorCookie {
+			t.Errorf("a signed in save set the editor cookie %q", c.Value)
+		}
+	}
+}
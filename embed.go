@@ -0,0 +1,45 @@
+package main
+
+import (
+	"flag"
+	"net/http"
+	"regexp"
+	"time"
+)
+
+// embedAncestors lists the sites allowed to frame /embed/ pages,
+// as CSP frame-ancestors sources
+var embedAncestors = flag.String("embed-ancestors", "", `space separated CSP frame-ancestors sources allowed to embed /embed/ pages, e.g. "https://dash.example.com" (empty = same origin only)`)
+
+// embedPath matches /embed/{Page.Title}
+var embedPath = regexp.MustCompile("^/embed/([a-zA-Z0-9]+)$")
+
+// embedHandler renders just the content of a page, without the wiki's
+// navigation, for showing inside an iframe on the sites in -embed-ancestors
+func embedHandler(w http.ResponseWriter, r *http.Request) {
+	if *embedAncestors == "" {
+		w.Header().Set("X-Frame-Options", "SAMEORIGIN")
+		w.Header().Set("Content-Security-Policy", "frame-ancestors 'self'")
+	} else {
+		w.Header().Set("Content-Security-Policy", "frame-ancestors "+*embedAncestors)
+	}
+	m := embedPath.FindStringSubmatch(r.URL.Path)
+	if m == nil {
+		notFound(w, r)
+		return
+	}
+	p, err := loadPage(m[1])
+	if err != nil {
+		notFound(w, r)
+		return
+	}
+	if denyView(w, r, p) {
+		return
+	}
+	if p.Meta.expired(time.Now()) {
+		errorHandler(w, r, http.StatusGone, p.Title+" has expired")
+		return
+	}
+	setPageCache(w, p)
+	renderTemplate(w, r, "embed", newViewPage(p))
+}
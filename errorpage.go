@@ -0,0 +1,68 @@
+package main
+
+import (
+	"bytes"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// errorPage is the data for the error templates
+type errorPage struct {
+	Status     int
+	StatusText string
+	Message    string
+}
+
+// errorTemplate finds the template for an error response with status in theme,
+// error{status}.html if the theme or else the default theme has one,
+// and the generic error.html otherwise
+func errorTemplate(theme string, status int) string {
+	specific := "error" + strconv.Itoa(status) + ".html"
+	for _, name := range []string{theme, *defaultTheme} {
+		if templates[name][specific] != nil {
+			return specific
+		}
+		if templates[name]["error.html"] != nil {
+			return "error.html"
+		}
+	}
+	return ""
+}
+
+// errorHandler replies to r with an error status and msg, a short message
+// that is shown escaped, rendering the theme's template for the status
+// for browsers and falling back to plain text for other clients
+// the details of internal server errors are logged rather than shown
+// headers already set, such as Retry-After or Allow, are kept
+func errorHandler(w http.ResponseWriter, r *http.Request, status int, msg string) {
+	if status == http.StatusInternalServerError {
+		log.Printf("%s %s: %s", r.Method, r.URL.Path, msg)
+		msg = "something went wrong, please try again later"
+	}
+	if !strings.Contains(r.Header.Get("Accept"), "text/html") {
+		http.Error(w, msg, status)
+		return
+	}
+	theme := requestTheme(w, r)
+	name := errorTemplate(theme, status)
+	if name == "" {
+		http.Error(w, msg, status)
+		return
+	}
+	var buf bytes.Buffer
+	if err := themeTemplate(theme, name).Execute(&buf, &errorPage{Status: status, StatusText: http.StatusText(status), Message: msg}); err != nil {
+		http.Error(w, msg, status)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	buf.WriteTo(w)
+}
+
+// notFound replies to r with 404 Not Found using errorHandler
+func notFound(w http.ResponseWriter, r *http.Request) {
+	errorHandler(w, r, http.StatusNotFound, "not found")
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"html/template"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// htmlRequest is a GET of target from a browser
+func htmlRequest(target string) *http.Request {
+	r := get(target)
+	r.Header.Set("Accept", "text/html,application/xhtml+xml")
+	return r
+}
+
+func TestErrorTemplates(t *testing.T) {
+	newTestWiki(t)
+	custom := template.Must(template.New("error503.html").Parse(`<h1>Back soon</h1><p>{{.Status}}: {{.Message}}</p>`))
+	templates["classic"]["error503.html"] = custom
+	t.Cleanup(func() { delete(templates["classic"], "error503.html") })
+
+	for _, tc := range []struct {
+		status int
+		msg    string
+		want   string
+	}{
+		{http.StatusBadRequest, "bad <input>", "<h1>400 Bad Request</h1>\n\n<p class=\"error\">bad &lt;input&gt;</p>"},
+		{http.StatusForbidden, "sign in", "<h1>403 Forbidden</h1>"},
+		{http.StatusNotFound, "not found", "<h1>Not found</h1>"},
+		{http.StatusTooManyRequests, "slow down", "<h1>429 Too Many Requests</h1>"},
+		{http.StatusInternalServerError, "disk on fire", "something went wrong, please try again later"},
+		{http.StatusServiceUnavailable, "maintenance", "<h1>Back soon</h1><p>503: maintenance</p>"},
+	} {
+		w := httptest.NewRecorder()
+		errorHandler(w, htmlRequest("/view/Page"), tc.status, tc.msg)
+		body := w.Body.String()
+		if w.Code != tc.status || !strings.Contains(body, tc.want) {
+			t.Errorf("%d: status %d, body\n%s\nwant it to contain\n%s", tc.status, w.Code, body, tc.want)
+		}
+		if tc.status == http.StatusInternalServerError && strings.Contains(body, tc.msg) {
+			t.Error("internal error details shown to the client")
+		}
+		if ct := w.Header().Get("Content-Type"); ct != "text/html; charset=utf-8" {
+			t.Errorf("%d: Content-Type %q", tc.status, ct)
+		}
+	}
+}
+
+func TestErrorPlainText(t *testing.T) {
+	h := newTestWiki(t)
+	w := do(h, get("/view/no.page"))
+	wantStatus(t, w, http.StatusNotFound)
+	if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain") || strings.TrimSpace(w.Body.String()) != "not found" {
+		t.Errorf("non-browser 404 is %q: %q", ct, w.Body)
+	}
+	w = do(h, htmlRequest("/view/no.page"))
+	wantStatus(t, w, http.StatusNotFound)
+	if !strings.Contains(w.Body.String(), "<h1>Not found</h1>") {
+		t.Errorf("browser 404 does not use error404.html:\n%s", w.Body)
+	}
+	if got := errorTemplate("modern", http.StatusNotFound); got != "error.html" {
+		t.Errorf("modern theme 404 uses %q, want its own error.html", got)
+	}
+	if got := errorTemplate("classic", http.StatusTeapot); got != "error.html" {
+		t.Errorf("unmapped status uses %q", got)
+	}
+}
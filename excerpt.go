@@ -0,0 +1,81 @@
+package main
+
+import (
+	"flag"
+	"html"
+	"strings"
+)
+
+// excerptLength caps the excerpt shown for each page in listings
+var excerptLength = flag.Int("excerpt-length", 160, "maximum length in characters of the excerpt shown for each page on /recent, /prefix/ and in /api/recent (0 hides excerpts)")
+
+// pageExcerpt is the short plain text description of p shown in listings,
+// its frontmatter summary if it has one or else the first paragraph of its
+// content, cut to at most n characters at a word boundary
+func pageExcerpt(p *Page, n int) string {
+	if n <= 0 {
+		return ""
+	}
+	if p.Meta.Summary != "" {
+		return truncate(n, plainText(p.Title, p.Meta.Summary))
+	}
+	return excerpt(p.Title, p.Content, n)
+}
+
+// excerpt is the plain text of the first paragraph of content, the body of
+// the page title, cut to at most n characters at a word boundary
+// headings, fenced code, math blocks, directives and other non-paragraph
+// blocks are skipped, and quotes count as paragraphs without their '>' markers
+func excerpt(title string, content []byte, n int) string {
+	var para []string
+	fenced, inMath := false, false
+lines:
+	for _, line := range bodyLines(content) {
+		trimmed := strings.TrimSpace(line)
+		if quoted := strings.TrimLeft(trimmed, "> "); quoted != trimmed {
+			if trimmed = quoted; trimmed == "" || strings.HasPrefix(trimmed, "[!") {
+				continue
+			}
+		}
+		switch {
+		case strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~"):
+			fenced = !fenced
+		case fenced:
+		case *enableMath && trimmed == mathDelim:
+			inMath = !inMath
+		case inMath:
+		case trimmed == "":
+			if len(para) > 0 {
+				break lines
+			}
+		case strings.HasPrefix(trimmed, "#") || strings.HasPrefix(trimmed, "$$") ||
+			strings.HasPrefix(trimmed, "|") || strings.HasPrefix(trimmed, "<") ||
+			strings.HasPrefix(trimmed, "{{"):
+		default:
+			para = append(para, trimmed)
+		}
+	}
+	return truncate(n, plainText(title, strings.Join(para, "\n")))
+}
+
+// plainText renders the markup of text, from the page title, and reduces
+// the result to plain text on a single line
+// tags are dropped before entities are decoded so that an escaped "<" is kept,
+// and without leaving a space so that "*text*." stays "text."
+func plainText(title, text string) string {
+	rendered := htmlAnyTag.ReplaceAllString(string(renderBody(title, []byte(text))), "")
+	return strings.Join(strings.Fields(html.UnescapeString(rendered)), " ")
+}
+
+// withExcerpts fills in the excerpt of each change that can be loaded
+func withExcerpts(changes []pageChange) []pageChange {
+	if *excerptLength <= 0 {
+		return changes
+	}
+	for i := range changes {
+		if p, err := loadPage(changes[i].Title); err == nil {
+			changes[i].Excerpt = pageExcerpt(p, *excerptLength)
+		}
+	}
+	return changes
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestExcerpt(t *testing.T) {
+	newTestWiki(t, "math=true")
+	long := strings.Repeat("word ", 60)
+	for _, tc := range []struct {
+		name, body string
+		n          int
+		want       string
+	}{
+		{"short", "Just a line.", 160, "Just a line."},
+		{"heading first", "# Title\n\n## Sub\nThe *first* paragraph\nruns on.\n\nThe second.", 160, "The first paragraph runs on."},
+		{"code first", "```\nfunc main() {}\n```\n\nAfter the code.", 160, "After the code."},
+		{"tilde code first", "~~~\n# not a heading\n\nnor a paragraph\n~~~\nAfter.", 160, "After."},
+		{"long", long, 23, "word word word word…"},
+		{"long with markup", "A [link](https://example.com/) and **bold** text going on and on", 20, "A link and bold…"},
+		{"other blocks first", "| a | b |\n<div>raw</div>\n{{toc}}\n$$\nx\n$$\nText.", 160, "Text."},
+		{"quote", "> [!NOTE]\n> Quoted *text*.", 160, "Quoted text."},
+		{"entities", "Fish & chips < 5", 160, "Fish & chips < 5"},
+		{"nothing but headings", "# One\n## Two", 160, ""},
+		{"hidden", "Some text.", 0, ""},
+	} {
+		if got := pageExcerpt(newPage("Test", []byte(tc.body)), tc.n); got != tc.want {
+			t.Errorf("%s: excerpt %q, want %q", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestExcerptSummary(t *testing.T) {
+	newTestWiki(t)
+	p := newPage("Test", []byte("---\nsummary: The *real* summary, which is long\n---\nThe first paragraph."))
+	if got, want := pageExcerpt(p, 20), "The real summary…"; got != want {
+		t.Errorf("excerpt %q, want %q", got, want)
+	}
+}
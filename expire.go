@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"time"
+)
+
+// expireInterval is how often pages past their "expires:" date are deleted
+var expireInterval = flag.Duration("expire-interval", time.Hour, "how often to scan for and delete pages past their expires date")
+
+// runExpirer periodically deletes expired pages until ctx is done
+func runExpirer(ctx context.Context) {
+	ticker := time.NewTicker(*expireInterval)
+	defer ticker.Stop()
+	for {
+		if !isReadOnly() {
+			if err := sweepExpired(time.Now()); err != nil {
+				log.Printf("expire: %v", err)
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// sweepExpired deletes every page whose frontmatter says it expired before now
+// until the sweep gets to them expired pages are answered with 410 Gone
+func sweepExpired(now time.Time) error {
+	titles, err := listPages()
+	if err != nil {
+		return err
+	}
+	for _, title := range titles {
+		if err := expirePage(title, now); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// expirePage deletes title if it has expired by now
+func expirePage(title string, now time.Time) error {
+	unlock := lockPage(title)
+	defer unlock()
+	p, err := loadPage(title)
+	if err != nil {
+		return err
+	}
+	if !p.Meta.expired(now) {
+		return nil
+	}
+	if err := removePage(title); err != nil {
+		return err
+	}
+	log.Printf("deleted expired page %s", title)
+	return nil
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"html"
+	"html/template"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// settings for converting standalone HTML exports to PDF
+// PDF export is unavailable unless -pdf-tool is set
+var (
+	pdfTool    = flag.String("pdf-tool", "", "path to wkhtmltopdf or a chromium binary used for PDF export (empty disables PDF export)")
+	pdfTimeout = flag.Duration("pdf-timeout", 30*time.Second, "maximum time allowed for converting a page to PDF")
+)
+
+// exportPath matches urls of standalone page exports: /export/{Page.Title}.{html|pdf}
+var exportPath = regexp.MustCompile(`^/export/([a-zA-Z0-9]+)\.(html|pdf)$`)
+
+// exportHandler serves a Page as a self-contained HTML document
+// with inlined styles and no site chrome, or as a PDF rendered from it,
+// prompting the browser to download it, /export/{Page.Title}/bundle
+// is left to bundleHandler
+// if the page does not exist, an HTTP Not Found error is returned
+func exportHandler(w http.ResponseWriter, r *http.Request) {
+	if m := bundlePath.FindStringSubmatch(r.URL.Path); m != nil {
+		bundleHandler(w, r, m[1])
+		return
+	}
+	m := exportPath.FindStringSubmatch(r.URL.Path)
+	if m == nil {
+		notFound(w, r)
+		return
+	}
+	p, err := loadPage(m[1])
+	if err != nil {
+		notFound(w, r)
+		return
+	}
+	if denyView(w, r, p) {
+		return
+	}
+	if m[2] == "pdf" {
+		exportPDF(w, r, p)
+		return
+	}
+	w.Header().Set("Content-Disposition", `attachment; filename="`+p.Title+`.html"`)
+	renderTemplate(w, r, "export", newViewPage(p))
+}
+
+// exportPDF converts the standalone HTML export of p into a PDF with -pdf-tool
+// as a job, see waitJob, and writes it to the response
+// with ?async=1 the job runs in the background instead, see startJob
+// if no tool is configured, an HTTP Not Implemented error is returned
+func exportPDF(w http.ResponseWriter, r *http.Request, p *Page) {
+	if *pdfTool == "" {
+		errorHandler(w, r, http.StatusNotImplemented, "PDF export is not configured")
+		return
+	}
+	theme, base := requestTheme(w, r), absoluteURL(r, "/view/"+p.Title)
+	render := func(ctx context.Context) func() (jobResult, error) {
+		return func() (jobResult, error) {
+			pdf, err := renderPDF(ctx, theme, p, base)
+			return jobResult{contentType: "application/pdf", filename: p.Title + ".pdf", body: pdf}, err
+		}
+	}
+	if r.FormValue("async") != "" {
+		startJob(w, r, render(context.Background()))
+		return
+	}
+	waitJob(w, r, render(r.Context()))
+}
+
+// renderPDF writes the standalone HTML for p in theme to a temporary directory,
+// runs -pdf-tool over it and returns the resulting PDF
+// relative sources in the page are made absolute against base, the page's url,
+// as the tool would otherwise load them from the local filesystem
+// the temporary directory is always removed and the tool is killed
+// if it runs longer than -pdf-timeout
+func renderPDF(ctx context.Context, theme string, p *Page, base string) ([]byte, error) {
+	v := newViewPage(p)
+	var err error
+	if v.HTML, err = absoluteSources(v.HTML, base); err != nil {
+		return nil, err
+	}
+
+	dir, err := ioutil.TempDir("", "gowiki-pdf")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	in := filepath.Join(dir, p.Title+".html")
+	out := filepath.Join(dir, p.Title+".pdf")
+	f, err := os.Create(in)
+	if err != nil {
+		return nil, err
+	}
+	err = themeTemplate(theme, "export.html").Execute(f, v)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, *pdfTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, *pdfTool, pdfToolArgs(in, out)...)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return nil, fmt.Errorf("PDF conversion timed out after %s", *pdfTimeout)
+		}
+		return nil, fmt.Errorf("PDF conversion failed: %v: %s", err, output)
+	}
+	return ioutil.ReadFile(out)
+}
+
+// pdfToolArgs builds the command line for converting in to out,
+// chromium style binaries print headlessly and anything else
+// is assumed to take wkhtmltopdf style arguments
+func pdfToolArgs(in, out string) []string {
+	if strings.Contains(strings.ToLower(filepath.Base(*pdfTool)), "chrom") {
+		return []string{"--headless", "--disable-gpu", "--print-to-pdf=" + out, "file://" + in}
+	}
+	return []string{"--quiet", in, out}
+}
+
+// sourceAttr matches the attributes of rendered HTML that make a browser
+// fetch a url, whose values the renderer always writes double quoted
+var sourceAttr = regexp.MustCompile(`(\s(?:src|poster)=")([^"]*)"`)
+
+// absoluteSources resolves the sources of html against base, dropping those
+// that do not end up as http or https urls
+func absoluteSources(h template.HTML, base string) (template.HTML, error) {
+	b, err := url.Parse(base)
+	if err != nil {
+		return "", err
+	}
+	s := sourceAttr.ReplaceAllStringFunc(string(h), func(m string) string {
+		sm := sourceAttr.FindStringSubmatch(m)
+		u, err := b.Parse(html.UnescapeString(sm[2]))
+		if err != nil || u.Scheme != "http" && u.Scheme != "https" {
+			return ""
+		}
+		return sm[1] + template.HTMLEscapeString(u.String()) + `"`
+	})
+	return template.HTML(s), nil
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestExportPDFNotConfigured(t *testing.T) {
+	h := newTestWiki(t)
+	writePage(t, "Notes", "text")
+	wantStatus(t, do(h, get("/export/Notes.pdf")), http.StatusNotImplemented)
+}
+
+// fakePDFTool writes a stand-in for wkhtmltopdf running script over its
+// input and output files, $2 and $3
+func fakePDFTool(t *testing.T, script string) string {
+	t.Helper()
+	tool := filepath.Join(t.TempDir(), "topdf")
+	if err := os.WriteFile(tool, []byte("#!/bin/sh\n"+script+"\n"), 0700); err != nil {
+		t.Fatal(err)
+	}
+	return tool
+}
+
+func TestExportPDFSources(t *testing.T) {
+	// the tool copies the HTML it is given
+	tool := fakePDFTool(t, `cp "$2" "$3"`)
+	h := newTestWiki(t, "pdf-tool="+tool, "html-policy=relaxed", "base-url=https://wiki.example")
+	runJobWorkers(t)
+	writePage(t, "Notes", `<img src="/etc/passwd"> <img src="../../secret.png"> <img src="https://cdn.example/a.png"> <video poster="../poster.png"></video>`+"\n\nSee [Other].")
+
+	w := do(h, get("/export/Notes.pdf"))
+	wantStatus(t, w, http.StatusOK)
+	if ct, cd := w.Header().Get("Content-Type"), w.Header().Get("Content-Disposition"); ct != "application/pdf" || cd != `attachment; filename="Notes.pdf"` {
+		t.Errorf("Content-Type %q, Content-Disposition %q", ct, cd)
+	}
+	out := w.Body.String()
+	for _, want := range []string{
+		`src="https://wiki.example/etc/passwd"`,
+		`src="https://wiki.example/secret.png"`,
+		`src="https://cdn.example/a.png"`,
+		`poster="https://wiki.example/poster.png"`,
+		`href="/view/Other"`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("export lacks %s:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "file:") || strings.Contains(out, `src="/`) || strings.Contains(out, `src="../`) {
+		t.Errorf("export still refers to local files:\n%s", out)
+	}
+}
+
+func TestExportPDFJobs(t *testing.T) {
+	h := newTestWiki(t, "pdf-tool="+fakePDFTool(t, `echo pdf > "$3"`))
+	emptyJobQueue(t)
+	writePage(t, "Notes", "text")
+
+	w := do(h, get("/export/Notes.pdf?async=1"))
+	wantStatus(t, w, http.StatusAccepted)
+	location := w.Header().Get("Location")
+	runJobWorkers(t)
+	if j := pollJob(t, h, location); j.Status != jobDone {
+		t.Fatalf("PDF job %+v", j)
+	}
+	w = do(h, get(location+"/result"))
+	wantStatus(t, w, http.StatusOK)
+	if w.Body.String() != "pdf\n" || w.Header().Get("Content-Disposition") != `attachment; filename="Notes.pdf"` {
+		t.Errorf("PDF job result %q with headers %v", w.Body, w.Header())
+	}
+}
+
+func TestExportPDFQueueFull(t *testing.T) {
+	h := newTestWiki(t, "pdf-tool="+fakePDFTool(t, `echo pdf > "$3"`))
+	emptyJobQueue(t)
+	writePage(t, "Notes", "text")
+	for i := 0; i < jobQueueSize; i++ {
+		if _, err := submitJob(func() (jobResult, error) { return jobResult{}, nil }); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, target := range []string{"/export/Notes.pdf", "/export/Notes.pdf?async=1"} {
+		w := do(h, get(target))
+		wantStatus(t, w, http.StatusTooManyRequests)
+		if w.Header().Get("Retry-After") == "" {
+			t.Errorf("%s: no Retry-After when the queue is full", target)
+		}
+	}
+}
+
+func TestExportPDFFails(t *testing.T) {
+	h := newTestWiki(t, "pdf-tool="+fakePDFTool(t, "echo broken >&2; exit 1"))
+	runJobWorkers(t)
+	writePage(t, "Notes", "text")
+	w := do(h, get("/export/Notes.pdf"))
+	wantStatus(t, w, http.StatusInternalServerError)
+	if cd := w.Header().Get("Content-Disposition"); cd != "" {
+		t.Errorf("failed conversion offered as a download with %q", cd)
+	}
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"archive/zip"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// bundle export settings
+var (
+	bundleDepth    = flag.Int("bundle-depth", 2, "how many wikilinks away from the page /export/{title}/bundle follows, at most")
+	bundleMaxPages = flag.Int("bundle-max-pages", 50, "maximum number of pages in an /export/{title}/bundle")
+)
+
+// bundlePath matches /export/{Page.Title}/bundle
+var bundlePath = regexp.MustCompile(`^/export/([a-zA-Z0-9]+)/bundle$`)
+
+// bundlePage is a page of a bundle with the number of links it is away from
+// the page the bundle was made for
+type bundlePage struct {
+	*Page
+	Depth int
+}
+
+// linkNeighborhood walks the wikilinks from root breadth first, up to depth links
+// away, and returns the pages reached in the order they were found, root first
+// each page is visited once however many ways it is reached, and pages that do
+// not exist, are expired or cannot be seen by the client behind r are skipped
+// truncated is true if more than max pages could be reached
+func linkNeighborhood(r *http.Request, root *Page, depth, max int) (pages []bundlePage, truncated bool) {
+	seen := map[string]bool{root.Title: true}
+	pages = []bundlePage{{Page: root}}
+	now := time.Now()
+	for i := 0; i < len(pages); i++ {
+		if pages[i].Depth == depth {
+			continue
+		}
+		for _, title := range linkedTitles(pages[i].Content) {
+			if seen[title] {
+				continue
+			}
+			seen[title] = true
+			p, err := loadPage(title)
+			if err != nil || !canView(r, p) || p.Meta.expired(now) {
+				continue
+			}
+			if len(pages) == max {
+				return pages, true
+			}
+			pages = append(pages, bundlePage{Page: p, Depth: pages[i].Depth + 1})
+		}
+	}
+	return pages, false
+}
+
+// bundleHandler serves /export/{Page.Title}/bundle, the page together with the
+// pages reachable from it through wikilinks, as a zip of their .txt sources
+// with an index.txt listing each page and how many links away it is
+// ?depth= follows fewer links than -bundle-depth and ?format=html returns the
+// pages as a single book instead
+// the included pages are also listed in the X-Bundle-Pages header, with
+// X-Bundle-Truncated set when -bundle-max-pages left some out
+func bundleHandler(w http.ResponseWriter, r *http.Request, title string) {
+	p, err := loadPage(title)
+	if err != nil {
+		notFound(w, r)
+		return
+	}
+	if denyView(w, r, p) {
+		return
+	}
+	depth := *bundleDepth
+	if v := r.FormValue("depth"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 || n > *bundleDepth {
+			errorHandler(w, r, http.StatusBadRequest, "depth must be a number from 0 to "+strconv.Itoa(*bundleDepth))
+			return
+		}
+		depth = n
+	}
+	pages, truncated := linkNeighborhood(r, p, depth, *bundleMaxPages)
+
+	titles := make([]string, len(pages))
+	for i, bp := range pages {
+		titles[i] = bp.Title
+	}
+	w.Header().Set("X-Bundle-Pages", strings.Join(titles, ","))
+	if truncated {
+		w.Header().Set("X-Bundle-Truncated", "true")
+	}
+	if r.FormValue("format") == "html" {
+		chapters := make([]*Page, len(pages))
+		for i, bp := range pages {
+			chapters[i] = bp.Page
+		}
+		renderTemplate(w, r, "book", newBook(p.DisplayTitle(), chapters))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", `attachment; filename="`+p.Title+`-bundle.zip"`)
+	zw := zip.NewWriter(w)
+	var index strings.Builder
+	for _, bp := range pages {
+		fmt.Fprintf(&index, "%s\t%d\n", bp.Title, bp.Depth)
+		f, err := zw.CreateHeader(&zip.FileHeader{Name: bp.Title + ".txt", Method: zip.Deflate, Modified: bp.ModTime})
+		if err == nil {
+			_, err = f.Write(bp.Body)
+		}
+		if err != nil {
+			log.Printf("bundle of %s: %v", p.Title, err)
+			return
+		}
+	}
+	if truncated {
+		fmt.Fprintf(&index, "# truncated at %d pages\n", *bundleMaxPages)
+	}
+	f, err := zw.CreateHeader(&zip.FileHeader{Name: "index.txt", Method: zip.Deflate, Modified: time.Now()})
+	if err == nil {
+		_, err = f.Write([]byte(index.String()))
+	}
+	if err == nil {
+		err = zw.Close()
+	}
+	if err != nil {
+		log.Printf("bundle of %s: %v", p.Title, err)
+	}
+}
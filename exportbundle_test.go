@@ -0,0 +1,87 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"net/http"
+	"testing"
+)
+
+// bundleFiles unzips a bundle into its file contents by name
+func bundleFiles(t *testing.T, body []byte) map[string]string {
+	t.Helper()
+	zr, err := zip.NewReader(bytes.NewReader(body), int64(len(body)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{}
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, _ := io.ReadAll(rc)
+		rc.Close()
+		files[f.Name] = string(b)
+	}
+	return files
+}
+
+// newBundleWiki holds pages linking Root -> A -> B -> C, with B linking back
+// to Root and A, and Root also linking to a page that does not exist
+func newBundleWiki(t *testing.T, flags ...string) http.Handler {
+	h := newTestWiki(t, flags...)
+	writePage(t, "Root", "[A] and [Missing]")
+	writePage(t, "A", "[B]")
+	writePage(t, "B", "[C], [Root] and [A]")
+	writePage(t, "C", "the end")
+	return h
+}
+
+func TestBundleDepth(t *testing.T) {
+	h := newBundleWiki(t)
+	for _, tc := range []struct {
+		path, pages string
+	}{
+		{"/export/Root/bundle", "Root,A,B"},
+		{"/export/Root/bundle?depth=0", "Root"},
+		{"/export/Root/bundle?depth=1", "Root,A"},
+	} {
+		w := do(h, get(tc.path))
+		wantStatus(t, w, http.StatusOK)
+		if got := w.Header().Get("X-Bundle-Pages"); got != tc.pages {
+			t.Errorf("%s includes %q, want %q", tc.path, got, tc.pages)
+		}
+	}
+	wantStatus(t, do(h, get("/export/Root/bundle?depth=3")), http.StatusBadRequest)
+	wantStatus(t, do(h, get("/export/Missing/bundle")), http.StatusNotFound)
+}
+
+func TestBundleCycles(t *testing.T) {
+	h := newBundleWiki(t, "bundle-depth=10")
+	w := do(h, get("/export/Root/bundle"))
+	wantStatus(t, w, http.StatusOK)
+	if got := w.Header().Get("X-Bundle-Pages"); got != "Root,A,B,C" {
+		t.Errorf("bundle includes %q, want each page once", got)
+	}
+	files := bundleFiles(t, w.Body.Bytes())
+	if len(files) != 5 || files["B.txt"] != "[C], [Root] and [A]" {
+		t.Errorf("bundle files %v", files)
+	}
+	if want := "Root\t0\nA\t1\nB\t2\nC\t3\n"; files["index.txt"] != want {
+		t.Errorf("index.txt %q, want %q", files["index.txt"], want)
+	}
+}
+
+func TestBundleMaxPages(t *testing.T) {
+	h := newBundleWiki(t, "bundle-depth=10", "bundle-max-pages=2")
+	w := do(h, get("/export/Root/bundle"))
+	wantStatus(t, w, http.StatusOK)
+	if got := w.Header().Get("X-Bundle-Pages"); got != "Root,A" || w.Header().Get("X-Bundle-Truncated") != "true" {
+		t.Errorf("capped bundle includes %q, truncated %q", got, w.Header().Get("X-Bundle-Truncated"))
+	}
+	if got := bundleFiles(t, w.Body.Bytes())["index.txt"]; got != "Root\t0\nA\t1\n# truncated at 2 pages\n" {
+		t.Errorf("index.txt %q", got)
+	}
+}
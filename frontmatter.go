@@ -0,0 +1,218 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// pageMeta is the optional metadata carried in a Page's frontmatter,
+// a block at the very top of the body delimited by '---' lines, e.g.
+//
+//	---
+//	title: Getting Started
+//	tags: [guide, intro]
+//	author: jane
+//	date: 2024-01-31
+//	archived: true
+//	expires: 2024-12-31
+//	access: private
+//	owner: jane
+//	slug: getting-started
+//	summary: How to install the wiki and write a first page
+//	---
+//
+// only this small subset of YAML is understood: "key: value" pairs,
+// optionally quoted, with tags given as a [flow, list] or as '- item' lines
+type pageMeta struct {
+	Title    string
+	Tags     []string
+	Author   string
+	Date     string
+	Archived bool
+	Expires  string
+	Access   string
+	Owner    string
+	Slug     string
+	Summary  string
+}
+
+// frontmatterDelim opens and closes a frontmatter block
+const frontmatterDelim = "---"
+
+// parseFrontmatter splits body into its frontmatter metadata and content
+// a body without frontmatter is returned unchanged as content
+// problems with the frontmatter are described by the returned warning,
+// an unterminated block is treated as ordinary content so nothing is lost
+func parseFrontmatter(body []byte) (pageMeta, []byte, string) {
+	var meta pageMeta
+	lines := bytes.SplitAfter(body, []byte("\n"))
+	if len(lines) == 0 || strings.TrimSpace(string(lines[0])) != frontmatterDelim {
+		return meta, body, ""
+	}
+
+	end := -1
+	for i := 1; i < len(lines); i++ {
+		if strings.TrimSpace(string(lines[i])) == frontmatterDelim {
+			end = i
+			break
+		}
+	}
+	if end < 0 {
+		return meta, body, "frontmatter is missing its closing '---' line"
+	}
+
+	var problems []string
+	var listKey string
+	for i := 1; i < end; i++ {
+		line := strings.TrimRight(string(lines[i]), "\r\n")
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		if strings.HasPrefix(trimmed, "- ") && listKey != "" {
+			meta.set(listKey, unquote(strings.TrimSpace(trimmed[2:])), true)
+			continue
+		}
+		colon := strings.Index(line, ":")
+		if colon <= 0 {
+			problems = append(problems, fmt.Sprintf("line %d", i+1))
+			continue
+		}
+		key := strings.ToLower(strings.TrimSpace(line[:colon]))
+		value := strings.TrimSpace(line[colon+1:])
+		listKey = ""
+		if value == "" {
+			listKey = key
+			continue
+		}
+		if !meta.set(key, value, false) {
+			problems = append(problems, fmt.Sprintf("unknown key %q", key))
+		}
+	}
+
+	if _, err := meta.expiresAt(); err != nil {
+		problems = append(problems, err.Error())
+	}
+	if _, ok := accessRanks[meta.Access]; !ok {
+		problems = append(problems, fmt.Sprintf("invalid access level %q, treating the page as private", meta.Access))
+	}
+	if meta.Slug != "" && !validSlug.MatchString(meta.Slug) {
+		problems = append(problems, fmt.Sprintf("invalid slug %q", meta.Slug))
+	}
+
+	content := bytes.Join(lines[end+1:], nil)
+	warning := ""
+	if len(problems) > 0 {
+		warning = "frontmatter could not be fully parsed: " + strings.Join(problems, ", ")
+	}
+	return meta, content, warning
+}
+
+// set stores value under key, appending to list valued keys when item is true
+// it returns false for keys that are not recognised
+func (m *pageMeta) set(key, value string, item bool) bool {
+	switch key {
+	case "title":
+		m.Title = unquote(value)
+	case "author":
+		m.Author = unquote(value)
+	case "date":
+		m.Date = unquote(value)
+	case "archived":
+		m.Archived = unquote(value) == "true"
+	case "expires":
+		m.Expires = unquote(value)
+	case "access":
+		m.Access = strings.ToLower(unquote(value))
+	case "owner":
+		m.Owner = unquote(value)
+	case "slug":
+		m.Slug = unquote(value)
+	case "summary":
+		m.Summary = unquote(value)
+	case "tags":
+		if item {
+			m.Tags = append(m.Tags, value)
+			return true
+		}
+		m.Tags = append(m.Tags, splitList(value)...)
+	default:
+		return false
+	}
+	return true
+}
+
+// expiresAt parses the expires key, which is either a date, meaning the page
+// expires once that day is over, or an RFC 3339 time
+// the zero time is returned for pages that never expire
+func (m *pageMeta) expiresAt() (time.Time, error) {
+	if m.Expires == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, m.Expires); err == nil {
+		return t, nil
+	}
+	day, err := time.ParseInLocation("2006-01-02", m.Expires, time.Local)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid expires date %q", m.Expires)
+	}
+	return day.AddDate(0, 0, 1), nil
+}
+
+// expired reports whether the page has expired by now,
+// an expires value that cannot be parsed never expires
+func (m *pageMeta) expired(now time.Time) bool {
+	t, err := m.expiresAt()
+	return err == nil && !t.IsZero() && !now.Before(t)
+}
+
+// setFrontmatter returns body with key set to value in its frontmatter,
+// replacing an existing entry for key or adding a frontmatter block if needed
+func setFrontmatter(body []byte, key, value string) []byte {
+	entry := key + ": " + value + "\n"
+	lines := bytes.SplitAfter(body, []byte("\n"))
+	end := -1
+	if len(lines) > 0 && strings.TrimSpace(string(lines[0])) == frontmatterDelim {
+		for i := 1; i < len(lines); i++ {
+			if strings.TrimSpace(string(lines[i])) == frontmatterDelim {
+				end = i
+				break
+			}
+		}
+	}
+	if end < 0 {
+		return append([]byte(frontmatterDelim+"\n"+entry+frontmatterDelim+"\n"), body...)
+	}
+	for i := 1; i < end; i++ {
+		line := string(lines[i])
+		if colon := strings.Index(line, ":"); colon > 0 && strings.ToLower(strings.TrimSpace(line[:colon])) == key {
+			lines[i] = []byte(entry)
+			return bytes.Join(lines, nil)
+		}
+	}
+	out := append([]byte(nil), lines[0]...)
+	out = append(out, entry...)
+	return append(out, bytes.Join(lines[1:], nil)...)
+}
+
+// splitList parses a YAML flow list such as "[a, b]" or a bare "a, b"
+func splitList(value string) []string {
+	value = strings.TrimSuffix(strings.TrimPrefix(value, "["), "]")
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = unquote(strings.TrimSpace(item)); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// unquote strips matching single or double quotes around a YAML scalar
+func unquote(value string) string {
+	if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+		return value[1 : len(value)-1]
+	}
+	return value
+}
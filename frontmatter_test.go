@@ -0,0 +1,75 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFrontmatterRoundTrip(t *testing.T) {
+	newTestWiki(t)
+	body := "---\n" +
+		"title: \"Getting Started\"\n" +
+		"tags: [guide, 'intro']\n" +
+		"author: jane\n" +
+		"date: 2024-01-31\n" +
+		"archived: true\n" +
+		"expires: 2099-12-31\n" +
+		"access: Internal\n" +
+		"owner: jane\n" +
+		"slug: getting-started\n" +
+		"summary: How to install the wiki\n" +
+		"---\n" +
+		"The content\n"
+	want := pageMeta{
+		Title:    "Getting Started",
+		Tags:     []string{"guide", "intro"},
+		Author:   "jane",
+		Date:     "2024-01-31",
+		Archived: true,
+		Expires:  "2099-12-31",
+		Access:   "internal",
+		Owner:    "jane",
+		Slug:     "getting-started",
+		Summary:  "How to install the wiki",
+	}
+	writePage(t, "Guide", body)
+	p, err := loadPage("Guide")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(p.Body) != body {
+		t.Errorf("saved body %q, want %q", p.Body, body)
+	}
+	if !reflect.DeepEqual(p.Meta, want) || string(p.Content) != "The content\n" || p.Warning != "" {
+		t.Errorf("loaded meta %+v, content %q, warning %q", p.Meta, p.Content, p.Warning)
+	}
+
+	writePage(t, "Guide", string(setFrontmatter(p.Body, "title", "Started")))
+	want.Title = "Started"
+	if p, _ = loadPage("Guide"); !reflect.DeepEqual(p.Meta, want) || string(p.Content) != "The content\n" {
+		t.Errorf("after setting the title, meta %+v and content %q", p.Meta, p.Content)
+	}
+}
+
+func TestFrontmatterShapes(t *testing.T) {
+	for _, tc := range []struct {
+		name, body, content string
+		tags                []string
+		warned              bool
+	}{
+		{"none", "just text\n", "just text\n", nil, false},
+		{"item list", "---\ntags:\n- a\n- 'b'\n---\ntext", "text", []string{"a", "b"}, false},
+		{"comments and blanks", "---\n# note\n\ntags: a, b\n---\n", "", []string{"a", "b"}, false},
+		{"unterminated", "---\ntags: a\ntext", "---\ntags: a\ntext", nil, true},
+		{"unknown key", "---\ncolour: red\n---\ntext", "text", nil, true},
+		{"bad access", "---\naccess: secret\n---\ntext", "text", nil, true},
+	} {
+		meta, content, warning := parseFrontmatter([]byte(tc.body))
+		if string(content) != tc.content || !reflect.DeepEqual(meta.Tags, tc.tags) || (warning != "") != tc.warned {
+			t.Errorf("%s: content %q, tags %q, warning %q", tc.name, content, meta.Tags, warning)
+		}
+	}
+	if got := string(setFrontmatter([]byte("text"), "archived", "true")); got != "---\narchived: true\n---\ntext" {
+		t.Errorf("adding frontmatter gives %q", got)
+	}
+}
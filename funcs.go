@@ -0,0 +1,67 @@
+package main
+
+import (
+	"net/url"
+	"strings"
+	"time"
+)
+
+// formatDate formats t with layout, the -locale date and time when none is
+// given or its date alone for the layout "date", and a zero t as ""
+// templates rendered for a request are given the locale it prefers instead,
+// see requestTemplate
+//
+//	{{formatDate .Modified}}  {{formatDate .Modified "date"}}  {{formatDate .Modified "2 Jan 2006"}}
+func formatDate(t time.Time, layout ...string) string {
+	return currentLocale().formatDate(t, layout...)
+}
+
+// relativeTime describes how long ago t was in the -locale language,
+// e.g. "5 minutes ago", falling back to its date once it is more than a month old
+//
+//	{{relativeTime .Modified}}
+func relativeTime(t time.Time) string {
+	return currentLocale().relativeTime(t)
+}
+
+// formatNumber writes n with its digits grouped in thousands as -locale does
+//
+//	{{formatNumber .Views}}
+func formatNumber(n int) string {
+	return currentLocale().formatNumber(n)
+}
+
+// truncate cuts s to at most n characters at a word boundary, marking the cut
+// with an ellipsis, the argument order suiting a pipeline
+//
+//	{{.Summary | truncate 60}}
+func truncate(n int, s string) string {
+	r := []rune(s)
+	if len(r) <= n {
+		return s
+	}
+	cut := string(r[:n])
+	if i := strings.LastIndex(cut, " "); i > 0 {
+		cut = cut[:i]
+	}
+	return strings.TrimRight(cut, " .,;:") + "…"
+}
+
+// urlFor builds the path of a wiki url from its segments, escaping each one
+// and prefixing the path of -base-url so that links keep working when the
+// wiki is served below the root of its host
+//
+//	{{urlFor "view" .Title}}  {{urlFor "static" "editor.js"}}
+func urlFor(segments ...string) string {
+	var b strings.Builder
+	if u, err := url.Parse(*baseURL); err == nil {
+		b.WriteString(strings.TrimRight(u.EscapedPath(), "/"))
+	}
+	for _, s := range segments {
+		b.WriteString("/" + url.PathEscape(s))
+	}
+	if b.Len() == 0 {
+		return "/"
+	}
+	return b.String()
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"html/template"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFormatDate(t *testing.T) {
+	newTestWiki(t)
+	when := time.Date(2024, time.March, 5, 14, 7, 0, 0, time.UTC)
+	for _, tc := range []struct {
+		layout []string
+		want   string
+	}{
+		{nil, "2024-03-05 14:07"},
+		{[]string{"date"}, "2024-03-05"},
+		{[]string{"2 Jan 2006"}, "5 Mar 2024"},
+	} {
+		if got := formatDate(when, tc.layout...); got != tc.want {
+			t.Errorf("formatDate(%v) = %q, want %q", tc.layout, got, tc.want)
+		}
+	}
+	if got := formatDate(time.Time{}); got != "" {
+		t.Errorf("a zero time is formatted as %q", got)
+	}
+}
+
+func TestRelativeTime(t *testing.T) {
+	newTestWiki(t)
+	old := time.Now().Add(-40 * 24 * time.Hour)
+	for _, tc := range []struct {
+		t    time.Time
+		want string
+	}{
+		{time.Now(), "just now"},
+		{time.Now().Add(-time.Minute - time.Second), "1 minute ago"},
+		{time.Now().Add(-5*time.Minute - time.Second), "5 minutes ago"},
+		{time.Now().Add(-3*time.Hour - time.Second), "3 hours ago"},
+		{time.Now().Add(-2*24*time.Hour - time.Second), "2 days ago"},
+		{old, old.Format("2006-01-02")},
+		{time.Time{}, ""},
+	} {
+		if got := relativeTime(tc.t); got != tc.want {
+			t.Errorf("relativeTime = %q, want %q", got, tc.want)
+		}
+	}
+}
+
+func TestFormatNumber(t *testing.T) {
+	newTestWiki(t)
+	for n, want := range map[int]string{0: "0", 999: "999", 1000: "1,000", 1234567: "1,234,567", -45000: "-45,000"} {
+		if got := formatNumber(n); got != want {
+			t.Errorf("formatNumber(%d) = %q, want %q", n, got, want)
+		}
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	for _, tc := range []struct {
+		n       int
+		s, want string
+	}{
+		{20, "short enough", "short enough"},
+		{12, "short enough", "short enough"},
+		{10, "cut at a word boundary", "cut at a…"},
+		{9, "end of sentence. More", "end of…"},
+		{5, "unbroken", "unbro…"},
+		{3, "äöüß", "äöü…"},
+	} {
+		if got := truncate(tc.n, tc.s); got != tc.want {
+			t.Errorf("truncate(%d, %q) = %q, want %q", tc.n, tc.s, got, tc.want)
+		}
+	}
+
+	// the argument order suits a pipeline
+	tmpl := template.Must(template.New("t").Funcs(templateFuncs).Parse(`{{. | truncate 10}}`))
+	var b strings.Builder
+	if err := tmpl.Execute(&b, "cut at a word boundary"); err != nil {
+		t.Fatal(err)
+	}
+	if got := b.String(); got != "cut at a…" {
+		t.Errorf("pipeline truncated to %q", got)
+	}
+}
+
+func TestURLFor(t *testing.T) {
+	newTestWiki(t)
+	for _, tc := range []struct {
+		base     string
+		segments []string
+		want     string
+	}{
+		{"", nil, "/"},
+		{"", []string{"view", "FrontPage"}, "/view/FrontPage"},
+		{"", []string{"static", "a b/c.js"}, "/static/a%20b%2Fc.js"},
+		{"https://example.com", []string{"view", "FrontPage"}, "/view/FrontPage"},
+		{"https://example.com/wiki/", nil, "/wiki"},
+		{"https://example.com/wiki", []string{"edit", "Notes"}, "/wiki/edit/Notes"},
+	} {
+		setFlag(t, "base-url", tc.base)
+		if got := urlFor(tc.segments...); got != tc.want {
+			t.Errorf("urlFor(%q) under %q = %q, want %q", tc.segments, tc.base, got, tc.want)
+		}
+	}
+}
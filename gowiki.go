@@ -1,58 +1,373 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
 	"html/template"
-	"io/ioutil"
+	"log"
 	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
 	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+	"unicode/utf8"
 )
 
-// templates pre-loads all html templates at startup
-// this will panic if an error occurs and will exit the program
-var templates = template.Must(template.ParseFiles("tmpl/edit.html", "tmpl/view.html"))
-
 // validPath sets regular expression matcher for valid endpoints of our program
 // this is to prevent any file being able to be read/written to our server
-var validPath = regexp.MustCompile("^/(edit|save|view)/([a-zA-Z0-9]+)$")
+var validPath = regexp.MustCompile("^/(edit|save|view|copy|delete|history)/([a-zA-Z0-9]+|[a-z0-9]+(?:-[a-z0-9]+)+)$")
+
+// validTitle matches the titles a Page may be saved under
+var validTitle = regexp.MustCompile("^[a-zA-Z0-9]+$")
+
+// maxTitleLen caps the number of characters allowed in a Page title
+// so that titles cannot produce unwieldy filenames and urls
+var maxTitleLen = flag.Int("max-title-len", 100, "maximum number of characters allowed in a page title")
+
+// deleteEmptyPages deletes a page saved with an empty body rather than keeping
+// it as an empty file, once the editor has confirmed it
+var deleteEmptyPages = flag.Bool("delete-empty-pages", false, "delete pages saved with an empty or whitespace-only body, after asking to confirm, instead of keeping them empty")
+
+// editor settings for the edit form
+var (
+	editRows  = flag.Int("edit-rows", 20, "number of rows in the edit textarea")
+	editCols  = flag.Int("edit-cols", 80, "number of columns in the edit textarea")
+	noToolbar = flag.Bool("no-toolbar", false, "hide the Markdown formatting toolbar on the edit form")
+	noSummary = flag.Bool("no-edit-summary", false, "hide the edit summary field on the edit form")
+)
 
-// rootHandler redirects root path to /view/FrontPage
+// maxSummaryLen caps the length of an edit summary
+const maxSummaryLen = 200
+
+// normalizeNewlines converts CRLF line endings in saved bodies to LF
+var normalizeNewlines = flag.Bool("normalize-newlines", false, "convert CRLF line endings to LF when saving pages")
+
+// trimWhitespace strips trailing whitespace from saved bodies
+var trimWhitespace = flag.Bool("trim-whitespace", false, "strip trailing spaces from lines and end pages with exactly one newline when saving them")
+
+// cleanBody checks that a submitted Page body is valid UTF-8 and applies
+// the configured line ending and whitespace normalization to it
+func cleanBody(body string) ([]byte, error) {
+	if !utf8.ValidString(body) {
+		return nil, errors.New("page body is not valid UTF-8 text")
+	}
+	if *normalizeNewlines {
+		body = strings.ReplaceAll(body, "\r\n", "\n")
+	}
+	if *trimWhitespace {
+		body = trimTrailingSpace(body)
+	}
+	return []byte(body), nil
+}
+
+// trimTrailingSpace removes the spaces and tabs ending each line of body,
+// except within ``` or ~~~ fenced code blocks where they may matter,
+// and makes body end in exactly one newline
+func trimTrailingSpace(body string) string {
+	lines := strings.Split(body, "\n")
+	fence := ""
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case fence == "" && (strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~")):
+			fence = trimmed[:3]
+		case fence != "" && strings.HasPrefix(trimmed, fence):
+			fence = ""
+		case fence != "":
+			continue
+		}
+		cr := strings.HasSuffix(line, "\r")
+		line = strings.TrimRight(strings.TrimSuffix(line, "\r"), " \t")
+		if cr {
+			line += "\r"
+		}
+		lines[i] = line
+	}
+	body = strings.Join(lines, "\n")
+	trimmed := strings.TrimRight(body, "\r\n")
+	if trimmed == "" {
+		return ""
+	}
+	if strings.Contains(body, "\r\n") {
+		return trimmed + "\r\n"
+	}
+	return trimmed + "\n"
+}
+
+// editSummary tidies a submitted edit summary onto a single line of at most
+// maxSummaryLen characters
+func editSummary(s string) string {
+	s = strings.Join(strings.Fields(strings.ToValidUTF8(s, "")), " ")
+	if r := []rune(s); len(r) > maxSummaryLen {
+		s = string(r[:maxSummaryLen])
+	}
+	return s
+}
+
+// validateTitle checks that title is usable as a Page title,
+// returning an error describing the problem if it is not
+func validateTitle(title string) error {
+	if strings.TrimSpace(title) == "" {
+		return errors.New("title must not be empty")
+	}
+	if n := utf8.RuneCountInString(title); n > *maxTitleLen {
+		return fmt.Errorf("title must be at most %d characters long (got %d)", *maxTitleLen, n)
+	}
+	if !validTitle.MatchString(title) {
+		return errors.New("title may only contain letters and digits")
+	}
+	return nil
+}
+
+// rootHandler redirects root path to /view/FrontPage,
+// or shows the blog there in -blog mode
 func rootHandler(w http.ResponseWriter, r *http.Request) {
+	if *blogMode && r.URL.Path == "/" {
+		blogHandler(w, r)
+		return
+	}
 	http.Redirect(w, r, "/view/FrontPage", http.StatusFound)
 }
 
 // viewHandler loads wiki page and renders it in browser
 // via the url pattern: /view/{Page.Title}
 // if the page does not exist, request redirects to edit new Page
+// if the client's copy is current, an HTTP Not Modified response is sent instead
+// with ?changes=1 the diff against the previous version is shown above the page
+// with ?source=1 the page's raw body is shown instead of its rendering
+// pages with a slug are served at /view/{slug}, their title's url redirecting there
+// pages consisting of "#REDIRECT [Target]" redirect to /view/Target unless
+// ?redirect=no or ?source=1 is given, a broken chain of redirects shows the page itself
 func viewHandler(w http.ResponseWriter, r *http.Request, title string) {
+	bySlug := false
+	if t := slugTitle(title); t != "" && !pageExists(title) {
+		title, bySlug = t, true
+	}
 	p, err := loadPage(title)
 	if err != nil {
 		http.Redirect(w, r, "/edit/"+title, http.StatusFound)
 		return
 	}
-	renderTemplate(w, "view", p)
+	if denyView(w, r, p) {
+		return
+	}
+	if p.Meta.expired(time.Now()) {
+		errorHandler(w, r, http.StatusGone, title+" has expired")
+		return
+	}
+	if slug := p.Meta.Slug; !bySlug && slugTitle(slug) == title {
+		u := *r.URL
+		u.Path = "/view/" + slug
+		http.Redirect(w, r, u.String(), http.StatusMovedPermanently)
+		return
+	}
+	source := r.FormValue("source") != ""
+	if redirectTarget(p) != "" && r.FormValue("redirect") != "no" && !source {
+		target, err := resolveRedirect(p)
+		if err == nil {
+			http.Redirect(w, r, "/view/"+target+"?from="+title, http.StatusFound)
+			return
+		}
+		p.Warning = err.Error()
+	}
+	views := 0
+	if *countViews {
+		views = recordView(r, title)
+	}
+	setPageCache(w, p)
+	w.Header().Add("Vary", "Cookie")
+	// the view count changes with every view, so such views are never current
+	if !hasToday(p.Content) && !*countViews && notModified(w, r, p.ModTime, viewETag(r, p)) {
+		return
+	}
+	v := newViewPage(p)
+	v.Views = views
+	v.Source = source
+	v.Backlinks = visiblePages(r, v.Backlinks)
+	if *metaSidebar {
+		v.Info = newPageInfo(v)
+	}
+	if from := r.FormValue("from"); validTitle.MatchString(from) {
+		v.RedirectedFrom = from
+	}
+	prev, ok, err := previousVersion(title, p.Body)
+	if err != nil {
+		errorHandler(w, r, http.StatusInternalServerError, err.Error())
+		return
+	}
+	v.HasPrevious = ok && canViewVersion(r, title, prev)
+	if v.HasPrevious && r.FormValue("changes") != "" {
+		v.Changes = renderDiff(prev, p.Body)
+	}
+	renderTemplate(w, r, "view", v)
+}
+
+// notModified sets the Last-Modified header from modtime, and the ETag header
+// to etag unless it is "", and reports whether r shows the client already
+// has that version, in which case an HTTP Not Modified response has been written
+// with an etag only a matching If-None-Match header counts, as responses that
+// depend on more than modtime may differ for the same If-Modified-Since
+func notModified(w http.ResponseWriter, r *http.Request, modtime time.Time, etag string) bool {
+	if modtime.IsZero() {
+		return false
+	}
+	// HTTP dates have a resolution of one second
+	modtime = modtime.UTC().Truncate(time.Second)
+	w.Header().Set("Last-Modified", modtime.Format(http.TimeFormat))
+	if etag != "" {
+		w.Header().Set("ETag", etag)
+	}
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return false
+	}
+	if etag != "" {
+		if !etagMatches(r.Header.Get("If-None-Match"), etag) {
+			return false
+		}
+	} else if since, err := http.ParseTime(r.Header.Get("If-Modified-Since")); err != nil || modtime.After(since) {
+		return false
+	}
+	w.WriteHeader(http.StatusNotModified)
+	return true
 }
 
 // editHandler provides form to edit and save wiki Page contents
+// if the title is invalid, the form is shown along with the validation error
+// a page that does not exist yet may start with a backlink to ?from=,
+// see -backlink-new-pages
 func editHandler(w http.ResponseWriter, r *http.Request, title string) {
+	if err := validateTitle(title); err != nil {
+		renderTemplateStatus(w, r, "edit", http.StatusBadRequest, newEditPage(r, &Page{Title: title}, err))
+		return
+	}
 	p, err := loadPage(title)
 	if err != nil {
-		p = &Page{Title: title}
+		p = &Page{Title: title, Body: backlinkBody(r.FormValue("from"))}
+	} else if denyView(w, r, p) {
+		return
 	}
-	renderTemplate(w, "edit", p)
+	renderTemplate(w, r, "edit", newEditPage(r, p, nil))
 }
 
 // saveHandler saves Page to disk and redirects to view Page
+// if the title or body is invalid, the edit form is shown again with the
+// submitted body and the validation error instead of saving
+// saves within -edit-cooldown of the previous one get an HTTP Too Many Requests error
+// and bodies over -max-body-size an HTTP Request Entity Too Large error
 func saveHandler(w http.ResponseWriter, r *http.Request, title string) {
-	body := r.FormValue("body")
-	p := &Page{Title: title, Body: []byte(body)}
-	err := p.save()
+	if !parseBody(w, r) {
+		return
+	}
+	raw := r.FormValue("body")
+	body, err := cleanBody(raw)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		p := &Page{Title: title, Body: []byte(strings.ToValidUTF8(raw, "\uFFFD"))}
+		renderTemplateStatus(w, r, "edit", http.StatusBadRequest, newEditPage(r, p, err))
+		return
+	}
+	p := newPage(title, body)
+	p.Summary = editSummary(r.FormValue("summary"))
+	p.Editor = editorName(r)
+	if err := validateTitle(title); err != nil {
+		renderTemplateStatus(w, r, "edit", http.StatusBadRequest, newEditPage(r, p, err))
+		return
+	}
+	if *deleteEmptyPages && strings.TrimSpace(string(body)) == "" {
+		saveEmpty(w, r, p)
+		return
+	}
+	if err := validateSlug(title, p.Meta.Slug); err != nil {
+		renderTemplateStatus(w, r, "edit", http.StatusConflict, newEditPage(r, p, err))
 		return
 	}
+	if coolingDown(w, r, title) || overQuota(w, r) {
+		return
+	}
+	action := auditEdit
+	if old, err := loadPage(title); err != nil {
+		action = auditCreate
+	} else if denyView(w, r, old) {
+		return
+	}
+	if err := p.save(); err != nil {
+		errorHandler(w, r, http.StatusInternalServerError, err.Error())
+		return
+	}
+	recordSave(title)
+	recordEdit(r, time.Now())
+	recordAudit(r, action, title)
+	rememberEditor(w, r, p.Editor)
 	http.Redirect(w, r, "/view/"+title, http.StatusFound)
 }
 
+// saveEmpty handles saving p with an empty body under -delete-empty-pages,
+// which deletes the page once the editor confirms it by saving again from
+// the form shown the first time, and refuses to create a page left empty
+func saveEmpty(w http.ResponseWriter, r *http.Request, p *Page) {
+	if !pageExists(p.Title) {
+		renderTemplateStatus(w, r, "edit", http.StatusBadRequest, newEditPage(r, p, errors.New("an empty page is not saved, add some text to create "+p.Title)))
+		return
+	}
+	if r.FormValue("delete") == "" {
+		e := newEditPage(r, p, errors.New("saving an empty page deletes it, save again to delete "+p.Title+" or add some text to keep it"))
+		e.ConfirmDelete = true
+		renderTemplate(w, r, "edit", e)
+		return
+	}
+	deletePage(w, r, p.Title)
+}
+
+// copyHandler duplicates a Page under the destination title posted as "dest"
+// and redirects to edit the new Page
+// the destination must be a valid title that is not already in use
+func copyHandler(w http.ResponseWriter, r *http.Request, title string) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		errorHandler(w, r, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	p, err := loadPage(title)
+	if err != nil {
+		notFound(w, r)
+		return
+	}
+	if denyView(w, r, p) {
+		return
+	}
+	dest := r.FormValue("dest")
+	if err := validateTitle(dest); err != nil {
+		errorHandler(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	if pageExists(dest) {
+		errorHandler(w, r, http.StatusConflict, "page "+dest+" already exists")
+		return
+	}
+	if err := validateSlug(dest, p.Meta.Slug); err != nil {
+		errorHandler(w, r, http.StatusConflict, err.Error()+", change the slug before copying the page")
+		return
+	}
+	if overQuota(w, r) {
+		return
+	}
+	p.Title = dest
+	p.Editor = editorName(r)
+	if err := p.save(); err != nil {
+		errorHandler(w, r, http.StatusInternalServerError, err.Error())
+		return
+	}
+	recordEdit(r, time.Now())
+	recordAudit(r, auditCreate, dest)
+	http.Redirect(w, r, "/edit/"+dest, http.StatusFound)
+}
+
 // makeHandler consolidates the URL parsing logic to grab Page title
 // and then executes fn with title paramter included
 // if title is invalid or not found, an HTTP Not Found error is returned
@@ -60,7 +375,7 @@ func makeHandler(fn func(w http.ResponseWriter, r *http.Request, title string))
 	return func(w http.ResponseWriter, r *http.Request) {
 		m := validPath.FindStringSubmatch(r.URL.Path)
 		if m == nil {
-			http.NotFound(w, r)
+			notFound(w, r)
 			return
 		}
 		fn(w, r, m[2])
@@ -68,44 +383,387 @@ func makeHandler(fn func(w http.ResponseWriter, r *http.Request, title string))
 }
 
 // renderTemplate consolidates processing involved with template rendering
-// by executing provided Page on '{{tmpl}}.html' and writing to http response
-func renderTemplate(w http.ResponseWriter, tmpl string, p *Page) {
-	err := templates.ExecuteTemplate(w, tmpl+".html", p)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+// by executing provided data on '{{tmpl}}.html' of the request's theme
+// and writing to http response
+func renderTemplate(w http.ResponseWriter, r *http.Request, tmpl string, data interface{}) {
+	renderTemplateStatus(w, r, tmpl, http.StatusOK, data)
+}
+
+// renderTemplateStatus is renderTemplate with an explicit http status code
+// the template is rendered into a buffer first so that a failed render
+// can still be reported as an HTTP Internal Server Error
+// output beyond -stream-threshold is streamed instead, and a render failing
+// after that can only be logged, the client getting a truncated page
+func renderTemplateStatus(w http.ResponseWriter, r *http.Request, tmpl string, status int, data interface{}) {
+	t := requestTemplate(w, r, tmpl+".html")
+	if t == nil {
+		errorHandler(w, r, http.StatusInternalServerError, "template "+tmpl+" not found")
 		return
 	}
+	sw := newStreamWriter(w, status)
+	err := t.Execute(sw, data)
+	switch {
+	case err != nil && sw.streaming:
+		log.Printf("rendering %s after streaming began: %v", tmpl, err)
+	case err != nil:
+		errorHandler(w, r, http.StatusInternalServerError, err.Error())
+	default:
+		sw.close()
+	}
 }
 
 // Page represents a standard, interconnected wiki page
-// consisting of a title and body (the page content)
+// consisting of a title and body (the page source)
+// Meta, Content and Warning are derived from the body's optional frontmatter,
+// Content being the body without it
+// ModTime is when the Page was last saved, zero for pages not loaded from disk
+// Summary is the edit summary given when saving, kept with the saved version
+// Editor is who saved it, "" if that is not known
 type Page struct {
-	Title string
-	Body  []byte
+	Title   string
+	Body    []byte
+	Meta    pageMeta
+	Content []byte
+	Warning string
+	ModTime time.Time
+	Summary string
+	Editor  string
+}
+
+// newPage constructs a Page from its title and body, parsing any frontmatter
+func newPage(title string, body []byte) *Page {
+	meta, content, warning := parseFrontmatter(body)
+	return &Page{Title: title, Body: body, Meta: meta, Content: content, Warning: warning}
+}
+
+// DisplayTitle is the title shown for the Page,
+// the frontmatter title if it has one and its Title otherwise
+func (p *Page) DisplayTitle() string {
+	if p.Meta.Title != "" {
+		return p.Meta.Title
+	}
+	return p.Title
+}
+
+// viewPage wraps a Page with its rendered body, table of contents,
+// the pages it links to and the pages linking to it
+// Views is the page's view count, zero when views are not counted
+// HasPrevious reports whether there is an earlier version to compare with
+// and Changes holds the diff against it when it was asked for
+// RedirectedFrom is the redirect page the reader came through, if any
+// Source shows the page's raw body in place of its rendering
+type viewPage struct {
+	*Page
+	HTML           template.HTML
+	TOC            []heading
+	Links          []pageLink
+	Backlinks      []string
+	Views          int
+	HasPrevious    bool
+	Changes        template.HTML
+	RedirectedFrom string
+	Math           bool
+	HeadingLinks   bool
+	Tasks          bool
+	Info           *pageInfo
+	Source         bool
+}
+
+// newViewPage renders p for display
+func newViewPage(p *Page) *viewPage {
+	v := &viewPage{
+		Page:      p,
+		HTML:      renderBody(p.Title, p.Content),
+		TOC:       pageHeadings(p.Content),
+		Links:     pageLinks(p.Content),
+		Backlinks: backlinks(p.Title),
+		Math:      *enableMath,
+	}
+	v.HeadingLinks = !*noHeadingLinks && len(v.TOC) > 0
+	v.Tasks = hasTasks(v.HTML)
+	return v
+}
+
+// editPage wraps a Page with the extra state needed by the edit form,
+// such as a validation error to display above the textarea
+// and the configured editor settings
+// ConfirmDelete asks the editor to confirm deleting the page by saving it empty
+type editPage struct {
+	*Page
+	Error         string
+	Rows          int
+	Cols          int
+	Toolbar       bool
+	PasteMarkdown bool
+	EditSummary   bool
+	AskName       bool
+	Editor        string
+	ConfirmDelete bool
+}
+
+// newEditPage prepares p for the edit form using the configured editor settings,
+// err is shown to the user if it is not nil
+// anonymous editors, those r does not authenticate, are asked for their name
+func newEditPage(r *http.Request, p *Page, err error) *editPage {
+	e := &editPage{Page: p, Rows: *editRows, Cols: *editCols, Toolbar: !*noToolbar, PasteMarkdown: !*noPasteMarkdown, EditSummary: !*noSummary}
+	if signedInUser(r) == "" {
+		e.AskName = true
+		e.Editor = rememberedEditor(r)
+	}
+	if err != nil {
+		e.Error = err.Error()
+	}
+	return e
+}
+
+// pageLocks holds a mutex per Page title serializing writes to its file
+var pageLocks = struct {
+	sync.Mutex
+	m map[string]*sync.Mutex
+}{m: map[string]*sync.Mutex{}}
+
+// lockPage locks title for writing and returns the function unlocking it
+// callers doing a read-modify-write of a Page hold it across the whole
+// operation and use write rather than save
+func lockPage(title string) func() {
+	pageLocks.Lock()
+	mu, ok := pageLocks.m[title]
+	if !ok {
+		mu = &sync.Mutex{}
+		pageLocks.m[title] = mu
+	}
+	pageLocks.Unlock()
+	mu.Lock()
+	return mu.Unlock
 }
 
 // save creates/updates a .txt file, named after this Page's Title
-// and puts its Body as the file contents
+// and puts its Body as the file contents, gzipped with -compress-pages
 func (p *Page) save() error {
-	filename := "data/" + p.Title + ".txt"
-	return ioutil.WriteFile(filename, p.Body, 0600)
+	unlock := lockPage(p.Title)
+	defer unlock()
+	return p.write()
+}
+
+// write is save for callers already holding the Page's lock,
+// it also updates the in-memory indexes to match the new Body
+// and records it in the Page's history
+func (p *Page) write() error {
+	if err := writePageFile(p.Title, p.Body); err != nil {
+		return err
+	}
+	if err := writeEditor(p.Title, p.Editor); err != nil {
+		return err
+	}
+	indexPage(p)
+	return saveVersion(p.Title, p.Body, p.Summary, p.Editor)
+}
+
+// removePage deletes title from disk and from the index while the caller
+// holds its lock, the saved versions of the page are kept in its history
+func removePage(title string) error {
+	if err := os.Remove(pageFile(title)); err != nil {
+		return err
+	}
+	if err := writeEditor(title, ""); err != nil {
+		return err
+	}
+	unindexPage(title)
+	return nil
+}
+
+// pageExists reports whether a Page with the given title has been saved
+func pageExists(title string) bool {
+	_, err := os.Stat(pageFile(title))
+	return err == nil
 }
 
-// loadPage constructs a .txt file name from the provided title,
-// and loads the contents of that file (along with the title) into a Page
+// listPages returns the titles of all saved pages, sorted
+func listPages() ([]string, error) {
+	files, err := filepath.Glob(pageFilePattern())
+	if err != nil {
+		return nil, err
+	}
+	var titles []string
+	seen := map[string]bool{}
+	for _, f := range files {
+		if title, ok := pageTitle(filepath.Base(f)); ok && !seen[title] {
+			seen[title] = true
+			titles = append(titles, title)
+		}
+	}
+	sort.Strings(titles)
+	return titles, nil
+}
+
+// loadPage finds the .txt or .txt.gz file for the provided title,
+// and loads the contents of that file (along with the title
+// and the time it was last modified) into a Page
 func loadPage(title string) (*Page, error) {
-	filename := "data/" + title + ".txt"
-	body, err := ioutil.ReadFile(filename)
+	filename := pageFile(title)
+	fi, err := os.Stat(filename)
 	if err != nil {
 		return nil, err
 	}
-	return &Page{Title: title, Body: body}, nil
+	body, err := readPageFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	p := newPage(title, body)
+	p.ModTime = fi.ModTime()
+	p.Editor = readEditor(title)
+	return p, nil
 }
 
 func main() {
-	http.HandleFunc("/", rootHandler)
-	http.HandleFunc("/view/", makeHandler(viewHandler))
-	http.HandleFunc("/edit/", makeHandler(editHandler))
-	http.HandleFunc("/save/", makeHandler(saveHandler))
-	http.ListenAndServe(":8080", nil)
+	flag.Parse()
+	if _, ok := templates[*defaultTheme]; !ok {
+		log.Fatalf("unknown theme %q", *defaultTheme)
+	}
+	if err := checkHTMLPolicy(); err != nil {
+		log.Fatal(err)
+	}
+	if err := checkLocale(); err != nil {
+		log.Fatal(err)
+	}
+	if err := checkBaseURL(); err != nil {
+		log.Fatal(err)
+	}
+	if err := loadBundles(); err != nil {
+		log.Fatal(err)
+	}
+	if *redirectsFile != "" {
+		if _, err := reloadRedirects(); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if *apiTokensFile != "" {
+		if err := loadAPITokens(); err != nil {
+			log.Fatal(err)
+		}
+	}
+	checkReadOnly()
+	// the backup comes before anything below writes to data/, so that it
+	// can undo a bad migration or seeding
+	if *backupOnStart {
+		path, err := snapshot()
+		if err != nil {
+			log.Fatalf("backup: %v", err)
+		}
+		log.Printf("backed up data to %s", path)
+	}
+	if *migratePages {
+		if isReadOnly() {
+			log.Fatal("cannot migrate pages while read-only")
+		}
+		n, err := migratePageFiles()
+		if err != nil {
+			log.Fatalf("migrating pages: %v", err)
+		}
+		log.Printf("moved %d pages into the %s layout", n, map[bool]string{false: "flat", true: "sharded"}[*shardPages])
+	}
+	if *helpPages && !isReadOnly() {
+		if err := seedHelpPages(); err != nil {
+			log.Fatalf("help pages: %v", err)
+		}
+	}
+	if _, err := rebuildIndex(); err != nil {
+		log.Fatal(err)
+	}
+	if err := loadViews(); err != nil {
+		log.Fatal(err)
+	}
+	if flag.Arg(0) == "publish" {
+		summary, err := publish()
+		if err != nil {
+			log.Fatal(err)
+		}
+		log.Printf("published %d files to %s (%d unchanged)", summary.Written, summary.Dir, summary.Unchanged)
+		return
+	}
+	if *devMode {
+		log.Printf("warning: -dev is set, /debug/ endpoints are exposed")
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	// background jobs run until shutdown, which waits for them to finish
+	var jobs sync.WaitGroup
+	for _, job := range []func(context.Context){runArchiver, runExpirer, runViewFlusher, runNotifier, runRedirectReloader, runVacuumer, runJobs} {
+		jobs.Add(1)
+		go func(job func(context.Context)) {
+			defer jobs.Done()
+			job(ctx)
+		}(job)
+	}
+
+	l, err := listen(*addr)
+	if err != nil {
+		log.Fatal(err)
+	}
+	serve(ctx, &http.Server{Handler: newHandler()}, l)
+	jobs.Wait()
+}
+
+// newHandler is the wiki's handler: every route of newServeMux behind the
+// middleware that applies to all requests
+func newHandler() http.Handler {
+	return withRequestID(redirectPaths(limitConcurrency(*maxConcurrent, duringMaintenance(overrideMethod(newServeMux())))))
+}
+
+// newServeMux registers the wiki's routes, those behind flags only when
+// the flags are set
+func newServeMux() *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", rootHandler)
+	mux.HandleFunc("/healthz", healthHandler)
+	if *enablePWA {
+		mux.HandleFunc("/manifest.webmanifest", manifestHandler)
+		mux.HandleFunc("/sw.js", serviceWorkerHandler)
+	}
+	mux.Handle("/static/", staticHandler())
+	mux.HandleFunc("/view/", makeHandler(viewHandler))
+	mux.HandleFunc("/edit/", requireWritable(requireEditNetwork(requireSession(makeHandler(editHandler)))))
+	mux.HandleFunc("/save/", requireWritable(requireEditNetwork(requireSession(makeHandler(saveHandler)))))
+	mux.HandleFunc("/copy/", requireWritable(requireEditNetwork(requireSession(makeHandler(copyHandler)))))
+	mux.HandleFunc("/delete/", requireWritable(requireEditNetwork(requireSession(makeHandler(deleteHandler)))))
+	mux.HandleFunc("/import-url", requireWritable(requireEditNetwork(requireSession(importURLHandler))))
+	mux.HandleFunc("/task/", requireWritable(requireEditNetwork(requireSession(taskHandler))))
+	mux.HandleFunc("/render", requireToken(scopeRead, renderHandler))
+	mux.HandleFunc("/convert/html-to-markdown", requireEditNetwork(requireSession(convertHandler)))
+	mux.HandleFunc("/history/", makeHandler(historyHandler))
+	mux.HandleFunc("/login", loginHandler)
+	mux.HandleFunc("/logout", logoutHandler)
+	mux.HandleFunc("/recent", recentPageHandler)
+	mux.HandleFunc("/prefix/", prefixHandler)
+	mux.HandleFunc("/export/", exportHandler)
+	mux.HandleFunc("/book", bookHandler)
+	mux.HandleFunc("/book/", bookHandler)
+	mux.HandleFunc("/jobs/", jobsHandler)
+	mux.HandleFunc("/embed/", embedHandler)
+	if *devMode {
+		mux.HandleFunc("/debug/pagedata/", pageDataHandler)
+	}
+	if *statsJSON {
+		mux.HandleFunc("/stats.json", requireToken(scopeRead, statsHandler))
+	}
+	mux.HandleFunc("/api/outline/", requireToken(scopeRead, outlineHandler))
+	mux.HandleFunc("/api/meta/", requireToken(scopeRead, metaHandler))
+	mux.HandleFunc("/api/recent", requireToken(scopeRead, recentHandler))
+	mux.HandleFunc("/api/slug", requireToken(scopeRead, slugHandler))
+	mux.HandleFunc("/api/history/", historyAPIHandler)
+	mux.HandleFunc("/admin/audit", requireAdmin(auditHandler))
+	mux.HandleFunc("/admin/rebuild", requireAdmin(rebuildHandler))
+	mux.HandleFunc("/admin/publish", requireAdmin(publishHandler))
+	mux.HandleFunc("/admin/popular", requireAdmin(popularHandler))
+	mux.HandleFunc("/admin/replace", requireAdmin(requireWritable(replaceHandler)))
+	mux.HandleFunc("/admin/merge", requireAdmin(requireWritable(mergeHandler)))
+	mux.HandleFunc("/admin/vacuum", requireAdmin(requireWritable(vacuumHandler)))
+	mux.HandleFunc("/admin/maintenance", requireAdmin(maintenanceHandler))
+	mux.HandleFunc("/admin/banner", requireAdmin(bannerHandler))
+	mux.HandleFunc("/admin/redirects", requireAdmin(redirectsHandler))
+	mux.HandleFunc("/admin/linkcheck", requireAdmin(linkcheckHandler))
+	mux.HandleFunc("/admin/duplicates", requireAdmin(duplicatesHandler))
+	mux.HandleFunc("/admin/backup", requireAdmin(backupHandler))
+	return mux
 }
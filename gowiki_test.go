@@ -0,0 +1,316 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// srcDir is the package directory, which holds the templates, static files
+// and help pages the tests' wikis share
+var srcDir string
+
+func TestMain(m *testing.M) {
+	var err error
+	if srcDir, err = os.Getwd(); err != nil {
+		log.Fatal(err)
+	}
+	if err := loadBundles(); err != nil {
+		log.Fatal(err)
+	}
+	os.Exit(m.Run())
+}
+
+// newTestWiki runs the rest of the test in an empty wiki of its own, with
+// flags given as "name=value" set until the test ends, and returns its handler
+func newTestWiki(t testing.TB, flags ...string) http.Handler {
+	t.Helper()
+	dir := t.TempDir()
+	for _, name := range []string{"static", "help"} {
+		if err := os.Symlink(filepath.Join(srcDir, name), filepath.Join(dir, name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Mkdir(filepath.Join(dir, "data"), 0700); err != nil {
+		t.Fatal(err)
+	}
+	t.Chdir(dir)
+	for _, f := range flags {
+		name, value, _ := strings.Cut(f, "=")
+		setFlag(t, name, value)
+	}
+
+	lastSaves.Lock()
+	lastSaves.at = map[string]time.Time{}
+	lastSaves.Unlock()
+	editCounts.Lock()
+	editCounts.day, editCounts.counts = "", map[string]int{}
+	editCounts.Unlock()
+	viewCounts.Lock()
+	viewCounts.counts = map[string]int{}
+	viewCounts.Unlock()
+	statsCache.Lock()
+	statsCache.doc = nil
+	statsCache.Unlock()
+	apiTokens = nil
+	t.Cleanup(func() { apiTokens = nil })
+
+	if _, err := rebuildIndex(); err != nil {
+		t.Fatal(err)
+	}
+	return newHandler()
+}
+
+// setFlag sets the flag name to value until the test ends
+func setFlag(t testing.TB, name, value string) {
+	t.Helper()
+	f := flag.Lookup(name)
+	if f == nil {
+		t.Fatalf("no flag -%s", name)
+	}
+	old := f.Value.String()
+	if err := f.Value.Set(value); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { f.Value.Set(old) })
+}
+
+// writePage saves body as title, failing the test if it cannot
+func writePage(t testing.TB, title, body string) {
+	t.Helper()
+	if err := newPage(title, []byte(body)).save(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// readPage returns the saved body of title, "" if there is none
+func readPage(t *testing.T, title string) string {
+	t.Helper()
+	p, err := loadPage(title)
+	if err != nil {
+		return ""
+	}
+	return string(p.Body)
+}
+
+// do runs r through h and returns the response
+func do(h http.Handler, r *http.Request) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
+
+// get is a GET request for target
+func get(target string) *http.Request {
+	return httptest.NewRequest(http.MethodGet, target, nil)
+}
+
+// postForm is a POST request of form to target, as an HTML form sends it
+func postForm(target string, form url.Values) *http.Request {
+	r := httptest.NewRequest(http.MethodPost, target, strings.NewReader(form.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return r
+}
+
+// asUser makes r carry a session of user, which needs -session-secret set
+func asUser(r *http.Request, user string) *http.Request {
+	r.AddCookie(&http.Cookie{Name: sessionCookie, Value: signSession(user, time.Now().Add(time.Hour))})
+	return r
+}
+
+// asAdmin makes r carry the admin's Basic Auth credentials
+func asAdmin(r *http.Request) *http.Request {
+	r.SetBasicAuth(*adminUser, *adminPass)
+	return r
+}
+
+// wantStatus fails the test unless w has the status code want
+func wantStatus(t *testing.T, w *httptest.ResponseRecorder, want int) {
+	t.Helper()
+	if w.Code != want {
+		t.Fatalf("status %d, want %d: %s", w.Code, want, strings.TrimSpace(w.Body.String()))
+	}
+}
+
+func TestValidateTitle(t *testing.T) {
+	newTestWiki(t)
+	for _, tc := range []struct {
+		title string
+		ok    bool
+	}{
+		{"", false},
+		{"   ", false},
+		{"\t\n", false},
+		{"A", true},
+		{strings.Repeat("a", 100), true},
+		{strings.Repeat("a", 101), false},
+		{"Two Words", false},
+	} {
+		if err := validateTitle(tc.title); (err == nil) != tc.ok {
+			t.Errorf("validateTitle(%q) = %v", tc.title, err)
+		}
+	}
+	setFlag(t, "max-title-len", "5")
+	if validateTitle("Short") != nil || validateTitle("Longer") == nil {
+		t.Error("-max-title-len 5 does not allow exactly 5 characters")
+	}
+}
+
+func TestTitleErrorsShowInEditForm(t *testing.T) {
+	h := newTestWiki(t)
+	long := strings.Repeat("a", 101)
+	for _, r := range []*http.Request{
+		get("/edit/" + long),
+		postForm("/save/"+long, url.Values{"body": {"text"}}),
+	} {
+		w := do(h, r)
+		wantStatus(t, w, http.StatusBadRequest)
+		if want := "title must be at most 100 characters long (got 101)"; !strings.Contains(w.Body.String(), want) || !strings.Contains(w.Body.String(), `<textarea`) {
+			t.Errorf("%s %s does not show the edit form with %q:\n%s", r.Method, r.URL.Path, want, w.Body)
+		}
+	}
+	if pageExists(long) {
+		t.Error("a page with an overlong title was saved")
+	}
+	wantStatus(t, do(h, get("/edit/"+strings.Repeat("a", 100))), http.StatusOK)
+	wantStatus(t, do(h, postForm("/save/"+strings.Repeat("a", 100), url.Values{"body": {"text"}})), http.StatusFound)
+}
+
+func TestCopyPage(t *testing.T) {
+	h := newTestWiki(t)
+	writePage(t, "Source", "the text")
+	writePage(t, "Taken", "other text")
+
+	w := do(h, postForm("/copy/Source", url.Values{"dest": {"Copy"}}))
+	wantStatus(t, w, http.StatusFound)
+	if loc := w.Header().Get("Location"); loc != "/edit/Copy" {
+		t.Errorf("copy redirects to %q, want /edit/Copy", loc)
+	}
+	if got := readPage(t, "Copy"); got != "the text" {
+		t.Errorf("copied body %q", got)
+	}
+
+	wantStatus(t, do(h, postForm("/copy/Source", url.Values{"dest": {"Taken"}})), http.StatusConflict)
+	if got := readPage(t, "Taken"); got != "other text" {
+		t.Errorf("copying over an existing page changed it to %q", got)
+	}
+	wantStatus(t, do(h, postForm("/copy/Source", url.Values{"dest": {"Not valid"}})), http.StatusBadRequest)
+	wantStatus(t, do(h, postForm("/copy/Missing", url.Values{"dest": {"Other"}})), http.StatusNotFound)
+	wantStatus(t, do(h, get("/copy/Source")), http.StatusMethodNotAllowed)
+}
+
+func TestSaveRejectsInvalidUTF8(t *testing.T) {
+	h := newTestWiki(t)
+	w := do(h, postForm("/save/Notes", url.Values{"body": {"bad \xff\xfe bytes"}}))
+	wantStatus(t, w, http.StatusBadRequest)
+	if body := w.Body.String(); !strings.Contains(body, "page body is not valid UTF-8 text") || !strings.Contains(body, "bad \uFFFD bytes") {
+		t.Errorf("edit form does not show the error and the sanitized body:\n%s", body)
+	}
+	if pageExists("Notes") {
+		t.Error("a body of invalid UTF-8 was saved")
+	}
+}
+
+func TestSaveLineEndings(t *testing.T) {
+	h := newTestWiki(t)
+	mixed := "one\r\ntwo\nthree\r\n"
+	wantStatus(t, do(h, postForm("/save/Kept", url.Values{"body": {mixed}})), http.StatusFound)
+	if got := readPage(t, "Kept"); got != mixed {
+		t.Errorf("body %q saved as %q without -normalize-newlines", mixed, got)
+	}
+	setFlag(t, "normalize-newlines", "true")
+	wantStatus(t, do(h, postForm("/save/Normal", url.Values{"body": {mixed}})), http.StatusFound)
+	if got := readPage(t, "Normal"); got != "one\ntwo\nthree\n" {
+		t.Errorf("body %q saved as %q under -normalize-newlines", mixed, got)
+	}
+}
+
+func TestSaveEmptyDeletesAfterConfirming(t *testing.T) {
+	h := newTestWiki(t, "delete-empty-pages=true")
+	writePage(t, "Notes", "text")
+
+	w := do(h, postForm("/save/Notes", url.Values{"body": {" \r\n "}}))
+	wantStatus(t, w, http.StatusOK)
+	if body := w.Body.String(); !strings.Contains(body, "saving an empty page deletes it") || !strings.Contains(body, `name="delete"`) {
+		t.Errorf("empty save does not ask to confirm deleting:\n%s", body)
+	}
+	if got := readPage(t, "Notes"); got != "text" {
+		t.Fatalf("an unconfirmed empty save changed the page to %q", got)
+	}
+
+	w = do(h, postForm("/save/Notes", url.Values{"body": {""}, "delete": {"1"}}))
+	wantStatus(t, w, http.StatusSeeOther)
+	if pageExists("Notes") {
+		t.Error("a confirmed empty save did not delete the page")
+	}
+
+	w = do(h, postForm("/save/Blank", url.Values{"body": {""}, "delete": {"1"}}))
+	wantStatus(t, w, http.StatusBadRequest)
+	if pageExists("Blank") {
+		t.Error("an empty page was created")
+	}
+}
+
+func TestSaveEmptyKeepsPage(t *testing.T) {
+	h := newTestWiki(t)
+	writePage(t, "Notes", "text")
+	wantStatus(t, do(h, postForm("/save/Notes", url.Values{"body": {""}, "delete": {"1"}})), http.StatusFound)
+	if !pageExists("Notes") || readPage(t, "Notes") != "" {
+		t.Errorf("without -delete-empty-pages an empty save left %q, exists %v", readPage(t, "Notes"), pageExists("Notes"))
+	}
+}
+
+func TestSaveTrimsWhitespace(t *testing.T) {
+	h := newTestWiki(t, "trim-whitespace=true")
+	for _, tc := range []struct {
+		name, body, want string
+	}{
+		{"trailing spaces", "one  \ntwo\t\nthree \n\n\n", "one\ntwo\nthree\n"},
+		{"no final newline", "text ", "text\n"},
+		{"crlf", "one \r\ntwo\t\r\n", "one\r\ntwo\r\n"},
+		{"fenced code", "```\ncode  \n  indented\t\n```  \n~~~\n~~~ \nafter ", "```\ncode  \n  indented\t\n```\n~~~\n~~~\nafter\n"},
+		{"other fence inside", "~~~\n```  \nstill code  \n~~~\nprose  ", "~~~\n```  \nstill code  \n~~~\nprose\n"},
+	} {
+		wantStatus(t, do(h, postForm("/save/Notes", url.Values{"body": {tc.body}})), http.StatusFound)
+		if got := readPage(t, "Notes"); got != tc.want {
+			t.Errorf("%s: saved %q as %q, want %q", tc.name, tc.body, got, tc.want)
+		}
+	}
+	setFlag(t, "trim-whitespace", "false")
+	wantStatus(t, do(h, postForm("/save/Notes", url.Values{"body": {"kept  \n\n"}})), http.StatusFound)
+	if got := readPage(t, "Notes"); got != "kept  \n\n" {
+		t.Errorf("without -trim-whitespace saved %q", got)
+	}
+}
+
+func TestViewSource(t *testing.T) {
+	body := "---\ntitle: Danger\n---\n<script>alert(\"hi\")</script>\n**bold** & [Other]"
+	for _, theme := range []string{"classic", "modern"} {
+		h := newTestWiki(t, "theme="+theme)
+		writePage(t, "Notes", body)
+		w := do(h, get("/view/Notes?source=1"))
+		wantStatus(t, w, http.StatusOK)
+		got := w.Body.String()
+		want := `<pre class="source">---
+title: Danger
+---
+&lt;script&gt;alert(&#34;hi&#34;)&lt;/script&gt;
+**bold** &amp; [Other]</pre>`
+		if !strings.Contains(got, want) {
+			t.Errorf("%s: source view lacks\n%s\nin\n%s", theme, want, got)
+		}
+		if strings.Contains(got, "<script>alert") || strings.Contains(got, "<strong>bold</strong>") {
+			t.Errorf("%s: source view renders the body", theme)
+		}
+		if !strings.Contains(got, `<a href="/view/Notes">rendered</a>`) {
+			t.Errorf("%s: source view does not link to the rendered page", theme)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"flag"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// helpPages turns on creating the pages in help/ at startup, which document
+// the markup for new users and give them a SandBox to try it in
+var helpPages = flag.Bool("help-pages", false, "create the Help, SandBox and FormattingGuide pages from help/ at startup if they were never created before")
+
+// help page locations: the default content of each page is help/{title}.txt,
+// and the titles already created once are listed in helpMarker so that
+// pages deleted since are not brought back
+const (
+	helpDir    = "help"
+	helpMarker = "data/.help-pages"
+)
+
+// seedHelpPages creates every help page that has never been created before,
+// leaving pages that already exist as they are
+func seedHelpPages() error {
+	files, err := filepath.Glob(filepath.Join(helpDir, "*"+plainExt))
+	if err != nil {
+		return err
+	}
+	seeded := map[string]bool{}
+	if data, err := ioutil.ReadFile(helpMarker); err == nil {
+		for _, title := range strings.Fields(string(data)) {
+			seeded[title] = true
+		}
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+
+	var created []string
+	for _, file := range files {
+		title := strings.TrimSuffix(filepath.Base(file), plainExt)
+		if seeded[title] || !validTitle.MatchString(title) {
+			continue
+		}
+		seeded[title] = true
+		if pageExists(title) {
+			continue
+		}
+		body, err := ioutil.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		p := newPage(title, body)
+		p.Summary = "created the help pages"
+		if err := p.save(); err != nil {
+			return err
+		}
+		created = append(created, title)
+	}
+	if len(created) > 0 {
+		log.Printf("created help pages %s", strings.Join(created, ", "))
+	}
+
+	var titles []string
+	for title := range seeded {
+		titles = append(titles, title)
+	}
+	sort.Strings(titles)
+	return ioutil.WriteFile(helpMarker, []byte(strings.Join(titles, "\n")+"\n"), 0600)
+}
@@ -0,0 +1,301 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// keepHistory controls whether every save is also kept as a version in history/
+var keepHistory = flag.Bool("history", true, "keep every saved version of a page under data/history/")
+
+// thresholds below which a save updates the page without adding a version to
+// its history, so that history is not swamped by a stream of trivial edits
+// sizes are measured against the newest version in the history
+var (
+	historyMinLines    = flag.Int("history-min-lines", 0, "minimum number of changed lines for a save to be kept in history (0 = no minimum)")
+	historyMinChars    = flag.Int("history-min-chars", 0, "minimum number of changed characters for a save to be kept in history (0 = no minimum)")
+	historyMinInterval = flag.Duration("history-min-interval", 0, "minimum time between two versions of a page in history (0 = no minimum)")
+)
+
+// historyCompactWindow folds a run of quick saves by one editor into a single
+// version, the newest, so that saving repeatedly while editing does not
+// bury the versions around it
+var historyCompactWindow = flag.Duration("history-compact-window", 0, "time within which a save by the same editor replaces their previous version in history instead of adding one (0 = keep every version)")
+
+// historyDir holds one subdirectory per page title with a file per saved version
+const historyDir = "data/history"
+
+// version is one saved version of a Page, ID is its unix nanosecond timestamp
+// an edit summary given with the version is kept next to it in {ID}.summary
+type version struct {
+	ID      string    `json:"id"`
+	Time    time.Time `json:"time"`
+	Size    int64     `json:"size"`
+	Summary string    `json:"summary"`
+	Editor  string    `json:"editor"`
+}
+
+// versionPath returns the file holding version id of title
+func versionPath(title, id string) string {
+	return filepath.Join(historyDir, title, id+".txt")
+}
+
+// summaryPath returns the file holding the edit summary of version id of title
+func summaryPath(title, id string) string {
+	return filepath.Join(historyDir, title, id+".summary")
+}
+
+// versionEditorPath returns the file holding who saved version id of title
+func versionEditorPath(title, id string) string {
+	return filepath.Join(historyDir, title, id+".editor")
+}
+
+// latestPath returns the file naming the newest version of title,
+// a pointer that tools can follow without racing the listing of versions
+func latestPath(title string) string {
+	return filepath.Join(historyDir, title, "latest")
+}
+
+// saveVersion stores body as the newest version of title,
+// along with its edit summary and editor if there are any
+// unless the change is significant by the -history-min-* thresholds
+// nothing is stored, though an edit summary always gets its version
+// the new version is written before any version it supersedes under
+// -history-compact-window is removed, so a failed save loses nothing
+func saveVersion(title string, body []byte, summary, editor string) error {
+	if !*keepHistory {
+		return nil
+	}
+	if summary == "" {
+		ok, err := significant(title, body, time.Now())
+		if err != nil || !ok {
+			return err
+		}
+	}
+	if err := os.MkdirAll(filepath.Join(historyDir, title), 0700); err != nil {
+		return err
+	}
+	now := time.Now()
+	superseded, err := supersededVersion(title, editor, now)
+	if err != nil {
+		return err
+	}
+	id := strconv.FormatInt(now.UnixNano(), 10)
+	if summary != "" {
+		if err := ioutil.WriteFile(summaryPath(title, id), []byte(summary), 0600); err != nil {
+			return err
+		}
+	}
+	if editor != "" {
+		if err := ioutil.WriteFile(versionEditorPath(title, id), []byte(editor), 0600); err != nil {
+			return err
+		}
+	}
+	if err := ioutil.WriteFile(versionPath(title, id), body, 0600); err != nil {
+		return err
+	}
+	tmp := latestPath(title) + ".tmp"
+	if err := ioutil.WriteFile(tmp, []byte(id), 0600); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, latestPath(title)); err != nil {
+		return err
+	}
+	if superseded == "" {
+		return nil
+	}
+	return removeVersion(title, superseded)
+}
+
+// supersededVersion returns the id of the newest version of title if a save
+// by editor at now replaces it under -history-compact-window, "" otherwise
+// only a version saved by the same known editor within the window is
+// replaced, and never the first version of a page or one given an edit
+// summary, as those mark points in the history worth keeping
+func supersededVersion(title, editor string, now time.Time) (string, error) {
+	if *historyCompactWindow <= 0 || editor == "" {
+		return "", nil
+	}
+	vs, err := versions(title)
+	if err != nil || len(vs) < 2 {
+		return "", err
+	}
+	last := vs[len(vs)-1]
+	if last.Editor != editor || last.Summary != "" || now.Sub(last.Time) >= *historyCompactWindow {
+		return "", nil
+	}
+	return last.ID, nil
+}
+
+// removeVersion deletes version id of title together with its summary and editor
+func removeVersion(title, id string) error {
+	for _, path := range []string{versionPath(title, id), summaryPath(title, id), versionEditorPath(title, id)} {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// latestVersion returns the id of the newest version of title, "" if it has none
+// histories saved before the latest pointer existed, or whose newest version
+// has since been removed, fall back to the newest version listed
+func latestVersion(title string) (string, error) {
+	if id, err := ioutil.ReadFile(latestPath(title)); err == nil {
+		if _, err := os.Stat(versionPath(title, string(id))); err == nil {
+			return string(id), nil
+		}
+	}
+	vs, err := versions(title)
+	if err != nil || len(vs) == 0 {
+		return "", err
+	}
+	return vs[len(vs)-1].ID, nil
+}
+
+// significant reports whether saving body at now differs enough from
+// the newest version of title to be kept as a version of its own
+func significant(title string, body []byte, now time.Time) (bool, error) {
+	if *historyMinLines <= 0 && *historyMinChars <= 0 && *historyMinInterval <= 0 {
+		return true, nil
+	}
+	vs, err := versions(title)
+	if err != nil || len(vs) == 0 {
+		return true, err
+	}
+	last := vs[len(vs)-1]
+	if *historyMinInterval > 0 && now.Sub(last.Time) < *historyMinInterval {
+		return false, nil
+	}
+	if *historyMinLines <= 0 && *historyMinChars <= 0 {
+		return true, nil
+	}
+	old, err := loadVersion(title, last.ID)
+	if err != nil {
+		return false, err
+	}
+	lines, chars := diffSize(old, body)
+	return *historyMinLines > 0 && lines >= *historyMinLines || *historyMinChars > 0 && chars >= *historyMinChars, nil
+}
+
+// versions lists the saved versions of title, oldest first
+func versions(title string) ([]version, error) {
+	files, err := ioutil.ReadDir(filepath.Join(historyDir, title))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var vs []version
+	for _, f := range files {
+		id := strings.TrimSuffix(f.Name(), ".txt")
+		nanos, err := strconv.ParseInt(id, 10, 64)
+		if err != nil || f.IsDir() || id == f.Name() {
+			continue
+		}
+		summary, _ := ioutil.ReadFile(summaryPath(title, id))
+		editor, _ := ioutil.ReadFile(versionEditorPath(title, id))
+		vs = append(vs, version{ID: id, Time: time.Unix(0, nanos), Size: f.Size(), Summary: string(summary), Editor: string(editor)})
+	}
+	sort.Slice(vs, func(i, j int) bool { return vs[i].Time.Before(vs[j].Time) })
+	return vs, nil
+}
+
+// loadVersion reads the body of version id of title
+func loadVersion(title, id string) ([]byte, error) {
+	if _, err := strconv.ParseInt(id, 10, 64); err != nil {
+		return nil, os.ErrNotExist
+	}
+	return ioutil.ReadFile(versionPath(title, id))
+}
+
+// previousVersion returns the body of the newest version that differs from
+// current, the page as it is now, ok is false when title has no such version
+// the newest version is usually current itself, but not when the latest
+// saves fell below the -history-min-* thresholds
+func previousVersion(title string, current []byte) (body []byte, ok bool, err error) {
+	vs, err := versions(title)
+	if err != nil {
+		return nil, false, err
+	}
+	for i := len(vs) - 1; i >= 0; i-- {
+		body, err = loadVersion(title, vs[i].ID)
+		if err != nil {
+			return nil, false, err
+		}
+		if !bytes.Equal(body, current) {
+			return body, true, nil
+		}
+	}
+	return nil, false, nil
+}
+
+// historyOwner returns the page whose access level governs the history vs of title,
+// which is the page itself or, for a deleted page, its last version
+func historyOwner(title string, vs []version) (*Page, error) {
+	p, err := loadPage(title)
+	if err != nil && len(vs) > 0 {
+		if body, verr := loadVersion(title, vs[len(vs)-1].ID); verr == nil {
+			p, err = newPage(title, body), nil
+		}
+	}
+	return p, err
+}
+
+// canViewVersion reports whether the requester behind r may see the version
+// of title with body, judged by the access level of its own frontmatter
+// rather than that of the current page
+func canViewVersion(r *http.Request, title string, body []byte) bool {
+	return canView(r, newPage(title, body))
+}
+
+// visibleVersions filters vs down to the versions of title the requester
+// behind r may see, versions that cannot be loaded are left out
+func visibleVersions(r *http.Request, title string, vs []version) []version {
+	visible := []version{}
+	for _, v := range vs {
+		if body, err := loadVersion(title, v.ID); err == nil && canViewVersion(r, title, body) {
+			visible = append(visible, v)
+		}
+	}
+	return visible
+}
+
+// historyPage is the data for the history template, newest version first
+type historyPage struct {
+	Title    string
+	Versions []version
+}
+
+// historyHandler lists the saved versions of a page with their edit summaries,
+// leaving out those the requester could not see when they were saved
+func historyHandler(w http.ResponseWriter, r *http.Request, title string) {
+	vs, err := versions(title)
+	if err != nil {
+		errorHandler(w, r, http.StatusInternalServerError, err.Error())
+		return
+	}
+	p, err := historyOwner(title, vs)
+	if err != nil {
+		notFound(w, r)
+		return
+	}
+	if denyView(w, r, p) {
+		return
+	}
+	h := &historyPage{Title: title}
+	vs = visibleVersions(r, title, vs)
+	for i := len(vs) - 1; i >= 0; i-- {
+		h.Versions = append(h.Versions, vs[i])
+	}
+	renderTemplate(w, r, "history", h)
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// saveAs saves body as title by editor with the edit summary summary
+func saveAs(t *testing.T, title, body, editor, summary string) {
+	t.Helper()
+	p := newPage(title, []byte(body))
+	p.Editor, p.Summary = editor, summary
+	if err := p.save(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// versionBodies lists the bodies of the versions of title, oldest first
+func versionBodies(t *testing.T, title string) []string {
+	t.Helper()
+	vs, err := versions(title)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var bodies []string
+	for _, v := range vs {
+		body, err := loadVersion(title, v.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		bodies = append(bodies, string(body))
+	}
+	return bodies
+}
+
+// backdate moves every version of title back by d
+func backdate(t *testing.T, title string, d time.Duration) {
+	t.Helper()
+	vs, _ := versions(title)
+	for _, v := range vs {
+		id := strconv.FormatInt(v.Time.Add(-d).UnixNano(), 10)
+		for _, path := range [][2]string{
+			{versionPath(title, v.ID), versionPath(title, id)},
+			{summaryPath(title, v.ID), summaryPath(title, id)},
+			{versionEditorPath(title, v.ID), versionEditorPath(title, id)},
+		} {
+			os.Rename(path[0], path[1])
+		}
+	}
+	os.Remove(latestPath(title))
+}
+
+func TestLatestPointer(t *testing.T) {
+	h := newTestWiki(t)
+	for _, body := range []string{"one", "two", "three"} {
+		writePage(t, "Notes", body)
+		vs, _ := versions("Notes")
+		id, err := os.ReadFile(latestPath("Notes"))
+		if err != nil || string(id) != vs[len(vs)-1].ID {
+			t.Fatalf("after saving %q latest is %q, %v, want %s", body, id, err, vs[len(vs)-1].ID)
+		}
+	}
+
+	w := do(h, get("/api/history/Notes/latest"))
+	wantStatus(t, w, http.StatusOK)
+	vs, _ := versions("Notes")
+	if w.Body.String() != "three" || w.Header().Get("X-Version") != vs[2].ID {
+		t.Errorf("latest is %q with X-Version %q, want three at %s", w.Body, w.Header().Get("X-Version"), vs[2].ID)
+	}
+	wantStatus(t, do(h, get("/api/history/Nothing/latest")), http.StatusNotFound)
+}
+
+func TestLatestPointerFallback(t *testing.T) {
+	newTestWiki(t)
+	writePage(t, "Notes", "one")
+	writePage(t, "Notes", "two")
+	vs, _ := versions("Notes")
+
+	os.Remove(latestPath("Notes"))
+	if id, err := latestVersion("Notes"); err != nil || id != vs[1].ID {
+		t.Errorf("without a pointer latest is %q, %v, want %s", id, err, vs[1].ID)
+	}
+	if err := removeVersion("Notes", vs[1].ID); err != nil {
+		t.Fatal(err)
+	}
+	os.WriteFile(latestPath("Notes"), []byte(vs[1].ID), 0600)
+	if id, err := latestVersion("Notes"); err != nil || id != vs[0].ID {
+		t.Errorf("with a pointer to a removed version latest is %q, %v, want %s", id, err, vs[0].ID)
+	}
+}
+
+func TestHistoryThresholds(t *testing.T) {
+	newTestWiki(t, "history-min-chars=10")
+	writePage(t, "Notes", "the first text")
+	writePage(t, "Notes", "the first texts")
+	if got := versionBodies(t, "Notes"); len(got) != 1 {
+		t.Errorf("a change below -history-min-chars was kept: %q", got)
+	}
+	if got := readPage(t, "Notes"); got != "the first texts" {
+		t.Errorf("a change below the threshold was not saved: %q", got)
+	}
+	saveAs(t, "Notes", "the first texts!", "", "typo")
+	writePage(t, "Notes", "something else entirely")
+	if got := versionBodies(t, "Notes"); len(got) != 3 || got[1] != "the first texts!" || got[2] != "something else entirely" {
+		t.Errorf("versions %q, want the summarized and the large change kept", got)
+	}
+
+	setFlag(t, "history-min-chars", "0")
+	setFlag(t, "history-min-lines", "2")
+	writePage(t, "Lines", "a\nb\nc")
+	writePage(t, "Lines", "a\nB\nc")
+	writePage(t, "Lines", "A\nB\nC")
+	if got := versionBodies(t, "Lines"); len(got) != 2 || got[1] != "A\nB\nC" {
+		t.Errorf("versions %q under -history-min-lines 2", got)
+	}
+
+	setFlag(t, "history-min-lines", "0")
+	setFlag(t, "history-min-interval", "1h")
+	writePage(t, "Timed", "one")
+	writePage(t, "Timed", "two")
+	backdate(t, "Timed", 2*time.Hour)
+	writePage(t, "Timed", "three")
+	if got := versionBodies(t, "Timed"); len(got) != 2 || got[0] != "one" || got[1] != "three" {
+		t.Errorf("versions %q under -history-min-interval 1h", got)
+	}
+}
+
+func TestHistoryCompactWindow(t *testing.T) {
+	newTestWiki(t, "history-compact-window=10m")
+	saveAs(t, "Notes", "one", "ann", "")
+	saveAs(t, "Notes", "two", "ann", "")
+	saveAs(t, "Notes", "three", "ann", "")
+	if got := versionBodies(t, "Notes"); len(got) != 2 || got[0] != "one" || got[1] != "three" {
+		t.Errorf("versions %q, want the first kept and quick saves folded into the newest", got)
+	}
+
+	saveAs(t, "Notes", "four", "bob", "")
+	saveAs(t, "Notes", "five", "bob", "with a summary")
+	saveAs(t, "Notes", "six", "bob", "")
+	saveAs(t, "Notes", "seven", "", "")
+	saveAs(t, "Notes", "eight", "", "")
+	if got := versionBodies(t, "Notes"); strings.Join(got, ",") != "one,three,five,six,seven,eight" {
+		t.Errorf("versions %q, want a summarized version and saves by unknown editors kept", got)
+	}
+
+	backdate(t, "Notes", 11*time.Minute)
+	saveAs(t, "Notes", "nine", "", "")
+	saveAs(t, "Notes", "ten", "ann", "")
+	backdate(t, "Notes", 11*time.Minute)
+	saveAs(t, "Notes", "eleven", "ann", "")
+	got := versionBodies(t, "Notes")
+	if strings.Join(got[6:], ",") != "nine,ten,eleven" {
+		t.Errorf("versions %q, want a save outside the window kept", got)
+	}
+	if id, err := latestVersion("Notes"); err != nil || id == "" {
+		t.Errorf("latest version %q, %v", id, err)
+	}
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"time"
+)
+
+// historyAPIPath matches /api/history/{Page.Title} and /api/history/{Page.Title}/{version.ID},
+// where the id may also be "latest"
+var historyAPIPath = regexp.MustCompile("^/api/history/([a-zA-Z0-9]+)(?:/([0-9]+|latest))?$")
+
+// auditRestore is recorded when a page is set back to an earlier version
+const auditRestore = "restore"
+
+// historyAPIHandler serves the history of a page as JSON
+//
+//	GET  /api/history/{title}       the saved versions, oldest first
+//	GET  /api/history/{title}/{id}  the body of version id
+//	POST /api/history/{title}/{id}  restores version id, see restoreHandler
+//
+// the id latest stands for the newest version, whose own id is given in the
+// X-Version header of the response
+// pages and versions that do not exist are 404 Not Found, versions are
+// only listed, shown and restored to those who may see them by their own
+// access level, and restoring needs the same login as editing,
+// see requireTokenOrSession
+func historyAPIHandler(w http.ResponseWriter, r *http.Request) {
+	m := historyAPIPath.FindStringSubmatch(r.URL.Path)
+	switch {
+	case m == nil:
+		notFound(w, r)
+	case r.Method == http.MethodGet || r.Method == http.MethodHead:
+		requireToken(scopeRead, versionsHandler)(w, r)
+	case r.Method == http.MethodPost && m[2] != "":
+		requireWritable(requireEditNetwork(requireTokenOrSession(scopeWrite, restoreHandler)))(w, r)
+	default:
+		w.Header().Set("Allow", "GET, HEAD, POST")
+		errorHandler(w, r, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+// versionsHandler lists the versions of a page or returns the body of one of them
+func versionsHandler(w http.ResponseWriter, r *http.Request) {
+	m := historyAPIPath.FindStringSubmatch(r.URL.Path)
+	title, id := m[1], m[2]
+	vs, err := versions(title)
+	if err != nil {
+		errorHandler(w, r, http.StatusInternalServerError, err.Error())
+		return
+	}
+	p, err := historyOwner(title, vs)
+	if err != nil {
+		notFound(w, r)
+		return
+	}
+	if denyView(w, r, p) {
+		return
+	}
+	if id == "" {
+		vs = visibleVersions(r, title, vs)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(vs)
+		return
+	}
+	if id == "latest" {
+		if id, err = latestVersion(title); err != nil {
+			errorHandler(w, r, http.StatusInternalServerError, err.Error())
+			return
+		}
+	}
+	body, err := loadVersion(title, id)
+	if err != nil {
+		notFound(w, r)
+		return
+	}
+	if denyView(w, r, newPage(title, body)) {
+		return
+	}
+	setPageCache(w, p)
+	w.Header().Set("X-Version", id)
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Write(body)
+}
+
+// restoreHandler saves version id of a page as its new current body, which
+// adds a version to its history like any other save
+// a deleted page is brought back by restoring one of its versions
+// it responds with the new version as JSON
+func restoreHandler(w http.ResponseWriter, r *http.Request) {
+	m := historyAPIPath.FindStringSubmatch(r.URL.Path)
+	title, id := m[1], m[2]
+	if overQuota(w, r) {
+		return
+	}
+
+	unlock := lockPage(title)
+	defer unlock()
+	vs, err := versions(title)
+	if err != nil {
+		errorHandler(w, r, http.StatusInternalServerError, err.Error())
+		return
+	}
+	owner, err := historyOwner(title, vs)
+	if err != nil {
+		notFound(w, r)
+		return
+	}
+	if denyView(w, r, owner) {
+		return
+	}
+	if id == "latest" {
+		if id, err = latestVersion(title); err != nil {
+			errorHandler(w, r, http.StatusInternalServerError, err.Error())
+			return
+		}
+	}
+	body, err := loadVersion(title, id)
+	if err != nil {
+		notFound(w, r)
+		return
+	}
+	p := newPage(title, body)
+	if denyView(w, r, p) {
+		return
+	}
+	if err := validateSlug(title, p.Meta.Slug); err != nil {
+		errorHandler(w, r, http.StatusConflict, err.Error())
+		return
+	}
+	var restored time.Time
+	for _, v := range vs {
+		if v.ID == id {
+			restored = v.Time
+		}
+	}
+	p.Summary = editSummary("restored the version of " + restored.UTC().Format("2006-01-02 15:04:05"))
+	p.Editor = editorName(r)
+	if err := p.write(); err != nil {
+		errorHandler(w, r, http.StatusInternalServerError, err.Error())
+		return
+	}
+	recordSave(title)
+	recordEdit(r, time.Now())
+	recordAudit(r, auditRestore, title)
+
+	current := version{}
+	if vs, err := versions(title); err == nil && len(vs) > 0 {
+		current = vs[len(vs)-1]
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(current)
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// firstVersion saves two versions of title and returns the id of the first
+func firstVersion(t *testing.T, title string) string {
+	t.Helper()
+	writePage(t, title, "first")
+	writePage(t, title, "second")
+	vs, err := versions(title)
+	if err != nil || len(vs) != 2 {
+		t.Fatalf("versions of %s: %v, %v", title, vs, err)
+	}
+	return vs[0].ID
+}
+
+// restore is a POST restoring version id of title
+func restore(title, id string) *http.Request {
+	return postForm("/api/history/"+title+"/"+id, nil)
+}
+
+func TestRestore(t *testing.T) {
+	h := newTestWiki(t)
+	id := firstVersion(t, "Notes")
+
+	w := do(h, restore("Notes", id))
+	wantStatus(t, w, http.StatusOK)
+	if got := readPage(t, "Notes"); got != "first" {
+		t.Errorf("restored body %q, want %q", got, "first")
+	}
+	var v version
+	if err := json.NewDecoder(w.Body).Decode(&v); err != nil {
+		t.Fatal(err)
+	}
+	vs, _ := versions("Notes")
+	if len(vs) != 3 || vs[2].ID != v.ID {
+		t.Fatalf("restore answered version %q, the history is %v", v.ID, vs)
+	}
+	if !strings.HasPrefix(v.Summary, "restored the version of ") {
+		t.Errorf("summary %q", v.Summary)
+	}
+	entries, err := tailAudit(1)
+	if err != nil || len(entries) != 1 || entries[0].Action != auditRestore || entries[0].Title != "Notes" {
+		t.Errorf("audit log %v, %v", entries, err)
+	}
+}
+
+func TestRestoreMissing(t *testing.T) {
+	h := newTestWiki(t)
+	id := firstVersion(t, "Notes")
+	wantStatus(t, do(h, restore("Notes", "12345")), http.StatusNotFound)
+	wantStatus(t, do(h, restore("Nothing", id)), http.StatusNotFound)
+}
+
+func TestRestoreNeedsSession(t *testing.T) {
+	h := newTestWiki(t, "session-secret=secret")
+	id := firstVersion(t, "Notes")
+
+	wantStatus(t, do(h, restore("Notes", id)), http.StatusUnauthorized)
+	if got := readPage(t, "Notes"); got != "second" {
+		t.Fatalf("anonymous restore changed the page to %q", got)
+	}
+
+	r := asUser(restore("Notes", id), "alice")
+	r.Header.Set("Origin", "http://evil.example")
+	wantStatus(t, do(h, r), http.StatusForbidden)
+	if got := readPage(t, "Notes"); got != "second" {
+		t.Fatalf("cross-site restore changed the page to %q", got)
+	}
+
+	w := do(h, asUser(restore("Notes", id), "alice"))
+	wantStatus(t, w, http.StatusOK)
+	var v version
+	json.NewDecoder(w.Body).Decode(&v)
+	if got := readPage(t, "Notes"); got != "first" || v.Editor != "alice" {
+		t.Errorf("restored %q by %q, want %q by alice", got, v.Editor, "first")
+	}
+}
+
+func TestRestoreNeedsWriteToken(t *testing.T) {
+	h := newTestWiki(t, "session-secret=secret")
+	apiTokens = map[[sha256.Size]byte]map[string]bool{
+		sha256.Sum256([]byte("reader")): {scopeRead: true},
+		sha256.Sum256([]byte("writer")): {scopeRead: true, scopeWrite: true},
+	}
+	id := firstVersion(t, "Notes")
+
+	for _, tc := range []struct {
+		auth string
+		want int
+	}{
+		{"", http.StatusUnauthorized},
+		{"Bearer wrong", http.StatusUnauthorized},
+		{"Bearer reader", http.StatusForbidden},
+		{"Bearer writer", http.StatusOK},
+	} {
+		r := asUser(restore("Notes", id), "alice")
+		if tc.auth != "" {
+			r.Header.Set("Authorization", tc.auth)
+		}
+		if w := do(h, r); w.Code != tc.want {
+			t.Errorf("restore with %q: status %d, want %d", tc.auth, w.Code, tc.want)
+		}
+	}
+	if got := readPage(t, "Notes"); got != "first" {
+		t.Errorf("body %q after restoring with a write token", got)
+	}
+}
+
+func TestHistoryHidesVersionsByTheirOwnAccess(t *testing.T) {
+	h := newAccessWiki(t)
+	writePage(t, "Plans", "---\naccess: private\nowner: bob\n---\nthe secret plan")
+	writePage(t, "Plans", "---\naccess: internal\n---\nthe team plan")
+	writePage(t, "Plans", "the public plan")
+	vs, _ := versions("Plans")
+	if len(vs) != 3 {
+		t.Fatalf("versions %v", vs)
+	}
+	private, internal := vs[0].ID, vs[1].ID
+
+	for _, req := range requesters {
+		want := map[string]int{"anonymous": 1, "user": 2, "owner": 3, "admin": 3}[req.name]
+		w := do(h, req.as(get("/api/history/Plans")))
+		wantStatus(t, w, http.StatusOK)
+		var listed []version
+		json.NewDecoder(w.Body).Decode(&listed)
+		if len(listed) != want {
+			t.Errorf("/api/history/Plans as %s lists %d versions, want %d", req.name, len(listed), want)
+		}
+		w = do(h, req.as(get("/history/Plans")))
+		wantStatus(t, w, http.StatusOK)
+		if n := strings.Count(w.Body.String(), " bytes</td>"); n != want {
+			t.Errorf("/history/Plans as %s links %d versions, want %d", req.name, n, want)
+		}
+	}
+
+	wantStatus(t, do(h, get("/api/history/Plans/"+private)), http.StatusNotFound)
+	wantStatus(t, do(h, get("/api/history/Plans/"+internal)), http.StatusForbidden)
+	wantStatus(t, do(h, asUser(get("/api/history/Plans/"+private), "alice")), http.StatusNotFound)
+	wantStatus(t, do(h, asUser(get("/api/history/Plans/"+internal), "alice")), http.StatusOK)
+	if w := do(h, asUser(get("/api/history/Plans/"+private), "bob")); w.Code != http.StatusOK || w.Body.String() != "---\naccess: private\nowner: bob\n---\nthe secret plan" {
+		t.Errorf("the owner sees the private version as %d %q", w.Code, w.Body)
+	}
+
+	wantStatus(t, do(h, asUser(restore("Plans", private), "alice")), http.StatusNotFound)
+	if got := readPage(t, "Plans"); got != "the public plan" {
+		t.Errorf("restoring a version alice cannot see changed the page to %q", got)
+	}
+}
+
+func TestChangesHideVersionsByTheirOwnAccess(t *testing.T) {
+	h := newAccessWiki(t)
+	writePage(t, "Plans", "---\naccess: private\nowner: bob\n---\nthe secret plan")
+	writePage(t, "Plans", "the public plan")
+	w := do(h, get("/view/Plans?changes=1"))
+	wantStatus(t, w, http.StatusOK)
+	if strings.Contains(w.Body.String(), "secret") {
+		t.Errorf("the changes of a formerly private page show its private version:\n%s", w.Body)
+	}
+	w = do(h, asUser(get("/view/Plans?changes=1"), "bob"))
+	wantStatus(t, w, http.StatusOK)
+	if !strings.Contains(w.Body.String(), "secret") {
+		t.Errorf("the owner does not see the changes from the private version:\n%s", w.Body)
+	}
+}
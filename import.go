@@ -0,0 +1,175 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"html/template"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// settings for the [import:URL] directive
+// importing is disabled unless at least one host is allowed
+var (
+	importHosts   = flag.String("import-hosts", "", "comma separated list of hosts that [import:URL] may fetch from (empty disables importing)")
+	importTimeout = flag.Duration("import-timeout", 5*time.Second, "timeout for fetching [import:URL] content")
+	importTTL     = flag.Duration("import-ttl", 10*time.Minute, "how long fetched [import:URL] content is cached")
+)
+
+// maxImportSize caps how much of a remote document is read by an import
+const maxImportSize = 1 << 20
+
+// importDirective matches a line consisting only of an [import:URL] directive
+var importDirective = regexp.MustCompile(`^\[import:(\S+)\]$`)
+
+// importClient fetches remote content for imports, refusing to follow
+// redirects that leave the allowed hosts
+var importClient = &http.Client{
+	CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		if len(via) >= 5 {
+			return errors.New("too many redirects")
+		}
+		return checkImportURL(req.URL)
+	},
+}
+
+// maxImportEntries caps the number of urls whose content is cached
+const maxImportEntries = 256
+
+// importEntry is a cached result of fetching a single url
+// done is closed once the fetch has finished, until then body and err
+// are not set and other imports of the url wait for it
+type importEntry struct {
+	body    string
+	err     error
+	fetched time.Time
+	done    chan struct{}
+}
+
+// fetching reports whether e is still being fetched
+func (e *importEntry) fetching() bool {
+	select {
+	case <-e.done:
+		return false
+	default:
+		return true
+	}
+}
+
+// importCache holds fetched import content keyed by url
+var importCache = struct {
+	sync.Mutex
+	entries map[string]*importEntry
+}{entries: map[string]*importEntry{}}
+
+// importAllowed reports whether host is in the -import-hosts allowlist
+func importAllowed(host string) bool {
+	host = strings.ToLower(host)
+	for _, h := range strings.Split(*importHosts, ",") {
+		if h = strings.ToLower(strings.TrimSpace(h)); h != "" && h == host {
+			return true
+		}
+	}
+	return false
+}
+
+// checkImportURL validates that u may be fetched by an import
+func checkImportURL(u *url.URL) error {
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("unsupported scheme %q", u.Scheme)
+	}
+	if !importAllowed(u.Hostname()) {
+		return fmt.Errorf("host %q is not allowed", u.Hostname())
+	}
+	return nil
+}
+
+// fetchImport returns the text at rawurl, serving it from the cache
+// while the cached copy is younger than -import-ttl
+// failed fetches are cached too, so a broken url is not hammered on every view,
+// and imports of a url that is being fetched wait for that fetch
+func fetchImport(rawurl string) (string, error) {
+	importCache.Lock()
+	if e, ok := importCache.entries[rawurl]; ok && (e.fetching() || time.Since(e.fetched) < *importTTL) {
+		importCache.Unlock()
+		<-e.done
+		return e.body, e.err
+	}
+	e := &importEntry{done: make(chan struct{})}
+	importCache.entries[rawurl] = e
+	pruneImports(time.Now())
+	importCache.Unlock()
+
+	e.body, e.err = fetchImportURL(rawurl)
+	importCache.Lock()
+	e.fetched = time.Now()
+	importCache.Unlock()
+	close(e.done)
+	return e.body, e.err
+}
+
+// pruneImports drops the cached imports that have expired by now and then,
+// while there are more than maxImportEntries, the oldest ones
+// imports still being fetched are kept, the caller holds importCache's lock
+func pruneImports(now time.Time) {
+	for url, e := range importCache.entries {
+		if !e.fetching() && now.Sub(e.fetched) >= *importTTL {
+			delete(importCache.entries, url)
+		}
+	}
+	for len(importCache.entries) > maxImportEntries {
+		oldest := ""
+		for url, e := range importCache.entries {
+			if !e.fetching() && (oldest == "" || e.fetched.Before(importCache.entries[oldest].fetched)) {
+				oldest = url
+			}
+		}
+		if oldest == "" {
+			return
+		}
+		delete(importCache.entries, oldest)
+	}
+}
+
+// fetchImportURL performs the actual request for fetchImport
+func fetchImportURL(rawurl string) (string, error) {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return "", err
+	}
+	if err := checkImportURL(u); err != nil {
+		return "", err
+	}
+	client := *importClient
+	client.Timeout = *importTimeout
+	resp, err := client.Get(u.String())
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxImportSize))
+	if err != nil {
+		return "", err
+	}
+	return string(body), nil
+}
+
+// renderImport renders the content of an [import:URL] directive,
+// a failed fetch renders a visible placeholder rather than failing the page
+func renderImport(rawurl string) string {
+	body, err := fetchImport(rawurl)
+	if err != nil {
+		return `<p class="import-error">could not import ` + template.HTMLEscapeString(rawurl) + ": " + template.HTMLEscapeString(err.Error()) + "</p>\n"
+	}
+	return `<pre class="import">` + template.HTMLEscapeString(body) + "</pre>\n"
+}
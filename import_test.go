@@ -0,0 +1,90 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+// newImportServer serves each path's name as its text, counting the requests
+// and holding them until release is closed
+func newImportServer(t *testing.T) (srv *httptest.Server, hits *int64, release chan struct{}) {
+	hits, release = new(int64), make(chan struct{})
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(hits, 1)
+		<-release
+		fmt.Fprint(w, strings.TrimPrefix(r.URL.Path, "/"))
+	}))
+	t.Cleanup(srv.Close)
+	u, _ := url.Parse(srv.URL)
+	newTestWiki(t, "import-hosts="+u.Hostname())
+	importCache.Lock()
+	importCache.entries = map[string]*importEntry{}
+	importCache.Unlock()
+	return srv, hits, release
+}
+
+func TestImportFetchesOnce(t *testing.T) {
+	srv, hits, release := newImportServer(t)
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if body, err := fetchImport(srv.URL + "/shared"); body != "shared" || err != nil {
+				t.Errorf("import %q, %v", body, err)
+			}
+		}()
+	}
+	close(release)
+	wg.Wait()
+	if n := atomic.LoadInt64(hits); n != 1 {
+		t.Errorf("%d fetches of one url, want 1", n)
+	}
+}
+
+func TestImportCacheExpires(t *testing.T) {
+	srv, hits, release := newImportServer(t)
+	close(release)
+	fetchImport(srv.URL + "/page")
+	fetchImport(srv.URL + "/page")
+	if n := atomic.LoadInt64(hits); n != 1 {
+		t.Errorf("%d fetches within -import-ttl, want 1", n)
+	}
+	setFlag(t, "import-ttl", "1ns")
+	fetchImport(srv.URL + "/page")
+	if n := atomic.LoadInt64(hits); n != 2 {
+		t.Errorf("%d fetches after -import-ttl, want 2", n)
+	}
+}
+
+func TestImportCacheIsBounded(t *testing.T) {
+	srv, _, release := newImportServer(t)
+	close(release)
+	for i := 0; i < maxImportEntries+20; i++ {
+		fetchImport(fmt.Sprintf("%s/page%d", srv.URL, i))
+	}
+	importCache.Lock()
+	n := len(importCache.entries)
+	_, newest := importCache.entries[fmt.Sprintf("%s/page%d", srv.URL, maxImportEntries+19)]
+	_, oldest := importCache.entries[srv.URL+"/page0"]
+	importCache.Unlock()
+	if n > maxImportEntries || !newest || oldest {
+		t.Errorf("%d cached imports, newest cached %v, oldest cached %v", n, newest, oldest)
+	}
+}
+
+func TestImportFailureRendersPlaceholder(t *testing.T) {
+	srv, _, release := newImportServer(t)
+	close(release)
+	writePage(t, "Docs", "[import:"+srv.URL+"/ok]\n\n[import:https://elsewhere.example/x]")
+	got := string(renderBody("Docs", []byte(readPage(t, "Docs"))))
+	if !strings.Contains(got, `<pre class="import">ok</pre>`) || !strings.Contains(got, `<p class="import-error">could not import https://elsewhere.example/x`) {
+		t.Errorf("rendered %s", got)
+	}
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// patterns used to pull the readable text out of a fetched web page
+var (
+	htmlTitle    = regexp.MustCompile(`(?is)<title[^>]*>(.*?)</title>`)
+	htmlHeading1 = regexp.MustCompile(`(?is)<h1[^>]*>(.*?)</h1>`)
+	htmlMain     = regexp.MustCompile(`(?is)<(article|main)[^>]*>(.*)</(?:article|main)>`)
+	htmlBody     = regexp.MustCompile(`(?is)<body[^>]*>(.*)</body>`)
+	htmlNoise    = regexp.MustCompile(`(?is)<(script|style|noscript|nav|header|footer|aside|form|svg|template)\b[^>]*>.*?</(?:script|style|noscript|nav|header|footer|aside|form|svg|template)>|<!--.*?-->`)
+	htmlHeading  = regexp.MustCompile(`(?is)<h([1-6])[^>]*>(.*?)</h[1-6]>`)
+	htmlItem     = regexp.MustCompile(`(?is)<li[^>]*>`)
+	htmlBreak    = regexp.MustCompile(`(?is)</?(p|div|section|article|blockquote|pre|ul|ol|table|tr|br|hr)\b[^>]*>`)
+	htmlAnyTag   = regexp.MustCompile(`(?s)<[^>]*>`)
+)
+
+// article is the readable part of a fetched web page
+type article struct {
+	Title string
+	Text  string
+}
+
+// extractArticle does a simple readability style extraction of the title and text
+// of an HTML document, preferring its <article> or <main> element and dropping
+// navigation, scripts and other page furniture
+// ok is false if src does not look like HTML with any text in it
+func extractArticle(src string) (a article, ok bool) {
+	if !strings.Contains(strings.ToLower(src), "<html") && !strings.Contains(strings.ToLower(src), "<body") {
+		return a, false
+	}
+	src = htmlNoise.ReplaceAllString(src, "")
+	if m := htmlTitle.FindStringSubmatch(src); m != nil {
+		a.Title = inlineText(m[1])
+	}
+	if m := htmlHeading1.FindStringSubmatch(src); m != nil && a.Title == "" {
+		a.Title = inlineText(m[1])
+	}
+	content := src
+	if m := htmlMain.FindStringSubmatch(src); m != nil {
+		content = m[2]
+	} else if m := htmlBody.FindStringSubmatch(src); m != nil {
+		content = m[1]
+	}
+
+	content = htmlHeading.ReplaceAllStringFunc(content, func(h string) string {
+		m := htmlHeading.FindStringSubmatch(h)
+		level, _ := strconv.Atoi(m[1])
+		return "\n\n" + strings.Repeat("#", level) + " " + inlineText(m[2]) + "\n\n"
+	})
+	content = htmlItem.ReplaceAllString(content, "\n- ")
+	content = htmlBreak.ReplaceAllString(content, "\n\n")
+	content = html.UnescapeString(htmlAnyTag.ReplaceAllString(content, ""))
+
+	var paras []string
+	for _, block := range strings.Split(content, "\n\n") {
+		var lines []string
+		for _, line := range strings.Split(block, "\n") {
+			if line = strings.Join(strings.Fields(line), " "); line != "" && line != "-" {
+				lines = append(lines, line)
+			}
+		}
+		if len(lines) > 0 {
+			paras = append(paras, strings.Join(lines, "\n"))
+		}
+	}
+	a.Text = strings.Join(paras, "\n\n")
+	return a, a.Text != ""
+}
+
+// inlineText reduces an HTML fragment to its text on a single line
+func inlineText(s string) string {
+	return strings.Join(strings.Fields(html.UnescapeString(htmlAnyTag.ReplaceAllString(s, " "))), " ")
+}
+
+// titleFrom turns free text such as an article title into a page title
+// by joining its words in CamelCase, e.g. "How Go works" becomes HowGoWorks
+func titleFrom(s string) string {
+	var b strings.Builder
+	for _, word := range strings.FieldsFunc(s, func(r rune) bool { return r > unicode.MaxASCII || !unicode.IsLetter(r) && !unicode.IsDigit(r) }) {
+		b.WriteString(strings.ToUpper(word[:1]) + word[1:])
+		if b.Len() >= *maxTitleLen {
+			break
+		}
+	}
+	t := b.String()
+	if len(t) > *maxTitleLen {
+		t = t[:*maxTitleLen]
+	}
+	return t
+}
+
+// unusedTitle returns title, or title with the lowest number appended
+// that does not clash with the title or slug of an existing page
+func unusedTitle(title string) string {
+	if !titleInUse(title) {
+		return title
+	}
+	for n := 2; ; n++ {
+		t := title + strconv.Itoa(n)
+		if !titleInUse(t) {
+			return t
+		}
+	}
+}
+
+// importURLHandler creates a page from the web page at the form value url,
+// fetched under the same -import-hosts allowlist and -import-timeout as
+// [import:URL], and then opens it for editing
+// the page is named after the form value title, or else the article's title,
+// and pages that cannot be extracted are kept as raw text with a source note
+func importURLHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		errorHandler(w, r, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	if overQuota(w, r) {
+		return
+	}
+	src := strings.TrimSpace(r.FormValue("url"))
+	u, err := url.Parse(src)
+	if err == nil {
+		err = checkImportURL(u)
+	}
+	if err != nil {
+		errorHandler(w, r, http.StatusForbidden, "cannot import "+src+": "+err.Error())
+		return
+	}
+	raw, err := fetchImportURL(src)
+	if err != nil {
+		errorHandler(w, r, http.StatusBadGateway, "could not import "+src+": "+err.Error())
+		return
+	}
+
+	a, ok := extractArticle(raw)
+	if !ok {
+		a = article{Text: strings.TrimSpace(raw)}
+	}
+	title := r.FormValue("title")
+	if title == "" {
+		if title = titleFrom(a.Title); title == "" {
+			title = "ImportedPage"
+		}
+		title = unusedTitle(title)
+	}
+	if err := validateTitle(title); err != nil {
+		errorHandler(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	if pageExists(title) {
+		errorHandler(w, r, http.StatusConflict, "page "+title+" already exists")
+		return
+	}
+
+	body := a.Text + "\n\nSource: " + src + "\n"
+	if a.Title != "" {
+		body = string(setFrontmatter([]byte(body), "title", a.Title))
+	}
+	p := newPage(title, []byte(body))
+	if err := validateSlug(title, p.Meta.Slug); err != nil {
+		errorHandler(w, r, http.StatusConflict, err.Error())
+		return
+	}
+	p.Summary = fmt.Sprintf("imported from %s", src)
+	p.Editor = editorName(r)
+	if err := p.save(); err != nil {
+		errorHandler(w, r, http.StatusInternalServerError, err.Error())
+		return
+	}
+	recordEdit(r, time.Now())
+	recordAudit(r, auditCreate, title)
+	http.Redirect(w, r, "/edit/"+title, http.StatusFound)
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// wikiIndex holds the in-memory indexes derived from the pages on disk:
+// the set of page titles, the link graph between them and their slugs
+type wikiIndex struct {
+	links     map[string][]string        // title -> titles it links to
+	backlinks map[string]map[string]bool // title -> titles linking to it
+	slugs     map[string]string          // slug -> title
+	slugOf    map[string]string          // title -> slug
+}
+
+// index is the live wikiIndex, guarded by indexMu
+// rebuilds construct a fresh wikiIndex and swap it in as a whole, replaying
+// onto it the changes in indexPending made to the live index meanwhile
+// so that pages saved or deleted during a rebuild are not lost
+var (
+	indexMu      sync.RWMutex
+	index        = newWikiIndex()
+	indexPending []func(*wikiIndex) // nil unless a rebuild is running
+)
+
+// rebuildMu keeps rebuilds from running at the same time
+var rebuildMu sync.Mutex
+
+// newWikiIndex returns an empty wikiIndex
+func newWikiIndex() *wikiIndex {
+	return &wikiIndex{links: map[string][]string{}, backlinks: map[string]map[string]bool{}, slugs: map[string]string{}, slugOf: map[string]string{}}
+}
+
+// set records that the page p exists, its slug and the pages it links to,
+// replacing whatever was previously recorded for its title
+func (idx *wikiIndex) set(p *Page) {
+	title := p.Title
+	idx.remove(title)
+	if slug := p.Meta.Slug; validSlug.MatchString(slug) {
+		idx.slugs[slug] = title
+		idx.slugOf[title] = slug
+	}
+	var targets []string
+	for _, l := range pageLinks(p.Content) {
+		targets = append(targets, l.Title)
+		if idx.backlinks[l.Title] == nil {
+			idx.backlinks[l.Title] = map[string]bool{}
+		}
+		idx.backlinks[l.Title][title] = true
+	}
+	idx.links[title] = targets
+}
+
+// remove forgets title, its slug and the links from it
+func (idx *wikiIndex) remove(title string) {
+	for _, target := range idx.links[title] {
+		delete(idx.backlinks[target], title)
+	}
+	delete(idx.links, title)
+	if slug, ok := idx.slugOf[title]; ok {
+		if idx.slugs[slug] == title {
+			delete(idx.slugs, slug)
+		}
+		delete(idx.slugOf, title)
+	}
+}
+
+// buildIndex reads every page on disk into a new wikiIndex
+func buildIndex() (*wikiIndex, error) {
+	titles, err := listPages()
+	if err != nil {
+		return nil, err
+	}
+	idx := newWikiIndex()
+	for _, title := range titles {
+		p, err := loadPage(title)
+		if os.IsNotExist(err) {
+			continue // deleted since it was listed
+		}
+		if err != nil {
+			return nil, err
+		}
+		idx.set(p)
+	}
+	return idx, nil
+}
+
+// rebuildIndex replaces the live index with one freshly built from disk
+func rebuildIndex() (*wikiIndex, error) {
+	rebuildMu.Lock()
+	defer rebuildMu.Unlock()
+	indexMu.Lock()
+	indexPending = []func(*wikiIndex){}
+	indexMu.Unlock()
+
+	idx, err := buildIndex()
+	indexMu.Lock()
+	defer indexMu.Unlock()
+	pending := indexPending
+	indexPending = nil
+	if err != nil {
+		return nil, err
+	}
+	for _, change := range pending {
+		change(idx)
+	}
+	index = idx
+	return idx, nil
+}
+
+// changeIndex applies change to the live index, and to the index being
+// rebuilt once it is done if a rebuild is running
+func changeIndex(change func(*wikiIndex)) {
+	indexMu.Lock()
+	defer indexMu.Unlock()
+	change(index)
+	if indexPending != nil {
+		indexPending = append(indexPending, change)
+	}
+}
+
+// indexPage updates the live index after p has been saved
+func indexPage(p *Page) {
+	changeIndex(func(idx *wikiIndex) { idx.set(p) })
+}
+
+// unindexPage updates the live index after title has been deleted
+func unindexPage(title string) {
+	changeIndex(func(idx *wikiIndex) { idx.remove(title) })
+}
+
+// backlinks lists the titles of pages linking to title, sorted
+func backlinks(title string) []string {
+	indexMu.RLock()
+	defer indexMu.RUnlock()
+	var titles []string
+	for t := range index.backlinks[title] {
+		titles = append(titles, t)
+	}
+	sort.Strings(titles)
+	return titles
+}
+
+// slugTitle returns the title of the page with slug, "" if there is none
+func slugTitle(slug string) string {
+	indexMu.RLock()
+	defer indexMu.RUnlock()
+	return index.slugs[slug]
+}
+
+// rebuildSummary reports the outcome of an index rebuild
+type rebuildSummary struct {
+	Pages    int    `json:"pages"`
+	Links    int    `json:"links"`
+	Duration string `json:"duration"`
+}
+
+// rebuildHandler rebuilds all derived indexes from disk and reports
+// a JSON summary of their sizes and how long the rebuild took
+func rebuildHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		errorHandler(w, r, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	start := time.Now()
+	idx, err := rebuildIndex()
+	if err != nil {
+		errorHandler(w, r, http.StatusInternalServerError, err.Error())
+		return
+	}
+	elapsed := time.Since(start)
+	log.Printf("rebuilt indexes in %s", elapsed)
+
+	summary := rebuildSummary{Pages: len(idx.links), Duration: elapsed.String()}
+	for _, targets := range idx.links {
+		summary.Links += len(targets)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(summary)
+}
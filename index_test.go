@@ -0,0 +1,52 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+)
+
+func TestRebuildKeepsConcurrentChanges(t *testing.T) {
+	newTestWiki(t)
+	const n = 50
+	for i := 0; i < n; i++ {
+		writePage(t, fmt.Sprintf("Old%d", i), "see [Target]")
+	}
+
+	done := make(chan struct{})
+	var rebuilds sync.WaitGroup
+	rebuilds.Add(1)
+	go func() {
+		defer rebuilds.Done()
+		for {
+			select {
+			case <-done:
+				return
+			default:
+			}
+			if _, err := rebuildIndex(); err != nil {
+				t.Error(err)
+				return
+			}
+		}
+	}()
+	for i := 0; i < n; i++ {
+		writePage(t, fmt.Sprintf("New%d", i), fmt.Sprintf("---\nslug: new-%d\n---\nsee [Target]", i))
+		unlock := lockPage(fmt.Sprintf("Old%d", i))
+		if err := removePage(fmt.Sprintf("Old%d", i)); err != nil {
+			t.Fatal(err)
+		}
+		unlock()
+	}
+	close(done)
+	rebuilds.Wait()
+
+	for i := 0; i < n; i++ {
+		if got := slugTitle(fmt.Sprintf("new-%d", i)); got != fmt.Sprintf("New%d", i) {
+			t.Errorf("slug new-%d belongs to %q", i, got)
+		}
+	}
+	if got := backlinks("Target"); len(got) != n || got[0][:3] != "New" {
+		t.Errorf("backlinks of Target %v, want the %d New pages", got, n)
+	}
+}
@@ -0,0 +1,229 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"log"
+	"net/http"
+	"regexp"
+	"sync"
+	"time"
+)
+
+// jobWorkers is the number of expensive jobs, such as rendering a book or a PDF,
+// that run at the same time
+var jobWorkers = flag.Int("job-workers", 2, "number of background jobs run at the same time")
+
+// job queue settings
+// finished jobs are kept for jobTTL for their results to be fetched
+const (
+	jobQueueSize = 16
+	jobTTL       = 10 * time.Minute
+)
+
+// job states
+const (
+	jobQueued  = "queued"
+	jobRunning = "running"
+	jobDone    = "done"
+	jobFailed  = "failed"
+)
+
+// jobPath matches /jobs/{id} and /jobs/{id}/result
+var jobPath = regexp.MustCompile("^/jobs/([0-9a-f]{32})(/result)?$")
+
+// errJobsBusy is returned when the job queue is full
+var errJobsBusy = errors.New("too many jobs are waiting, try again shortly")
+
+// jobResult is the response a finished job produced,
+// offered as a download named filename unless it is ""
+type jobResult struct {
+	contentType string
+	filename    string
+	body        []byte
+}
+
+// write writes the result as the response
+func (res jobResult) write(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", res.contentType)
+	if res.filename != "" {
+		w.Header().Set("Content-Disposition", `attachment; filename="`+res.filename+`"`)
+	}
+	w.Write(res.body)
+}
+
+// job is a unit of expensive work run in the background,
+// its id is random so that only whoever started it can find it
+type job struct {
+	ID       string    `json:"id"`
+	Status   string    `json:"status"`
+	Error    string    `json:"error,omitempty"`
+	Result   string    `json:"result,omitempty"`
+	Created  time.Time `json:"created"`
+	Finished time.Time `json:"finished,omitzero"`
+
+	run    func() (jobResult, error)
+	result jobResult
+	err    error
+	done   chan struct{}
+}
+
+// jobs holds every job that is queued, running or recently finished
+var jobs = struct {
+	sync.Mutex
+	m map[string]*job
+}{m: map[string]*job{}}
+
+// jobQueue feeds queued jobs to the workers of runJobs
+var jobQueue = make(chan *job, jobQueueSize)
+
+// submitJob queues run as a new job, failing with errJobsBusy when the queue is full
+func submitJob(run func() (jobResult, error)) (*job, error) {
+	b := make([]byte, 16)
+	rand.Read(b)
+	j := &job{ID: hex.EncodeToString(b), Status: jobQueued, Created: time.Now(), run: run, done: make(chan struct{})}
+
+	jobs.Lock()
+	defer jobs.Unlock()
+	for id, old := range jobs.m {
+		if !old.Finished.IsZero() && time.Since(old.Finished) > jobTTL {
+			delete(jobs.m, id)
+		}
+	}
+	select {
+	case jobQueue <- j:
+	default:
+		return nil, errJobsBusy
+	}
+	jobs.m[j.ID] = j
+	return j, nil
+}
+
+// startJob runs the work of a request as a job, answering 202 Accepted with
+// the job's status, whose Location the client polls until the job is done,
+// or 429 Too Many Requests when the queue is full
+func startJob(w http.ResponseWriter, r *http.Request, run func() (jobResult, error)) {
+	j, err := submitJob(run)
+	if err != nil {
+		w.Header().Set("Retry-After", "10")
+		errorHandler(w, r, http.StatusTooManyRequests, err.Error())
+		return
+	}
+	w.Header().Set("Location", "/jobs/"+j.ID)
+	writeJob(w, http.StatusAccepted, j)
+}
+
+// waitJob runs the work of a request as a job and writes its result once it
+// has finished, so that expensive requests answered right away still take
+// turns with the background jobs, or 429 Too Many Requests when the queue is full
+// a failed job is an HTTP Internal Server Error
+func waitJob(w http.ResponseWriter, r *http.Request, run func() (jobResult, error)) {
+	j, err := submitJob(run)
+	if err != nil {
+		w.Header().Set("Retry-After", "10")
+		errorHandler(w, r, http.StatusTooManyRequests, err.Error())
+		return
+	}
+	select {
+	case <-j.done:
+	case <-r.Context().Done():
+		return
+	}
+	jobs.Lock()
+	delete(jobs.m, j.ID)
+	result, err := j.result, j.err
+	jobs.Unlock()
+	if err != nil {
+		errorHandler(w, r, http.StatusInternalServerError, err.Error())
+		return
+	}
+	result.write(w)
+}
+
+// writeJob writes the status of j as JSON
+func writeJob(w http.ResponseWriter, status int, j *job) {
+	jobs.Lock()
+	snapshot := *j
+	jobs.Unlock()
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(&snapshot)
+}
+
+// runJobs runs queued jobs on -job-workers workers until ctx is done,
+// jobs still queued then are dropped
+func runJobs(ctx context.Context) {
+	var wg sync.WaitGroup
+	for i := 0; i < *jobWorkers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case j := <-jobQueue:
+					runJob(j)
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// runJob runs j, recording its result or why it failed
+func runJob(j *job) {
+	jobs.Lock()
+	j.Status = jobRunning
+	jobs.Unlock()
+
+	result, err := j.run()
+
+	jobs.Lock()
+	defer jobs.Unlock()
+	defer close(j.done)
+	j.Finished = time.Now()
+	if err != nil {
+		log.Printf("job %s: %v", j.ID, err)
+		j.Status, j.Error, j.err = jobFailed, "the job failed", err
+		return
+	}
+	j.Status, j.Result, j.result = jobDone, "/jobs/"+j.ID+"/result", result
+}
+
+// jobsHandler reports the status of a job at /jobs/{id}
+// and serves the result of a finished one at /jobs/{id}/result
+func jobsHandler(w http.ResponseWriter, r *http.Request) {
+	m := jobPath.FindStringSubmatch(r.URL.Path)
+	if m == nil {
+		notFound(w, r)
+		return
+	}
+	jobs.Lock()
+	j, ok := jobs.m[m[1]]
+	var status string
+	var result jobResult
+	if ok {
+		status, result = j.Status, j.result
+	}
+	jobs.Unlock()
+	if !ok {
+		notFound(w, r)
+		return
+	}
+	if m[2] == "" {
+		writeJob(w, http.StatusOK, j)
+		return
+	}
+	if status != jobDone {
+		errorHandler(w, r, http.StatusConflict, "the job is "+status)
+		return
+	}
+	w.Header().Set("Cache-Control", "private, no-cache")
+	result.write(w)
+}
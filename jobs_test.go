@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// emptyJobQueue drops the jobs left queued when the test ends
+func emptyJobQueue(t *testing.T) {
+	t.Cleanup(func() {
+		for {
+			select {
+			case <-jobQueue:
+			default:
+				return
+			}
+		}
+	})
+}
+
+// runJobWorkers runs the job workers until the test ends
+func runJobWorkers(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+	go func() { runJobs(ctx); close(stopped) }()
+	t.Cleanup(func() { cancel(); <-stopped })
+}
+
+// pollJob fetches the status of the job at location until it has finished
+func pollJob(t *testing.T, h http.Handler, location string) job {
+	t.Helper()
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(5 * time.Millisecond) {
+		w := do(h, get(location))
+		wantStatus(t, w, http.StatusOK)
+		var j job
+		if err := json.NewDecoder(w.Body).Decode(&j); err != nil {
+			t.Fatal(err)
+		}
+		if j.Status == jobDone || j.Status == jobFailed {
+			return j
+		}
+	}
+	t.Fatalf("job %s did not finish", location)
+	return job{}
+}
+
+func TestJobLifecycle(t *testing.T) {
+	h := newTestWiki(t)
+	emptyJobQueue(t)
+	writePage(t, "First", "first chapter")
+	writePage(t, "Second", "second chapter")
+
+	w := do(h, get("/book?pages=First,Second&async=1"))
+	wantStatus(t, w, http.StatusAccepted)
+	location := w.Header().Get("Location")
+	var queued job
+	json.NewDecoder(w.Body).Decode(&queued)
+	if queued.Status != jobQueued || location != "/jobs/"+queued.ID {
+		t.Fatalf("started job %+v at %q", queued, location)
+	}
+	wantStatus(t, do(h, get(location+"/result")), http.StatusConflict)
+
+	runJobWorkers(t)
+	done := pollJob(t, h, location)
+	if done.Status != jobDone || done.Result != location+"/result" {
+		t.Fatalf("finished job %+v", done)
+	}
+	w = do(h, get(done.Result))
+	wantStatus(t, w, http.StatusOK)
+	if body := w.Body.String(); !strings.Contains(body, "first chapter") || !strings.Contains(body, "second chapter") {
+		t.Errorf("job result lacks the chapters:\n%s", body)
+	}
+
+	failing, err := submitJob(func() (jobResult, error) { return jobResult{}, errors.New("broken") })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if j := pollJob(t, h, "/jobs/"+failing.ID); j.Status != jobFailed || j.Error != "the job failed" {
+		t.Errorf("failed job %+v", j)
+	}
+	wantStatus(t, do(h, get("/jobs/"+strings.Repeat("0", 32))), http.StatusNotFound)
+}
+
+func TestJobQueueFull(t *testing.T) {
+	h := newTestWiki(t)
+	emptyJobQueue(t)
+	writePage(t, "Page", "text")
+	for i := 0; i < jobQueueSize; i++ {
+		if _, err := submitJob(func() (jobResult, error) { return jobResult{}, nil }); err != nil {
+			t.Fatalf("job %d: %v", i, err)
+		}
+	}
+	if _, err := submitJob(func() (jobResult, error) { return jobResult{}, nil }); err != errJobsBusy {
+		t.Errorf("submitting to a full queue: %v", err)
+	}
+	w := do(h, get("/book?pages=Page&async=1"))
+	wantStatus(t, w, http.StatusTooManyRequests)
+	if w.Header().Get("Retry-After") == "" {
+		t.Error("no Retry-After when the queue is full")
+	}
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// limits on checking external links, which only happens when asked for
+var (
+	linkcheckWorkers = flag.Int("linkcheck-workers", 8, "number of external links /admin/linkcheck checks at once")
+	linkcheckTimeout = flag.Duration("linkcheck-timeout", 5*time.Second, "timeout for checking each external link")
+)
+
+// rawURL matches http and https urls in unescaped page source
+var rawURL = regexp.MustCompile("https?://[^\\s<>\"'`\\[\\]]+")
+
+// externalLinks lists the distinct http and https urls in body,
+// both bare and in Markdown links, leaving out those inside code spans
+// and fenced blocks of code
+func externalLinks(body []byte) []string {
+	seen := map[string]bool{}
+	var urls []string
+	for _, line := range proseLines(body) {
+		line = codeSpan.ReplaceAllString(line, "")
+		for _, m := range rawURL.FindAllString(line, -1) {
+			if u := strings.TrimRight(m, ".,;:!?)"); !seen[u] {
+				seen[u] = true
+				urls = append(urls, u)
+			}
+		}
+	}
+	return urls
+}
+
+// brokenLink is an external link that could not be fetched
+type brokenLink struct {
+	URL   string `json:"url"`
+	Error string `json:"error"`
+}
+
+// linkReport lists the broken links found on one page
+type linkReport struct {
+	Title    string       `json:"title"`
+	Missing  []string     `json:"missing,omitempty"`
+	External []brokenLink `json:"external,omitempty"`
+}
+
+// linkcheckSummary is the JSON response of /admin/linkcheck, counting the pages
+// and links checked, with Broken only holding the pages with broken links
+type linkcheckSummary struct {
+	Pages    int          `json:"pages"`
+	Internal int          `json:"internal"`
+	External int          `json:"external"`
+	Broken   []linkReport `json:"broken"`
+}
+
+// checkURL fetches the headers of url, falling back to GET for servers that
+// do not support HEAD, and describes what is wrong with it if anything
+func checkURL(client *http.Client, url string) string {
+	resp, err := client.Head(url)
+	if err == nil && (resp.StatusCode == http.StatusMethodNotAllowed || resp.StatusCode == http.StatusNotImplemented) {
+		resp.Body.Close()
+		resp, err = client.Get(url)
+	}
+	if err != nil {
+		return err.Error()
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 400 {
+		return resp.Status
+	}
+	return ""
+}
+
+// checkExternal checks urls with -linkcheck-workers requests in flight at once,
+// returning what is wrong with each broken url
+func checkExternal(urls []string) map[string]string {
+	client := &http.Client{Timeout: *linkcheckTimeout}
+	broken := map[string]string{}
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	queue := make(chan string)
+	workers := *linkcheckWorkers
+	if workers < 1 {
+		workers = 1
+	}
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for u := range queue {
+				if problem := checkURL(client, u); problem != "" {
+					mu.Lock()
+					broken[u] = problem
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+	for _, u := range urls {
+		queue <- u
+	}
+	close(queue)
+	wg.Wait()
+	return broken
+}
+
+// linkcheckHandler reports the links to pages that do not exist on every page,
+// and with ?external=1 also the http and https links that cannot be fetched
+func linkcheckHandler(w http.ResponseWriter, r *http.Request) {
+	titles, err := listPages()
+	if err != nil {
+		errorHandler(w, r, http.StatusInternalServerError, err.Error())
+		return
+	}
+	external := r.FormValue("external") == "1"
+
+	summary := linkcheckSummary{Pages: len(titles), Broken: []linkReport{}}
+	reports := map[string]*linkReport{}
+	pagesOf := map[string][]string{}
+	report := func(title string) *linkReport {
+		if reports[title] == nil {
+			reports[title] = &linkReport{Title: title}
+		}
+		return reports[title]
+	}
+	var urls []string
+	for _, title := range titles {
+		p, err := loadPage(title)
+		if err != nil {
+			errorHandler(w, r, http.StatusInternalServerError, err.Error())
+			return
+		}
+		for _, l := range pageLinks(p.Content) {
+			summary.Internal++
+			if !l.Exists {
+				report(title).Missing = append(report(title).Missing, l.Title)
+			}
+		}
+		if !external {
+			continue
+		}
+		for _, u := range externalLinks(p.Content) {
+			if pagesOf[u] == nil {
+				urls = append(urls, u)
+			}
+			pagesOf[u] = append(pagesOf[u], title)
+		}
+	}
+	if external {
+		summary.External = len(urls)
+		for u, problem := range checkExternal(urls) {
+			for _, title := range pagesOf[u] {
+				report(title).External = append(report(title).External, brokenLink{URL: u, Error: problem})
+			}
+		}
+	}
+
+	for _, title := range titles {
+		if rep := reports[title]; rep != nil {
+			sort.Slice(rep.External, func(i, j int) bool { return rep.External[i].URL < rep.External[j].URL })
+			summary.Broken = append(summary.Broken, *rep)
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(summary)
+}
@@ -0,0 +1,232 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"html/template"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultLocale formats dates and numbers for requests whose Accept-Language
+// matches none of the locales
+var defaultLocale = flag.String("locale", "en", "locale dates and numbers are shown in when a request's Accept-Language matches none of en, de, fr and es")
+
+// locale is how dates, relative times and numbers are written in a language
+// units holds the singular and plural of minute, hour and day
+type locale struct {
+	date      string // layout of a date
+	dateTime  string // layout of a date and time of day
+	months    [12]string
+	short     [12]string // abbreviated month names
+	days      [7]string  // weekday names, Sunday first
+	justNow   string
+	ago       string // format of "n units ago"
+	units     map[string][2]string
+	thousands string // separator of groups of thousands
+}
+
+// locales are the locales known by their ISO 639-1 language code
+var locales = map[string]*locale{
+	"en": {
+		date: "2006-01-02", dateTime: "2006-01-02 15:04",
+		months:  [12]string{"January", "February", "March", "April", "May", "June", "July", "August", "September", "October", "November", "December"},
+		short:   [12]string{"Jan", "Feb", "Mar", "Apr", "May", "Jun", "Jul", "Aug", "Sep", "Oct", "Nov", "Dec"},
+		days:    [7]string{"Sunday", "Monday", "Tuesday", "Wednesday", "Thursday", "Friday", "Saturday"},
+		justNow: "just now", ago: "%d %s ago",
+		units:     map[string][2]string{"minute": {"minute", "minutes"}, "hour": {"hour", "hours"}, "day": {"day", "days"}},
+		thousands: ",",
+	},
+	"de": {
+		date: "02.01.2006", dateTime: "02.01.2006 15:04",
+		months:  [12]string{"Januar", "Februar", "März", "April", "Mai", "Juni", "Juli", "August", "September", "Oktober", "November", "Dezember"},
+		short:   [12]string{"Jan", "Feb", "Mär", "Apr", "Mai", "Jun", "Jul", "Aug", "Sep", "Okt", "Nov", "Dez"},
+		days:    [7]string{"Sonntag", "Montag", "Dienstag", "Mittwoch", "Donnerstag", "Freitag", "Samstag"},
+		justNow: "gerade eben", ago: "vor %d %s",
+		units:     map[string][2]string{"minute": {"Minute", "Minuten"}, "hour": {"Stunde", "Stunden"}, "day": {"Tag", "Tagen"}},
+		thousands: ".",
+	},
+	"fr": {
+		date: "02/01/2006", dateTime: "02/01/2006 15:04",
+		months:  [12]string{"janvier", "février", "mars", "avril", "mai", "juin", "juillet", "août", "septembre", "octobre", "novembre", "décembre"},
+		short:   [12]string{"janv.", "févr.", "mars", "avr.", "mai", "juin", "juil.", "août", "sept.", "oct.", "nov.", "déc."},
+		days:    [7]string{"dimanche", "lundi", "mardi", "mercredi", "jeudi", "vendredi", "samedi"},
+		justNow: "à l'instant", ago: "il y a %d %s",
+		units:     map[string][2]string{"minute": {"minute", "minutes"}, "hour": {"heure", "heures"}, "day": {"jour", "jours"}},
+		thousands: " ",
+	},
+	"es": {
+		date: "02/01/2006", dateTime: "02/01/2006 15:04",
+		months:  [12]string{"enero", "febrero", "marzo", "abril", "mayo", "junio", "julio", "agosto", "septiembre", "octubre", "noviembre", "diciembre"},
+		short:   [12]string{"ene", "feb", "mar", "abr", "may", "jun", "jul", "ago", "sept", "oct", "nov", "dic"},
+		days:    [7]string{"domingo", "lunes", "martes", "miércoles", "jueves", "viernes", "sábado"},
+		justNow: "ahora mismo", ago: "hace %d %s",
+		units:     map[string][2]string{"minute": {"minuto", "minutos"}, "hour": {"hora", "horas"}, "day": {"día", "días"}},
+		thousands: ".",
+	},
+}
+
+// checkLocale reports an error if -locale is not one of the known locales
+func checkLocale() error {
+	if locales[*defaultLocale] == nil {
+		return fmt.Errorf("unknown locale %q", *defaultLocale)
+	}
+	return nil
+}
+
+// currentLocale is the locale selected by -locale
+func currentLocale() *locale {
+	if loc := locales[*defaultLocale]; loc != nil {
+		return loc
+	}
+	return locales["en"]
+}
+
+// requestLocale picks the known locale the client behind r prefers most
+// by its Accept-Language header, "" if it accepts none of them
+func requestLocale(r *http.Request) string {
+	best, bestQ := "", 0.0
+	for _, part := range strings.Split(r.Header.Get("Accept-Language"), ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			var err error
+			if q, err = strconv.ParseFloat(v, 64); err != nil {
+				continue
+			}
+		}
+		lang, _, _ := strings.Cut(strings.ToLower(tag), "-")
+		if locales[lang] != nil && q > bestQ {
+			best, bestQ = lang, q
+		}
+	}
+	return best
+}
+
+// formatDate formats t with layout, the locale's date and time when none is
+// given or its date alone for the layout "date", and a zero t as ""
+// month and weekday names in the layout are written in the locale's language
+func (loc *locale) formatDate(t time.Time, layout ...string) string {
+	if t.IsZero() {
+		return ""
+	}
+	l := loc.dateTime
+	if len(layout) > 0 {
+		if l = layout[0]; l == "date" {
+			l = loc.date
+		}
+	}
+	s := t.Format(l)
+	month, day := t.Month().String(), t.Weekday().String()
+	switch {
+	case strings.Contains(l, "January"):
+		s = strings.ReplaceAll(s, month, loc.months[t.Month()-1])
+	case strings.Contains(l, "Jan"):
+		s = strings.ReplaceAll(s, month[:3], loc.short[t.Month()-1])
+	}
+	switch {
+	case strings.Contains(l, "Monday"):
+		s = strings.ReplaceAll(s, day, loc.days[t.Weekday()])
+	case strings.Contains(l, "Mon"):
+		s = strings.ReplaceAll(s, day[:3], string([]rune(loc.days[t.Weekday()])[:3]))
+	}
+	return s
+}
+
+// relativeTime describes how long ago t was, e.g. "5 minutes ago",
+// falling back to its date once it is more than a month old
+func (loc *locale) relativeTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	ago := func(n int, unit string) string {
+		forms := loc.units[unit]
+		if n == 1 {
+			return fmt.Sprintf(loc.ago, n, forms[0])
+		}
+		return fmt.Sprintf(loc.ago, n, forms[1])
+	}
+	switch d := time.Since(t); {
+	case d < time.Minute:
+		return loc.justNow
+	case d < time.Hour:
+		return ago(int(d/time.Minute), "minute")
+	case d < 24*time.Hour:
+		return ago(int(d/time.Hour), "hour")
+	case d < 30*24*time.Hour:
+		return ago(int(d/(24*time.Hour)), "day")
+	}
+	return t.Format(loc.date)
+}
+
+// formatNumber writes n with its digits grouped in thousands
+func (loc *locale) formatNumber(n int) string {
+	digits := strconv.Itoa(n)
+	sign := ""
+	if n < 0 {
+		sign, digits = "-", digits[1:]
+	}
+	var b strings.Builder
+	for i, c := range digits {
+		if i > 0 && (len(digits)-i)%3 == 0 {
+			b.WriteString(loc.thousands)
+		}
+		b.WriteRune(c)
+	}
+	return sign + b.String()
+}
+
+// funcs are the template functions written in the locale's language,
+// replacing those of templateFuncs that write them for -locale
+func (loc *locale) funcs() template.FuncMap {
+	return template.FuncMap{
+		"formatDate":   loc.formatDate,
+		"relativeTime": loc.relativeTime,
+		"formatNumber": loc.formatNumber,
+	}
+}
+
+// localizedTemplates holds a copy of every theme's templates for each locale,
+// keyed by locale and then like templates, made before any template is run
+// as templates cannot be copied after that
+var localizedTemplates = localizeThemes(templates)
+
+// localizeThemes copies the templates of themes for each of the locales
+func localizeThemes(themes map[string]map[string]*template.Template) map[string]map[string]map[string]*template.Template {
+	names := make([]string, 0, len(locales))
+	for name := range locales {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	out := map[string]map[string]map[string]*template.Template{}
+	for _, name := range names {
+		out[name] = map[string]map[string]*template.Template{}
+		for theme, set := range themes {
+			copies := map[string]*template.Template{}
+			for file, t := range set {
+				copies[file] = template.Must(t.Clone()).Funcs(locales[name].funcs())
+			}
+			out[name][theme] = copies
+		}
+	}
+	return out
+}
+
+// requestTemplate is the template file name of the theme picked for r,
+// in the locale it prefers or else -locale
+func requestTemplate(w http.ResponseWriter, r *http.Request, name string) *template.Template {
+	theme := requestTheme(w, r)
+	w.Header().Add("Vary", "Accept-Language")
+	if loc := requestLocale(r); loc != "" {
+		if t := localizedTemplates[loc][theme][name]; t != nil {
+			return t
+		}
+		if t := localizedTemplates[loc][*defaultTheme][name]; t != nil {
+			return t
+		}
+	}
+	return themeTemplate(theme, name)
+}
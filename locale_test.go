@@ -0,0 +1,65 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLocaleDates(t *testing.T) {
+	when := time.Date(2024, time.March, 5, 14, 7, 0, 0, time.UTC)
+	for _, tc := range []struct {
+		locale, layout, want string
+	}{
+		{"en", "", "2024-03-05 14:07"},
+		{"de", "", "05.03.2024 14:07"},
+		{"en", "date", "2024-03-05"},
+		{"de", "date", "05.03.2024"},
+		{"en", "2 January 2006", "5 March 2024"},
+		{"de", "2 January 2006", "5 März 2024"},
+		{"en", "2 Jan 2006", "5 Mar 2024"},
+		{"de", "2 Jan 2006", "5 Mär 2024"},
+		{"en", "Monday, 2 Jan", "Tuesday, 5 Mar"},
+		{"de", "Monday, 2 Jan", "Dienstag, 5 Mär"},
+	} {
+		var layout []string
+		if tc.layout != "" {
+			layout = []string{tc.layout}
+		}
+		if got := locales[tc.locale].formatDate(when, layout...); got != tc.want {
+			t.Errorf("%s: formatDate(%q) = %q, want %q", tc.locale, tc.layout, got, tc.want)
+		}
+	}
+}
+
+func TestLocaleFromRequest(t *testing.T) {
+	h := newTestWiki(t)
+	writePage(t, "Notes", "text")
+	vs, _ := versions("Notes")
+	when := vs[0].Time
+	for _, tc := range []struct {
+		accept, want string
+	}{
+		{"", when.Format("2006-01-02")},
+		{"de-DE,de;q=0.9,en;q=0.5", when.Format("02.01.2006")},
+		{"en;q=0.4, de;q=0.8", when.Format("02.01.2006")},
+		{"ja", when.Format("2006-01-02")},
+	} {
+		r := get("/history/Notes")
+		r.Header.Set("Accept-Language", tc.accept)
+		w := do(h, r)
+		wantStatus(t, w, http.StatusOK)
+		if body := w.Body.String(); !strings.Contains(body, "<td>"+tc.want+" ") {
+			t.Errorf("Accept-Language %q: history does not show the date as %s", tc.accept, tc.want)
+		}
+		if vary := w.Header().Values("Vary"); !strings.Contains(strings.Join(vary, ","), "Accept-Language") {
+			t.Errorf("Accept-Language %q: Vary %q", tc.accept, vary)
+		}
+	}
+
+	setFlag(t, "locale", "de")
+	if got, want := formatDate(when, "date"), when.Format("02.01.2006"); got != want {
+		t.Errorf("-locale de formats a date as %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+)
+
+// maintenanceRetryAfter is the Retry-After sent while the wiki is in maintenance mode
+const maintenanceRetryAfter = "300"
+
+// maintenanceMode is set while the wiki is closed to everyone but admins
+var maintenanceMode atomic.Bool
+
+// duringMaintenance answers every request with 503 Service Unavailable and
+// the maintenance page while maintenance mode is on,
+// except for admins and the paths in limitExempt
+func duringMaintenance(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if maintenanceMode.Load() && !limitExempt[r.URL.Path] && !isAdmin(r) {
+			w.Header().Set("Retry-After", maintenanceRetryAfter)
+			w.Header().Set("Cache-Control", "no-store")
+			renderTemplateStatus(w, r, "maintenance", http.StatusServiceUnavailable, nil)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// maintenanceHandler turns maintenance mode on or off with POST ?on=true|false
+// and reports whether it is on as JSON
+func maintenanceHandler(w http.ResponseWriter, r *http.Request) {
+	if v := r.FormValue("on"); v != "" {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			errorHandler(w, r, http.StatusMethodNotAllowed, "method not allowed")
+			return
+		}
+		on, err := strconv.ParseBool(v)
+		if err != nil {
+			errorHandler(w, r, http.StatusBadRequest, "on must be true or false")
+			return
+		}
+		maintenanceMode.Store(on)
+		log.Printf("maintenance mode set to %t by %s", on, clientID(r))
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(struct {
+		Maintenance bool `json:"maintenance"`
+	}{maintenanceMode.Load()})
+}
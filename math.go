@@ -0,0 +1,47 @@
+package main
+
+import (
+	"flag"
+	"html/template"
+	"regexp"
+	"strings"
+)
+
+// enableMath passes $...$ and $$...$$ math through the renderer untouched
+// for KaTeX to typeset in the browser
+var enableMath = flag.Bool("math", false, "render $...$ and $$...$$ as math with KaTeX, which must be installed under static/katex/")
+
+// mathDelim is a line on its own opening or closing a block of display math
+const mathDelim = "$$"
+
+// mathSpan matches inline $$display$$ and $inline$ math in escaped text
+// like pandoc, inline math may not start or end with a space
+// and its closing $ may not be followed by a digit, so prices are left alone
+var mathSpan = regexp.MustCompile(`\$\$[^$]+\$\$|\$[^$\s](?:[^$]*[^$\s])?\$`)
+
+// holdMath sets aside the math in escaped text s, so that no other inline
+// markup touches the underscores, asterisks and backslashes inside it
+func holdMath(s string, in *inlineHTML) string {
+	var out strings.Builder
+	last := 0
+	for _, m := range mathSpan.FindAllStringIndex(s, -1) {
+		if m[1] < len(s) && s[m[1]] >= '0' && s[m[1]] <= '9' {
+			continue
+		}
+		out.WriteString(s[last:m[0]])
+		math := s[m[0]:m[1]]
+		if strings.HasPrefix(math, mathDelim) {
+			out.WriteString(in.hold(`<span class="math display">` + math[2:len(math)-2] + "</span>"))
+		} else {
+			out.WriteString(in.hold(`<span class="math inline">` + math[1:len(math)-1] + "</span>"))
+		}
+		last = m[1]
+	}
+	out.WriteString(s[last:])
+	return out.String()
+}
+
+// mathBlock renders the lines between a pair of $$ lines as display math
+func mathBlock(lines []string) string {
+	return `<div class="math display">` + template.HTMLEscapeString(strings.Join(lines, "\n")) + "</div>\n"
+}
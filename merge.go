@@ -0,0 +1,179 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// auditMerge is recorded for both pages of a merge
+const auditMerge = "merge"
+
+// mergeReport is the JSON response of /admin/merge
+// Renamed is true when the target did not exist and the source was renamed to it
+type mergeReport struct {
+	Source     string        `json:"source"`
+	Target     string        `json:"target"`
+	Renamed    bool          `json:"renamed"`
+	Redirected bool          `json:"redirected"`
+	Relinked   []replacement `json:"relinked"`
+}
+
+// outsideCode applies fn to the parts of line that are not code spans
+func outsideCode(line string, fn func(string) string) string {
+	var b strings.Builder
+	last := 0
+	for _, m := range codeSpan.FindAllStringIndex(line, -1) {
+		b.WriteString(fn(line[last:m[0]]))
+		b.WriteString(line[m[0]:m[1]])
+		last = m[1]
+	}
+	b.WriteString(fn(line[last:]))
+	return b.String()
+}
+
+// linkRewriter builds the replacer pointing wikilinks to the page from,
+// with or without a section, and includes of it at the page to instead
+// links inside code spans, fenced blocks and Markdown link text are left alone
+func linkRewriter(from, to string) replacer {
+	link := regexp.MustCompile(`\[` + from + `(#[^\[\]]+)?\](\(?)|\{\{include:` + from + `([#}])`)
+	return func(body []byte) ([]byte, int) {
+		n := 0
+		rewrite := func(m string) string {
+			sm := link.FindStringSubmatch(m)
+			if sm[2] == "(" {
+				return m
+			}
+			n++
+			if strings.HasPrefix(m, "{{") {
+				return "{{include:" + to + sm[3]
+			}
+			return "[" + to + sm[1] + "]"
+		}
+		lines := strings.Split(string(body), "\n")
+		inFence := false
+		for i, line := range lines {
+			if isFence(line) {
+				inFence = !inFence
+				continue
+			}
+			if !inFence {
+				lines[i] = outsideCode(line, func(s string) string { return link.ReplaceAllStringFunc(s, rewrite) })
+			}
+		}
+		if n == 0 {
+			return body, 0
+		}
+		return []byte(strings.Join(lines, "\n")), n
+	}
+}
+
+// mergedBody appends the content of source to the body of target,
+// under a heading naming the page it came from
+func mergedBody(target, source *Page) []byte {
+	body := strings.TrimRight(string(target.Body), "\n")
+	return []byte(body + "\n\n## " + source.DisplayTitle() + "\n\n" + strings.TrimLeft(string(source.Content), "\n"))
+}
+
+// mergePage merges the page source into target, renaming it when target does
+// not exist, and then deletes source or, with redirect, leaves a redirect
+// to target in its place
+// both pages are locked throughout, in title order so that two merges
+// of the same pair cannot deadlock
+func mergePage(r *http.Request, source, target string, redirect bool) (renamed bool, err error) {
+	first, second := source, target
+	if second < first {
+		first, second = second, first
+	}
+	defer lockPage(first)()
+	defer lockPage(second)()
+
+	src, err := loadPage(source)
+	if err != nil {
+		return false, err
+	}
+	merged := newPage(target, src.Body)
+	merged.Summary = "renamed from " + source
+	if dst, err := loadPage(target); err == nil {
+		merged = newPage(target, mergedBody(dst, src))
+		merged.Summary = "merged " + source + " into " + target
+	} else if !os.IsNotExist(err) {
+		return false, err
+	} else {
+		renamed = true
+	}
+	merged.Editor = editorName(r)
+	if err := merged.write(); err != nil {
+		return false, err
+	}
+
+	if redirect {
+		p := newPage(source, []byte("#REDIRECT ["+target+"]\n"))
+		p.Summary, p.Editor = merged.Summary, merged.Editor
+		return renamed, p.write()
+	}
+	return renamed, removePage(source)
+}
+
+// mergeHandler merges the page POSTed as source into the page target,
+// appending its content, pointing every wikilink to source at target and
+// deleting source, or replacing it with a redirect to target when redirect=1
+// a target that does not exist yet makes this a rename
+func mergeHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		errorHandler(w, r, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	source, target := r.FormValue("source"), r.FormValue("target")
+	for _, title := range []string{source, target} {
+		if err := validateTitle(title); err != nil {
+			errorHandler(w, r, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+	if source == target {
+		errorHandler(w, r, http.StatusBadRequest, "cannot merge a page into itself")
+		return
+	}
+
+	report := mergeReport{Source: source, Target: target, Redirected: r.FormValue("redirect") == "1"}
+	renamed, err := mergePage(r, source, target, report.Redirected)
+	if errors.Is(err, os.ErrNotExist) {
+		errorHandler(w, r, http.StatusNotFound, "page "+source+" does not exist")
+		return
+	}
+	if err != nil {
+		errorHandler(w, r, http.StatusInternalServerError, err.Error())
+		return
+	}
+	report.Renamed = renamed
+	recordAudit(r, auditMerge, source)
+	recordAudit(r, auditMerge, target)
+
+	fn := linkRewriter(source, target)
+	changes, err := previewReplace(r.Context(), fn)
+	if err != nil {
+		errorHandler(w, r, http.StatusInternalServerError, err.Error())
+		return
+	}
+	report.Relinked = []replacement{}
+	for i := range changes {
+		c := &changes[i]
+		if err := applyReplace(c, fn, editorName(r), "relinked "+source+" to "+target); err != nil {
+			errorHandler(w, r, http.StatusInternalServerError, err.Error())
+			return
+		}
+		if c.Count > 0 {
+			recordAudit(r, auditReplace, c.Title)
+			report.Relinked = append(report.Relinked, *c)
+		}
+	}
+	log.Printf("merged %s into %s, relinking %d pages", source, target, len(report.Relinked))
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"flag"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// noMethodOverride stops POSTed forms from standing in for other methods
+var noMethodOverride = flag.Bool("no-method-override", false, "do not treat POST requests carrying a _method form field or an X-HTTP-Method-Override header as that method")
+
+// overridable lists the methods a POST may be treated as
+var overridable = map[string]bool{
+	http.MethodPut:    true,
+	http.MethodPatch:  true,
+	http.MethodDelete: true,
+}
+
+// overrideMethod lets HTML forms, which can only GET and POST, reach handlers
+// of other methods: a POST with an X-HTTP-Method-Override header, or a _method
+// field in its url encoded form, is handed to next as that method
+// overriding to anything but PUT, PATCH or DELETE is an HTTP Bad Request error
+func overrideMethod(next http.Handler) http.Handler {
+	if *noMethodOverride {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			next.ServeHTTP(w, r)
+			return
+		}
+		m := r.Header.Get("X-HTTP-Method-Override")
+		if m == "" && strings.HasPrefix(r.Header.Get("Content-Type"), "application/x-www-form-urlencoded") {
+			if !parseBody(w, r) {
+				return
+			}
+			m = r.PostFormValue("_method")
+		}
+		if m != "" {
+			m = strings.ToUpper(m)
+			if !overridable[m] {
+				errorHandler(w, r, http.StatusBadRequest, "cannot override POST with "+m)
+				return
+			}
+			r.Method = m
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// auditDelete is recorded when a page is deleted
+const auditDelete = "delete"
+
+// deleteHandler deletes a Page on DELETE /delete/{Page.Title}, which the view
+// page's delete form sends as a POST overridden with _method=DELETE, and then
+// redirects to the front page
+// the saved versions of the page are kept so that it can be restored
+func deleteHandler(w http.ResponseWriter, r *http.Request, title string) {
+	if r.Method != http.MethodDelete {
+		w.Header().Set("Allow", http.MethodDelete)
+		errorHandler(w, r, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	deletePage(w, r, title)
+}
+
+// deletePage deletes title on behalf of the client behind r,
+// who must be able to see it, and redirects to the front page
+// a delete counts as an edit, held to -edit-cooldown and -daily-edit-quota
+func deletePage(w http.ResponseWriter, r *http.Request, title string) {
+	if coolingDown(w, r, title) || overQuota(w, r) {
+		return
+	}
+	unlock := lockPage(title)
+	defer unlock()
+	p, err := loadPage(title)
+	if err != nil {
+		notFound(w, r)
+		return
+	}
+	if denyView(w, r, p) {
+		return
+	}
+	if err := removePage(title); err != nil {
+		errorHandler(w, r, http.StatusInternalServerError, err.Error())
+		return
+	}
+	recordSave(title)
+	recordEdit(r, time.Now())
+	recordAudit(r, auditDelete, title)
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
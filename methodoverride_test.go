@@ -0,0 +1,66 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+)
+
+func TestDeleteFormOverride(t *testing.T) {
+	h := newTestWiki(t)
+	writePage(t, "Gone", "text")
+
+	wantStatus(t, do(h, get("/delete/Gone")), http.StatusMethodNotAllowed)
+	wantStatus(t, do(h, postForm("/delete/Gone", nil)), http.StatusMethodNotAllowed)
+	if !pageExists("Gone") {
+		t.Fatal("a request that is not a DELETE removed the page")
+	}
+
+	w := do(h, deleteForm("Gone"))
+	wantStatus(t, w, http.StatusSeeOther)
+	if loc := w.Header().Get("Location"); loc != "/" {
+		t.Errorf("delete redirects to %q, want /", loc)
+	}
+	if pageExists("Gone") {
+		t.Error("the delete form did not remove the page")
+	}
+	entries, err := tailAudit(1)
+	if err != nil || len(entries) != 1 || entries[0].Action != auditDelete || entries[0].Title != "Gone" {
+		t.Errorf("audit log %v, %v", entries, err)
+	}
+	wantStatus(t, do(h, deleteForm("Gone")), http.StatusNotFound)
+}
+
+func TestMethodOverrideHeader(t *testing.T) {
+	h := newTestWiki(t)
+	writePage(t, "Gone", "text")
+	r := postForm("/delete/Gone", nil)
+	r.Header.Set("X-HTTP-Method-Override", "delete")
+	wantStatus(t, do(h, r), http.StatusSeeOther)
+	if pageExists("Gone") {
+		t.Error("the override header did not remove the page")
+	}
+}
+
+func TestMethodOverrideDisallowed(t *testing.T) {
+	h := newTestWiki(t)
+	writePage(t, "Kept", "text")
+	for _, m := range []string{"GET", "TRACE", "CONNECT"} {
+		wantStatus(t, do(h, postForm("/delete/Kept", url.Values{"_method": {m}})), http.StatusBadRequest)
+		r := postForm("/delete/Kept", nil)
+		r.Header.Set("X-HTTP-Method-Override", m)
+		wantStatus(t, do(h, r), http.StatusBadRequest)
+	}
+	if !pageExists("Kept") {
+		t.Error("a disallowed override removed the page")
+	}
+}
+
+func TestNoMethodOverride(t *testing.T) {
+	h := newTestWiki(t, "no-method-override=true")
+	writePage(t, "Kept", "text")
+	wantStatus(t, do(h, deleteForm("Kept")), http.StatusMethodNotAllowed)
+	if !pageExists("Kept") {
+		t.Error("-no-method-override let the delete form remove the page")
+	}
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"flag"
+	"net/http"
+)
+
+// maxConcurrent caps the number of requests handled at once
+var maxConcurrent = flag.Int("max-concurrent", 0, "maximum number of requests handled at once, excess requests get 503 Service Unavailable (0 = unlimited)")
+
+// limitExempt lists the paths that are always served, however busy the server is,
+// so that health checks keep working under load
+var limitExempt = map[string]bool{
+	"/healthz": true,
+}
+
+// limitConcurrency lets at most n requests through to next at a time,
+// any more are shed immediately with an HTTP Service Unavailable error
+// and a Retry-After header rather than queuing up
+// n <= 0 disables the limit
+func limitConcurrency(n int, next http.Handler) http.Handler {
+	if n <= 0 {
+		return next
+	}
+	sem := make(chan struct{}, n)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if limitExempt[r.URL.Path] {
+			next.ServeHTTP(w, r)
+			return
+		}
+		select {
+		case sem <- struct{}{}:
+			defer func() { <-sem }()
+			next.ServeHTTP(w, r)
+		default:
+			w.Header().Set("Retry-After", "1")
+			errorHandler(w, r, http.StatusServiceUnavailable, "server is busy, please try again shortly")
+		}
+	})
+}
+
+// healthHandler reports that the server is up
+func healthHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Write([]byte("ok\n"))
+}
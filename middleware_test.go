@@ -0,0 +1,49 @@
+package main
+
+import (
+	"net/http"
+	"sync"
+	"testing"
+)
+
+func TestLimitConcurrencySheds(t *testing.T) {
+	newTestWiki(t)
+	const n = 3
+	started, release := make(chan struct{}), make(chan struct{})
+	h := limitConcurrency(n, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/healthz" {
+			healthHandler(w, r)
+			return
+		}
+		started <- struct{}{}
+		<-release
+	}))
+
+	var wg sync.WaitGroup
+	codes := make([]int, n)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			codes[i] = do(h, get("/view/Page")).Code
+		}(i)
+		<-started
+	}
+
+	w := do(h, get("/view/Page"))
+	wantStatus(t, w, http.StatusServiceUnavailable)
+	if w.Header().Get("Retry-After") == "" {
+		t.Error("shed request has no Retry-After header")
+	}
+	wantStatus(t, do(h, get("/healthz")), http.StatusOK)
+
+	close(release)
+	wg.Wait()
+	for i, code := range codes {
+		if code != http.StatusOK {
+			t.Errorf("request %d within the limit got status %d", i, code)
+		}
+	}
+	go func() { <-started }()
+	wantStatus(t, do(h, get("/view/Page")), http.StatusOK) // the freed slots take requests again
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// cidrList is a repeatable flag holding CIDR blocks, parsed as the flags are
+type cidrList []*net.IPNet
+
+func (l *cidrList) String() string {
+	var s []string
+	for _, n := range *l {
+		s = append(s, n.String())
+	}
+	return strings.Join(s, ",")
+}
+
+// Set parses one CIDR block, a bare address is taken as a block of just itself
+func (l *cidrList) Set(value string) error {
+	if !strings.Contains(value, "/") {
+		ip := net.ParseIP(value)
+		if ip == nil {
+			return fmt.Errorf("invalid address %q", value)
+		}
+		bits := 8 * net.IPv6len
+		if ip.To4() != nil {
+			bits = 8 * net.IPv4len
+		}
+		value = fmt.Sprintf("%s/%d", value, bits)
+	}
+	_, n, err := net.ParseCIDR(value)
+	if err != nil {
+		return err
+	}
+	*l = append(*l, n)
+	return nil
+}
+
+// contains reports whether ip falls within any of the blocks
+func (l cidrList) contains(ip net.IP) bool {
+	for _, n := range l {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// network access settings, each flag may be given several times
+var (
+	editAllow      cidrList
+	trustedProxies cidrList
+)
+
+func init() {
+	flag.Var(&editAllow, "edit-allow-cidr", "CIDR block allowed to edit pages, may be repeated (default: any address)")
+	flag.Var(&trustedProxies, "trusted-proxy", "CIDR block of reverse proxies whose X-Forwarded-For header is believed, may be repeated")
+}
+
+// clientIP is the address of the client behind r, nil if it cannot be told
+// X-Forwarded-For is only followed through hops made by trusted proxies,
+// so a client cannot pick its own address by sending the header itself
+func clientIP(r *http.Request) net.IP {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || !trustedProxies.contains(ip) {
+		return ip
+	}
+	hops := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := net.ParseIP(strings.TrimSpace(hops[i]))
+		if hop == nil {
+			break
+		}
+		ip = hop
+		if !trustedProxies.contains(ip) {
+			break
+		}
+	}
+	return ip
+}
+
+// requireEditNetwork rejects requests with 403 Forbidden unless they come
+// from an address in -edit-allow-cidr, when any blocks are configured
+func requireEditNetwork(fn http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if len(editAllow) > 0 {
+			if ip := clientIP(r); ip == nil || !editAllow.contains(ip) {
+				errorHandler(w, r, http.StatusForbidden, "editing is not allowed from your network")
+				return
+			}
+		}
+		fn(w, r)
+	}
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"flag"
+	"net/url"
+)
+
+// settings for links to pages that do not exist yet
+var (
+	editMissingLinks = flag.Bool("edit-missing-links", false, "link wikilinks to pages that do not exist yet straight to their edit form instead of /view/")
+	backlinkNewPages = flag.Bool("backlink-new-pages", false, "with -edit-missing-links, start a page created from a wikilink with a note linking back to the page the link was on")
+)
+
+// missingHref is the url a wikilink on the page from points to when its
+// target title does not exist yet, /edit/{title} under -edit-missing-links
+// with ?from= the linking page under -backlink-new-pages
+// while the wiki is read-only the link stays on /view/ as nothing can be created
+func missingHref(title, from string) string {
+	if !*editMissingLinks || isReadOnly() {
+		return "/view/" + title
+	}
+	href := "/edit/" + title
+	if *backlinkNewPages && from != "" && from != title {
+		href += "?from=" + url.QueryEscape(from)
+	}
+	return href
+}
+
+// backlinkBody is the text a new page created from a wikilink on the page
+// from starts with under -backlink-new-pages, empty if from is not a page
+func backlinkBody(from string) []byte {
+	if !*backlinkNewPages || validateTitle(from) != nil || !pageExists(from) {
+		return nil
+	}
+	return []byte("Created from a link on [" + from + "].\n")
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestEditMissingLinks(t *testing.T) {
+	for _, theme := range []string{"classic", "modern"} {
+		t.Run(theme, func(t *testing.T) { testEditMissingLinks(t, theme) })
+	}
+}
+
+// testEditMissingLinks checks where the links to missing pages of a view
+// in theme point with and without -edit-missing-links
+func testEditMissingLinks(t *testing.T, theme string) {
+	h := newTestWiki(t, "theme="+theme)
+	writePage(t, "Home", "See [Existing] and [Missing#Part].")
+	writePage(t, "Existing", "here")
+
+	body := do(h, get("/view/Home")).Body.String()
+	for _, want := range []string{
+		`<a href="/view/Missing#part" class="new-page">Missing#Part</a>`,
+		`<li><a href="/view/Missing" class="new-page">Missing</a></li>`,
+		`<li><a href="/view/Existing">Existing</a></li>`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("view without -edit-missing-links lacks %s", want)
+		}
+	}
+
+	setFlag(t, "edit-missing-links", "true")
+	setFlag(t, "backlink-new-pages", "true")
+	w := do(h, get("/view/Home"))
+	wantStatus(t, w, http.StatusOK)
+	body = w.Body.String()
+	for _, want := range []string{
+		`<a href="/edit/Missing?from=Home" class="new-page">Missing#Part</a>`,
+		`<li><a href="/edit/Missing?from=Home" class="new-page">Missing</a></li>`,
+		`<li><a href="/view/Existing">Existing</a></li>`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("view with -edit-missing-links lacks %s", want)
+		}
+	}
+
+	setFlag(t, "readonly", "true")
+	if body := do(h, get("/view/Home")).Body.String(); strings.Contains(body, "/edit/Missing") {
+		t.Error("a read-only wiki links to the edit form of a missing page")
+	}
+}
+
+func TestBacklinkBody(t *testing.T) {
+	h := newTestWiki(t, "edit-missing-links=true", "backlink-new-pages=true")
+	writePage(t, "Home", "See [Missing].")
+	body := do(h, get("/edit/Missing?from=Home")).Body.String()
+	if !strings.Contains(body, "Created from a link on [Home].") {
+		t.Errorf("the edit form of a page created from a link does not start with a backlink:\n%s", body)
+	}
+	body = do(h, get("/edit/Missing?from=Nowhere")).Body.String()
+	if strings.Contains(body, "Created from a link on") {
+		t.Error("a page created from a link on a missing page starts with a backlink")
+	}
+}
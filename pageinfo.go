@@ -0,0 +1,37 @@
+package main
+
+import (
+	"flag"
+	"strings"
+	"time"
+)
+
+// metaSidebar shows a panel of facts about the page next to it when viewing it
+var metaSidebar = flag.Bool("meta-sidebar", false, "show a sidebar with each page's dates, author, tags, word count and backlinks")
+
+// pageInfo is the data shown in a page's metadata sidebar,
+// zero valued fields are left out
+type pageInfo struct {
+	Created   time.Time
+	Modified  time.Time
+	Author    string
+	Tags      []string
+	Words     int
+	Backlinks int
+}
+
+// newPageInfo gathers the metadata sidebar of v, the page being viewed
+// it was created when its oldest version was saved, if history is kept
+func newPageInfo(v *viewPage) *pageInfo {
+	info := &pageInfo{
+		Modified:  v.ModTime,
+		Author:    v.Meta.Author,
+		Tags:      v.Meta.Tags,
+		Words:     len(strings.Fields(string(v.Content))),
+		Backlinks: len(v.Backlinks),
+	}
+	if vs, err := versions(v.Title); err == nil && len(vs) > 0 {
+		info.Created = vs[0].Time
+	}
+	return info
+}
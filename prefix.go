@@ -0,0 +1,80 @@
+package main
+
+import (
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// prefixPath matches /prefix/{prefix}, an empty prefix listing every page
+var prefixPath = regexp.MustCompile("^/prefix/([a-zA-Z0-9]*)$")
+
+// maxPrefixListing caps how many pages a prefix listing shows,
+// so a very broad prefix does not produce an enormous page
+const maxPrefixListing = 500
+
+// prefixPage is the data for the prefix template
+type prefixPage struct {
+	Prefix   string
+	Titles   []string
+	Excerpts map[string]string // page title -> excerpt
+	Total    int
+}
+
+// pagesWithPrefix lists the titles starting with prefix, ignoring case,
+// sorted case-insensitively
+func pagesWithPrefix(prefix string) ([]string, error) {
+	titles, err := listPages()
+	if err != nil {
+		return nil, err
+	}
+	prefix = strings.ToLower(prefix)
+	var matches []string
+	for _, title := range titles {
+		if strings.HasPrefix(strings.ToLower(title), prefix) {
+			matches = append(matches, title)
+		}
+	}
+	sort.SliceStable(matches, func(i, j int) bool { return strings.ToLower(matches[i]) < strings.ToLower(matches[j]) })
+	return matches, nil
+}
+
+// prefixHandler lists the pages whose titles start with a prefix,
+// giving pages named like MeetingJan, MeetingFeb a browsable index
+// ?prefix= on /prefix/ is accepted too, for use from a form
+func prefixHandler(w http.ResponseWriter, r *http.Request) {
+	m := prefixPath.FindStringSubmatch(r.URL.Path)
+	if m == nil {
+		notFound(w, r)
+		return
+	}
+	prefix := m[1]
+	if q := r.FormValue("prefix"); prefix == "" && q != "" {
+		if !validTitle.MatchString(q) {
+			errorHandler(w, r, http.StatusBadRequest, "prefix may only contain letters and digits")
+			return
+		}
+		http.Redirect(w, r, "/prefix/"+q, http.StatusFound)
+		return
+	}
+	titles, err := pagesWithPrefix(prefix)
+	if err != nil {
+		errorHandler(w, r, http.StatusInternalServerError, err.Error())
+		return
+	}
+	titles = visiblePages(r, titles)
+	p := &prefixPage{Prefix: prefix, Titles: titles, Total: len(titles)}
+	if len(p.Titles) > maxPrefixListing {
+		p.Titles = p.Titles[:maxPrefixListing]
+	}
+	if *excerptLength > 0 {
+		p.Excerpts = map[string]string{}
+		for _, title := range p.Titles {
+			if page, err := loadPage(title); err == nil {
+				p.Excerpts[title] = pageExcerpt(page, *excerptLength)
+			}
+		}
+	}
+	renderTemplate(w, r, "prefix", p)
+}
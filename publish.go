@@ -0,0 +1,116 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+)
+
+// publishDir is where static snapshots of the wiki are written
+var publishDir = flag.String("publish-dir", "public", "output directory for static HTML snapshots of the wiki")
+
+// viewHref matches links to /view/{Page.Title} in rendered HTML,
+// which become links to the sibling {Page.Title}.html file in a snapshot
+var viewHref = regexp.MustCompile(`href="/view/([a-zA-Z0-9]+)`)
+
+// publishSummary reports the outcome of publishing a snapshot
+type publishSummary struct {
+	Written   int    `json:"written"`
+	Unchanged int    `json:"unchanged"`
+	Dir       string `json:"dir"`
+}
+
+// publish writes every page to -publish-dir as a standalone {Page.Title}.html
+// using the default theme's export template, plus an index.html listing
+// the pages that are not archived, pages that are not public are left out
+// pages whose snapshot is newer than the page itself are left alone
+func publish() (*publishSummary, error) {
+	if err := os.MkdirAll(*publishDir, 0755); err != nil {
+		return nil, err
+	}
+	titles, err := listPages()
+	if err != nil {
+		return nil, err
+	}
+
+	summary := &publishSummary{Dir: *publishDir}
+	var listed []string
+	for _, title := range titles {
+		p, err := loadPage(title)
+		if err != nil {
+			return nil, err
+		}
+		if !p.Meta.public() {
+			// a page that stopped being public must not linger in the snapshot
+			if err := os.Remove(filepath.Join(*publishDir, title+".html")); err != nil && !os.IsNotExist(err) {
+				return nil, err
+			}
+			continue
+		}
+		if !p.Meta.Archived {
+			listed = append(listed, title)
+		}
+		out := filepath.Join(*publishDir, title+".html")
+		if upToDate(out, pageFile(title)) {
+			summary.Unchanged++
+			continue
+		}
+		if err := publishTemplate(out, "export.html", newViewPage(p)); err != nil {
+			return nil, err
+		}
+		summary.Written++
+	}
+	if err := publishTemplate(filepath.Join(*publishDir, "index.html"), "exportindex.html", listed); err != nil {
+		return nil, err
+	}
+	summary.Written++
+	return summary, nil
+}
+
+// upToDate reports whether the file out was modified after src
+func upToDate(out, src string) bool {
+	o, err := os.Stat(out)
+	if err != nil {
+		return false
+	}
+	s, err := os.Stat(src)
+	if err != nil {
+		return false
+	}
+	return o.ModTime().After(s.ModTime())
+}
+
+// publishTemplate renders the default theme's template name with data into
+// the file out, rewriting wiki links to point at the snapshot's files
+func publishTemplate(out, name string, data interface{}) error {
+	var buf bytes.Buffer
+	if err := themeTemplate(*defaultTheme, name).Execute(&buf, data); err != nil {
+		return err
+	}
+	html := viewHref.ReplaceAll(buf.Bytes(), []byte(`href="$1.html`))
+	return ioutil.WriteFile(out, html, 0644)
+}
+
+// publishHandler writes a static snapshot of the wiki
+// and reports how many files were written as JSON
+func publishHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		errorHandler(w, r, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	summary, err := publish()
+	if err != nil {
+		errorHandler(w, r, http.StatusInternalServerError, err.Error())
+		return
+	}
+	log.Printf("published %d files to %s (%d unchanged)", summary.Written, summary.Dir, summary.Unchanged)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(summary)
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"mime"
+	"net/http"
+	"path"
+)
+
+// settings for installing the wiki as a progressive web app
+var (
+	enablePWA     = flag.Bool("pwa", false, "serve a web app manifest and an offline service worker so the wiki can be installed as an app")
+	appName       = flag.String("app-name", "gowiki", "name of the installed app")
+	appShortName  = flag.String("app-short-name", "wiki", "short name of the installed app, shown under its icon")
+	appThemeColor = flag.String("app-theme-color", "#ffffff", "theme color of the installed app")
+	appIcon       = flag.String("app-icon", "", "URL of the installed app's icon, e.g. /static/icon.png")
+)
+
+// manifestIcon is an icon entry of a web app manifest
+type manifestIcon struct {
+	Src   string `json:"src"`
+	Sizes string `json:"sizes"`
+	Type  string `json:"type,omitempty"`
+}
+
+// webManifest is the subset of the web app manifest the wiki fills in
+type webManifest struct {
+	Name            string         `json:"name"`
+	ShortName       string         `json:"short_name"`
+	StartURL        string         `json:"start_url"`
+	Scope           string         `json:"scope"`
+	Display         string         `json:"display"`
+	ThemeColor      string         `json:"theme_color"`
+	BackgroundColor string         `json:"background_color"`
+	Icons           []manifestIcon `json:"icons"`
+}
+
+// manifestHandler serves /manifest.webmanifest built from the -app-* flags
+func manifestHandler(w http.ResponseWriter, r *http.Request) {
+	m := webManifest{
+		Name:            *appName,
+		ShortName:       *appShortName,
+		StartURL:        "/",
+		Scope:           "/",
+		Display:         "standalone",
+		ThemeColor:      *appThemeColor,
+		BackgroundColor: *appThemeColor,
+		Icons:           []manifestIcon{},
+	}
+	if *appIcon != "" {
+		m.Icons = append(m.Icons, manifestIcon{Src: *appIcon, Sizes: "any", Type: mime.TypeByExtension(path.Ext(*appIcon))})
+	}
+	w.Header().Set("Content-Type", "application/manifest+json")
+	json.NewEncoder(w).Encode(m)
+}
+
+// serviceWorkerHandler serves static/sw.js as /sw.js,
+// a service worker only controlling the pages at or below the path it is served from
+func serviceWorkerHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "no-cache")
+	http.ServeFile(w, r, "static/sw.js")
+}
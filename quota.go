@@ -0,0 +1,69 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// dailyEditQuota caps the number of edits a single client address may make per day
+var dailyEditQuota = flag.Int("daily-edit-quota", 0, "maximum number of edits per client IP address per day, admins are exempt (0 = unlimited)")
+
+// editCounts counts the edits made today by each client address
+// the counts start over when the day changes
+var editCounts = struct {
+	sync.Mutex
+	day    string
+	counts map[string]int
+}{counts: map[string]int{}}
+
+// quotaKey identifies the client address behind r for the edit quota
+func quotaKey(r *http.Request) string {
+	if ip := clientIP(r); ip != nil {
+		return ip.String()
+	}
+	return r.RemoteAddr
+}
+
+// quotaResetIn returns how long until the client behind r may edit again,
+// zero if it has not used up today's edits yet
+func quotaResetIn(r *http.Request, now time.Time) time.Duration {
+	if *dailyEditQuota <= 0 || isAdmin(r) {
+		return 0
+	}
+	editCounts.Lock()
+	defer editCounts.Unlock()
+	if editCounts.day != now.Format("2006-01-02") || editCounts.counts[quotaKey(r)] < *dailyEditQuota {
+		return 0
+	}
+	y, m, d := now.Date()
+	return time.Date(y, m, d+1, 0, 0, 0, 0, now.Location()).Sub(now)
+}
+
+// recordEdit counts an edit by the client behind r against today's quota
+func recordEdit(r *http.Request, now time.Time) {
+	if *dailyEditQuota <= 0 || isAdmin(r) {
+		return
+	}
+	editCounts.Lock()
+	defer editCounts.Unlock()
+	if day := now.Format("2006-01-02"); editCounts.day != day {
+		editCounts.day = day
+		editCounts.counts = map[string]int{}
+	}
+	editCounts.counts[quotaKey(r)]++
+}
+
+// overQuota rejects the request with HTTP Too Many Requests if the client behind r
+// has used up today's edits, reporting whether it did so
+func overQuota(w http.ResponseWriter, r *http.Request) bool {
+	wait := quotaResetIn(r, time.Now())
+	if wait <= 0 {
+		return false
+	}
+	w.Header().Set("Retry-After", retryAfter(wait))
+	errorHandler(w, r, http.StatusTooManyRequests, fmt.Sprintf("you have reached the limit of %d edits per day, it resets at midnight, in %s", *dailyEditQuota, wait.Round(time.Minute)))
+	return true
+}
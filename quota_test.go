@@ -0,0 +1,85 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+)
+
+// deleteForm is the view page's delete form for title
+func deleteForm(title string) *http.Request {
+	return postForm("/delete/"+title, url.Values{"_method": {"DELETE"}})
+}
+
+func TestDailyEditQuota(t *testing.T) {
+	h := newTestWiki(t, "daily-edit-quota=2", "admin-user=admin", "admin-pass=pass")
+	writePage(t, "Keep", "text")
+
+	wantStatus(t, do(h, postForm("/save/One", url.Values{"body": {"one"}})), http.StatusFound)
+	wantStatus(t, do(h, postForm("/save/Two", url.Values{"body": {"two"}})), http.StatusFound)
+	w := do(h, postForm("/save/Three", url.Values{"body": {"three"}}))
+	wantStatus(t, w, http.StatusTooManyRequests)
+	if w.Header().Get("Retry-After") == "" {
+		t.Error("no Retry-After on an exceeded quota")
+	}
+	if pageExists("Three") {
+		t.Error("a save over the quota was written")
+	}
+
+	wantStatus(t, do(h, deleteForm("Keep")), http.StatusTooManyRequests)
+	if !pageExists("Keep") {
+		t.Error("a delete over the quota removed the page")
+	}
+	wantStatus(t, do(h, asAdmin(postForm("/save/Three", url.Values{"body": {"three"}}))), http.StatusFound)
+}
+
+func TestDeleteCountsAgainstQuota(t *testing.T) {
+	h := newTestWiki(t, "daily-edit-quota=1", "delete-empty-pages=true")
+	writePage(t, "First", "text")
+	writePage(t, "Second", "text")
+
+	wantStatus(t, do(h, deleteForm("First")), http.StatusSeeOther)
+	w := do(h, postForm("/save/Second", url.Values{"body": {""}, "delete": {"1"}}))
+	wantStatus(t, w, http.StatusTooManyRequests)
+	if !pageExists("Second") {
+		t.Error("saving an empty page over the quota deleted it")
+	}
+}
+
+func TestDeleteCooldown(t *testing.T) {
+	h := newTestWiki(t, "edit-cooldown=1h", "delete-empty-pages=true")
+	wantStatus(t, do(h, postForm("/save/Notes", url.Values{"body": {"text"}})), http.StatusFound)
+
+	wantStatus(t, do(h, deleteForm("Notes")), http.StatusTooManyRequests)
+	wantStatus(t, do(h, postForm("/save/Notes", url.Values{"body": {""}, "delete": {"1"}})), http.StatusTooManyRequests)
+	if !pageExists("Notes") {
+		t.Error("a page saved moments ago was deleted")
+	}
+}
+
+func TestSaveCooldown(t *testing.T) {
+	h := newTestWiki(t, "edit-cooldown=1m", "admin-user=admin", "admin-pass=pass")
+	save := func(body string) *http.Request { return postForm("/save/Notes", url.Values{"body": {body}}) }
+	wantStatus(t, do(h, save("first")), http.StatusFound)
+
+	w := do(h, save("second"))
+	wantStatus(t, w, http.StatusTooManyRequests)
+	if ra := w.Header().Get("Retry-After"); ra != "60" && ra != "59" {
+		t.Errorf("Retry-After %q during a 1m cooldown", ra)
+	}
+	if got := readPage(t, "Notes"); got != "first" {
+		t.Errorf("a save during the cooldown changed the page to %q", got)
+	}
+	wantStatus(t, do(h, postForm("/save/Other", url.Values{"body": {"text"}})), http.StatusFound)
+	wantStatus(t, do(h, asAdmin(save("by admin"))), http.StatusFound)
+
+	lastSaves.Lock()
+	lastSaves.at["Notes"] = time.Now().Add(-time.Minute)
+	lastSaves.Unlock()
+	wantStatus(t, do(h, save("after")), http.StatusFound)
+	if got := readPage(t, "Notes"); got != "after" {
+		t.Errorf("a save after the cooldown left the page as %q", got)
+	}
+	wantStatus(t, do(h, save("again")), http.StatusTooManyRequests)
+}
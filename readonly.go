@@ -0,0 +1,82 @@
+package main
+
+import (
+	"flag"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// readOnlyFlag serves the wiki without allowing any changes, which also
+// happens on its own when the data directory turns out not to be writable
+var readOnlyFlag = flag.Bool("readonly", false, "serve pages without allowing edits")
+
+// readOnlyRecheck is how often storage found to be read-only is probed again,
+// so that the wiki starts accepting edits once it is remounted writable
+const readOnlyRecheck = time.Minute
+
+// readOnly tracks whether the data directory could be written at the last probe
+var readOnly = struct {
+	sync.Mutex
+	on      bool
+	checked time.Time
+}{}
+
+// probeWritable reports whether a file can be created in the data directory
+func probeWritable() error {
+	f, err := ioutil.TempFile("data", ".probe-")
+	if err != nil {
+		return err
+	}
+	f.Close()
+	return os.Remove(f.Name())
+}
+
+// checkReadOnly probes the data directory at startup,
+// warning that the wiki is read-only if it cannot be written
+func checkReadOnly() {
+	if *readOnlyFlag {
+		log.Printf("serving read-only")
+		return
+	}
+	readOnly.Lock()
+	defer readOnly.Unlock()
+	readOnly.checked = time.Now()
+	if err := probeWritable(); err != nil {
+		readOnly.on = true
+		log.Printf("warning: data directory is not writable, serving read-only: %v", err)
+	}
+}
+
+// isReadOnly reports whether changes cannot be made to the wiki,
+// probing the data directory again if it was read-only a while ago
+func isReadOnly() bool {
+	if *readOnlyFlag {
+		return true
+	}
+	readOnly.Lock()
+	defer readOnly.Unlock()
+	if readOnly.on && time.Since(readOnly.checked) >= readOnlyRecheck {
+		readOnly.checked = time.Now()
+		if probeWritable() == nil {
+			readOnly.on = false
+			log.Printf("data directory is writable again, accepting edits")
+		}
+	}
+	return readOnly.on
+}
+
+// requireWritable answers requests that would change the wiki with
+// 403 Forbidden and the read-only page while the wiki is read-only
+func requireWritable(fn http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if isReadOnly() {
+			renderTemplateStatus(w, r, "readonly", http.StatusForbidden, nil)
+			return
+		}
+		fn(w, r)
+	}
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// resetReadOnly forgets what the last probe of the data directory found
+func resetReadOnly() {
+	readOnly.Lock()
+	readOnly.on, readOnly.checked = false, time.Time{}
+	readOnly.Unlock()
+}
+
+func TestReadOnlyStorage(t *testing.T) {
+	h := newTestWiki(t)
+	t.Cleanup(resetReadOnly)
+	writePage(t, "Notes", "text")
+
+	// a data directory that cannot be written to, simulated by one whose
+	// probe file cannot be created as data is not a directory
+	if err := os.Rename("data", "data.rw"); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile("data", nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	checkReadOnly()
+	if !isReadOnly() {
+		t.Fatal("unwritable data directory not detected")
+	}
+	for _, r := range []*http.Request{
+		get("/edit/Notes"),
+		postForm("/save/Notes", url.Values{"body": {"changed"}}),
+		deleteForm("Notes"),
+	} {
+		w := do(h, r)
+		wantStatus(t, w, http.StatusForbidden)
+		if !strings.Contains(w.Body.String(), "read-only") {
+			t.Errorf("%s %s does not say the wiki is read-only:\n%s", r.Method, r.URL.Path, w.Body)
+		}
+	}
+
+	// storage that becomes writable again is picked up at the next recheck
+	os.Remove("data")
+	os.Rename("data.rw", "data")
+	readOnly.Lock()
+	readOnly.checked = time.Now().Add(-readOnlyRecheck)
+	readOnly.Unlock()
+	if isReadOnly() {
+		t.Fatal("still read-only after the data directory became writable")
+	}
+	wantStatus(t, do(h, postForm("/save/Notes", url.Values{"body": {"changed"}})), http.StatusFound)
+}
+
+func TestReadOnlyFlag(t *testing.T) {
+	h := newTestWiki(t, "readonly=true")
+	writePage(t, "Notes", "text")
+	wantStatus(t, do(h, get("/view/Notes")), http.StatusOK)
+	wantStatus(t, do(h, postForm("/save/Notes", url.Values{"body": {"changed"}})), http.StatusForbidden)
+	if got := readPage(t, "Notes"); got != "text" {
+		t.Errorf("body %q after saving while read-only", got)
+	}
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+)
+
+// redirectDirective matches the content of a Page that is an alias for
+// another one, written as "#REDIRECT [Target]"
+var redirectDirective = regexp.MustCompile(`^#REDIRECT\s*\[([a-zA-Z0-9]+)\]$`)
+
+// maxRedirects is the longest chain of redirect pages that is followed
+const maxRedirects = 5
+
+// redirectTarget returns the title p redirects to, or "" if p is not a redirect
+func redirectTarget(p *Page) string {
+	m := redirectDirective.FindSubmatch(bytes.TrimSpace(p.Content))
+	if m == nil {
+		return ""
+	}
+	return string(m[1])
+}
+
+// resolveRedirect follows the chain of redirects starting at p
+// and returns the title of the page it finally lands on,
+// chains that loop or run longer than maxRedirects are an error
+func resolveRedirect(p *Page) (string, error) {
+	seen := map[string]bool{p.Title: true}
+	title := redirectTarget(p)
+	for hops := 1; ; hops++ {
+		if seen[title] {
+			return "", fmt.Errorf("redirect loop at %s", title)
+		}
+		if hops > maxRedirects {
+			return "", fmt.Errorf("more than %d redirects starting from %s", maxRedirects, p.Title)
+		}
+		seen[title] = true
+		next, err := loadPage(title)
+		if err != nil {
+			// the target does not exist yet, landing there offers to create it
+			return title, nil
+		}
+		target := redirectTarget(next)
+		if target == "" {
+			return title, nil
+		}
+		title = target
+	}
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestRedirectPages(t *testing.T) {
+	h := newTestWiki(t)
+	writePage(t, "Target", "the real page")
+	writePage(t, "Alias", "#REDIRECT [Target]")
+	writePage(t, "Older", "#REDIRECT [Alias]")
+
+	for _, title := range []string{"Alias", "Older"} {
+		w := do(h, get("/view/"+title))
+		wantStatus(t, w, http.StatusFound)
+		if loc, want := w.Header().Get("Location"), "/view/Target?from="+title; loc != want {
+			t.Errorf("%s redirects to %q, want %q", title, loc, want)
+		}
+	}
+
+	for _, path := range []string{"/view/Alias?redirect=no", "/view/Alias?source=1"} {
+		wantStatus(t, do(h, get(path)), http.StatusOK)
+	}
+	w := do(h, get("/edit/Alias"))
+	wantStatus(t, w, http.StatusOK)
+	if !strings.Contains(w.Body.String(), "#REDIRECT [Target]") {
+		t.Errorf("edit form does not show the redirect's source:\n%s", w.Body)
+	}
+}
+
+func TestRedirectLoopGuard(t *testing.T) {
+	h := newTestWiki(t)
+	writePage(t, "Ping", "#REDIRECT [Pong]")
+	writePage(t, "Pong", "#REDIRECT [Ping]")
+	for i := 0; i <= maxRedirects; i++ {
+		writePage(t, fmt.Sprintf("Hop%d", i), fmt.Sprintf("#REDIRECT [Hop%d]", i+1))
+	}
+	writePage(t, fmt.Sprintf("Hop%d", maxRedirects+1), "the end")
+
+	for title, warning := range map[string]string{"Ping": "redirect loop at Ping", "Hop0": "more than 5 redirects starting from Hop0"} {
+		w := do(h, get("/view/"+title))
+		wantStatus(t, w, http.StatusOK)
+		if !strings.Contains(w.Body.String(), warning) {
+			t.Errorf("%s does not warn %q:\n%s", title, warning, w.Body)
+		}
+	}
+	w := do(h, get("/view/Hop1"))
+	wantStatus(t, w, http.StatusFound)
+	if loc, want := w.Header().Get("Location"), fmt.Sprintf("/view/Hop%d?from=Hop1", maxRedirects+1); loc != want {
+		t.Errorf("Hop1 redirects to %q, want %q", loc, want)
+	}
+}
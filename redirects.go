@@ -0,0 +1,151 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+)
+
+// redirectsFile maps old paths to new ones, answered with 301 Moved Permanently
+// it holds one "old new" pair of paths per line, "#" starting a comment, e.g.
+//
+//	/view/OldName /view/NewName
+//
+// and is reloaded on SIGHUP or a POST to /admin/redirects
+var redirectsFile = flag.String("redirects-file", "", "file of \"old-path new-path\" lines that are redirected with 301 Moved Permanently (empty disables)")
+
+// redirects is the live path redirect map, replaced as a whole on reload
+var redirects = struct {
+	sync.RWMutex
+	paths map[string]string
+}{paths: map[string]string{}}
+
+// loadRedirects parses filename into a map from old path to final new path,
+// chains of redirects being collapsed so each needs a single hop
+// a file containing a redirect loop is rejected
+func loadRedirects(filename string) (map[string]string, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	paths := map[string]string{}
+	s := bufio.NewScanner(f)
+	for n := 1; s.Scan(); n++ {
+		line := s.Text()
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) != 2 || !strings.HasPrefix(fields[0], "/") {
+			return nil, fmt.Errorf("%s:%d: want an old path and a new url", filename, n)
+		}
+		paths[fields[0]] = fields[1]
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+
+	final := make(map[string]string, len(paths))
+	for old := range paths {
+		seen := map[string]bool{old: true}
+		to := paths[old]
+		for next, ok := paths[to]; ok; next, ok = paths[to] {
+			if seen[to] {
+				return nil, fmt.Errorf("%s: redirect loop through %s", filename, old)
+			}
+			seen[to] = true
+			to = next
+		}
+		final[old] = to
+	}
+	return final, nil
+}
+
+// reloadRedirects replaces the live redirect map with -redirects-file,
+// keeping the old map if the file cannot be loaded
+func reloadRedirects() (int, error) {
+	paths, err := loadRedirects(*redirectsFile)
+	if err != nil {
+		return 0, err
+	}
+	redirects.Lock()
+	redirects.paths = paths
+	redirects.Unlock()
+	return len(paths), nil
+}
+
+// runRedirectReloader reloads the redirect map on every SIGHUP until ctx is done
+func runRedirectReloader(ctx context.Context) {
+	if *redirectsFile == "" {
+		return
+	}
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+			if n, err := reloadRedirects(); err != nil {
+				log.Printf("redirects: %v", err)
+			} else {
+				log.Printf("reloaded %d redirects", n)
+			}
+		}
+	}
+}
+
+// redirectPaths answers requests for the old paths in the redirect map
+// with a 301 to their new location, keeping any query string
+func redirectPaths(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		redirects.RLock()
+		to, ok := redirects.paths[r.URL.Path]
+		redirects.RUnlock()
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if r.URL.RawQuery != "" && !strings.Contains(to, "?") {
+			to += "?" + r.URL.RawQuery
+		}
+		http.Redirect(w, r, to, http.StatusMovedPermanently)
+	})
+}
+
+// redirectsHandler reloads -redirects-file on POST and reports
+// the number of redirects in effect as JSON
+func redirectsHandler(w http.ResponseWriter, r *http.Request) {
+	if *redirectsFile == "" {
+		errorHandler(w, r, http.StatusNotFound, "no redirects file is configured")
+		return
+	}
+	if r.Method == http.MethodPost {
+		if _, err := reloadRedirects(); err != nil {
+			errorHandler(w, r, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+	redirects.RLock()
+	n := len(redirects.paths)
+	redirects.RUnlock()
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(struct {
+		Redirects int `json:"redirects"`
+	}{n})
+}
@@ -0,0 +1,715 @@
+package main
+
+import (
+	"flag"
+	"html"
+	"html/template"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// wikiLink matches internal links of the form [PageName], [PageName#Section]
+// and [#Section], the last one pointing at a section of the current page
+var wikiLink = regexp.MustCompile(`\[([a-zA-Z0-9]*)(?:#([^\[\]]+))?\]`)
+
+// heading is a single section heading found in a Page body,
+// ID is the anchor used both by the table of contents and by section links
+type heading struct {
+	Level int
+	Text  string
+	ID    string
+}
+
+// headingID slugifies heading text into an anchor id, lower-casing letters
+// and digits and collapsing everything else into single dashes
+func headingID(text string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(text) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if dash && b.Len() > 0 {
+				b.WriteByte('-')
+			}
+			b.WriteRune(r)
+			dash = false
+			continue
+		}
+		dash = true
+	}
+	if b.Len() == 0 {
+		return "section"
+	}
+	return b.String()
+}
+
+// noHeadingLinks turns off the "#" link to each heading's section
+var noHeadingLinks = flag.Bool("no-heading-links", false, `do not add a "#" link to its section to each heading`)
+
+// headingLink renders the "#" link shown next to the heading with anchor id,
+// which headings.js copies the address of when clicked
+func headingLink(id string) string {
+	if *noHeadingLinks {
+		return ""
+	}
+	return ` <a class="heading-link" href="#` + id + `" aria-label="Link to this section">#</a>`
+}
+
+// parseHeading reports whether line is a heading ('# Text' through '###### Text')
+// and if so returns its level and text
+func parseHeading(line string) (int, string, bool) {
+	level := 0
+	for level < len(line) && line[level] == '#' {
+		level++
+	}
+	if level == 0 || level > 6 || level >= len(line) || line[level] != ' ' {
+		return 0, "", false
+	}
+	text := strings.TrimSpace(line[level:])
+	if text == "" {
+		return 0, "", false
+	}
+	return level, text, true
+}
+
+// headingIDs hands out unique anchor ids for the headings of a single Page,
+// suffixing repeated slugs with -1, -2 and so on
+type headingIDs map[string]int
+
+// next returns the unique anchor id for the given heading text
+func (ids headingIDs) next(text string) string {
+	id := headingID(text)
+	n, seen := ids[id]
+	ids[id] = n + 1
+	if seen {
+		id += "-" + strconv.Itoa(n)
+	}
+	return id
+}
+
+// pageHeadings lists all headings in body in order, with the same ids
+// that renderBody assigns to them
+func pageHeadings(body []byte) []heading {
+	var hs []heading
+	ids := headingIDs{}
+	for _, line := range proseLines(body) {
+		if level, text, ok := parseHeading(line); ok {
+			hs = append(hs, heading{Level: level, Text: text, ID: ids.next(text)})
+		}
+	}
+	return hs
+}
+
+// bodyLines splits a Page body into lines, ignoring carriage returns
+func bodyLines(body []byte) []string {
+	s := strings.ReplaceAll(string(body), "\r\n", "\n")
+	return strings.Split(s, "\n")
+}
+
+// isFence reports whether line opens or closes a fenced block of code,
+// a line starting with ``` or ~~~ which may name the block's language
+func isFence(line string) bool {
+	trimmed := strings.TrimSpace(line)
+	return strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~")
+}
+
+// proseLines is bodyLines with the fenced blocks of code left out,
+// the lines whose links and urls are not rendered as such
+func proseLines(body []byte) []string {
+	var lines []string
+	inFence := false
+	for _, line := range bodyLines(body) {
+		if isFence(line) {
+			inFence = !inFence
+			continue
+		}
+		if !inFence {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+// codeBlock renders the lines of a fenced block of code
+func codeBlock(lines []string) string {
+	return "<pre><code>" + template.HTMLEscapeString(strings.Join(lines, "\n")) + "</code></pre>\n"
+}
+
+// footnote markup: [^label] references and "[^label]: text" definitions
+var (
+	footnoteRef = regexp.MustCompile(`\[\^([^\[\]\s]+)\]`)
+	footnoteDef = regexp.MustCompile(`^\[\^([^\[\]\s]+)\]:\s*(.*)$`)
+)
+
+// renderer holds the state of rendering a single Page body into HTML
+// heading and footnote ids are namespaced by prefix, so that several bodies
+// rendered into the same HTML document do not collide
+// stack holds the titles of the pages being rendered, outermost first,
+// guarding against pages that include themselves
+type renderer struct {
+	out      strings.Builder
+	ids      headingIDs
+	prefix   string
+	notes    map[string]string // footnote label -> text
+	order    []string          // footnote labels in order of first reference
+	refs     map[string]int    // footnote label -> references rendered so far
+	stack    []string
+	includes int
+	tasks    int               // task list items rendered so far
+	book     map[string]string // page title -> id prefix of the pages of a book
+	shift    int               // levels headings are moved down by
+}
+
+// newRenderer returns a renderer for the page title whose ids start with prefix
+func newRenderer(title, prefix string) *renderer {
+	return &renderer{ids: headingIDs{}, prefix: prefix, notes: map[string]string{}, refs: map[string]int{}, stack: []string{title}}
+}
+
+// includeDirective matches a line transcluding another page, or one section of it:
+// {{include:PageName}} or {{include:PageName#Section}}
+var includeDirective = regexp.MustCompile(`^\{\{include:([a-zA-Z0-9]+)(?:#([^{}]+))?\}\}$`)
+
+// maxIncludeDepth bounds how deeply included pages may include further pages
+const maxIncludeDepth = 5
+
+// include renders the page title, or just its section, in place
+// a nested renderer gets its own namespace for heading and footnote ids,
+// missing pages and sections and include cycles render a placeholder
+func (rd *renderer) include(title, section string) {
+	placeholder := func(msg string) {
+		rd.out.WriteString(`<p class="include-error">` + template.HTMLEscapeString(msg) + "</p>\n")
+	}
+	for _, t := range rd.stack {
+		if t == title {
+			placeholder("cannot include " + title + ": it is already being included")
+			return
+		}
+	}
+	if len(rd.stack) > maxIncludeDepth {
+		placeholder("cannot include " + title + ": includes are nested too deeply")
+		return
+	}
+	p, err := loadPage(title)
+	if err != nil {
+		placeholder("cannot include " + title + ": page does not exist")
+		return
+	}
+	var outer pageMeta
+	if root, err := loadPage(rd.stack[0]); err == nil {
+		outer = root.Meta
+	}
+	if !mayInclude(&outer, &p.Meta) {
+		placeholder("cannot include " + title + ": it is more restricted than this page")
+		return
+	}
+	body := p.Content
+	if section != "" {
+		var ok bool
+		if body, ok = sectionBody(p.Content, section); !ok {
+			placeholder("cannot include " + title + "#" + section + ": section does not exist")
+			return
+		}
+	}
+
+	rd.includes++
+	nested := newRenderer(title, rd.prefix+"inc"+strconv.Itoa(rd.includes)+"-")
+	nested.stack = append(append([]string(nil), rd.stack...), title)
+	nested.book, nested.shift = rd.book, rd.shift
+	rd.out.WriteString(`<div class="include" data-page="` + title + `">` + "\n")
+	rd.out.WriteString(string(nested.render(body)))
+	rd.out.WriteString("</div>\n")
+}
+
+// sectionBody extracts the section of body whose heading id matches section,
+// from its heading up to the next heading of the same or a higher level
+// footnote definitions anywhere in body are kept so references still resolve
+func sectionBody(body []byte, section string) ([]byte, bool) {
+	want := headingID(section)
+	ids := headingIDs{}
+	var out, notes []string
+	level := 0
+	for _, line := range bodyLines(body) {
+		if footnoteDef.MatchString(line) {
+			notes = append(notes, line)
+			continue
+		}
+		if l, text, ok := parseHeading(line); ok {
+			id := ids.next(text)
+			if level > 0 && l <= level {
+				level = -1
+			}
+			if level == 0 && id == want {
+				level = l
+			}
+		}
+		if level > 0 {
+			out = append(out, line)
+		}
+	}
+	if len(out) == 0 {
+		return nil, false
+	}
+	return []byte(strings.Join(append(out, notes...), "\n")), true
+}
+
+// renderBody converts a Page body into HTML
+// headings become anchored <h1>-<h6> elements, blank lines separate paragraphs,
+// "Term" lines followed by ": definition" lines form definition lists,
+// "- [ ] item" and "- [x] item" lines form task lists of checkboxes,
+// lines between ``` or ~~~ fences form a block of code rendered as written,
+// [import:URL] lines are replaced by the remote content,
+// {{include:Page#Section}} lines by the rendered page or section, inline markup
+// is rendered by renderer.inline and footnotes are collected at the end
+// raw HTML is escaped or sanitized according to -html-policy
+func renderBody(title string, body []byte) template.HTML {
+	return newRenderer(title, "").render(body)
+}
+
+// render converts body into HTML, see renderBody
+func (rd *renderer) render(body []byte) template.HTML {
+	var lines []string
+	fenced := false
+	for _, line := range bodyLines(body) {
+		if isFence(line) {
+			fenced = !fenced
+		}
+		if m := footnoteDef.FindStringSubmatch(line); m != nil && !fenced {
+			rd.notes[m[1]] = m[2]
+			continue
+		}
+		lines = append(lines, line)
+	}
+
+	var para []string
+	list := "" // the element of the list being rendered, dl or ul
+	closeList := func() {
+		if list != "" {
+			rd.out.WriteString("</" + list + ">\n")
+			list = ""
+		}
+	}
+	openList := func(tag, class string) {
+		if list != tag {
+			closeList()
+			rd.out.WriteString("<" + tag + class + ">\n")
+			list = tag
+		}
+	}
+	flush := func() {
+		if len(para) == 0 {
+			return
+		}
+		closeList()
+		if pol := activePolicy(); pol != nil && strings.HasPrefix(strings.TrimSpace(para[0]), "<") {
+			// a paragraph starting with a tag is a block of raw HTML
+			rd.out.WriteString(sanitizeHTML(strings.Join(para, "\n"), pol) + "\n")
+		} else {
+			rd.out.WriteString("<p>" + rd.inline(strings.Join(para, "\n")) + "</p>\n")
+		}
+		para = nil
+	}
+
+	var quote []string
+	flushQuote := func() {
+		if len(quote) > 0 {
+			rd.quote(quote)
+			quote = nil
+		}
+	}
+
+	var math, code []string
+	inMath, inFence := false, false
+	for _, line := range lines {
+		if !inMath && (inFence || isFence(line)) {
+			switch {
+			case !isFence(line):
+				code = append(code, line)
+			case inFence:
+				rd.out.WriteString(codeBlock(code))
+				code, inFence = nil, false
+			default:
+				flushQuote()
+				flush()
+				closeList()
+				inFence = true
+			}
+			continue
+		}
+		if !inMath {
+			if text, ok := quoteLine(line); ok {
+				flush()
+				closeList()
+				quote = append(quote, text)
+				continue
+			}
+			flushQuote()
+		}
+		if *enableMath && strings.TrimSpace(line) == mathDelim {
+			if inMath {
+				rd.out.WriteString(mathBlock(math))
+				math = nil
+			} else {
+				flush()
+				closeList()
+			}
+			inMath = !inMath
+			continue
+		}
+		if inMath {
+			math = append(math, line)
+			continue
+		}
+		if strings.HasPrefix(line, ": ") && (len(para) > 0 || list == "dl") {
+			if len(para) > 0 {
+				term := para[len(para)-1]
+				para = para[:len(para)-1]
+				flush()
+				openList("dl", "")
+				rd.out.WriteString("<dt>" + rd.inline(term) + "</dt>\n")
+			}
+			rd.out.WriteString("<dd>" + rd.inline(strings.TrimSpace(line[2:])) + "</dd>\n")
+			continue
+		}
+		if m := taskItem.FindStringSubmatch(line); m != nil {
+			flush()
+			openList("ul", ` class="tasks"`)
+			rd.out.WriteString("<li>" + rd.task(m[1] != " ", m[2]) + " " + rd.inline(m[2]) + "</li>\n")
+			continue
+		}
+		if m := importDirective.FindStringSubmatch(strings.TrimSpace(line)); m != nil && *importHosts != "" {
+			flush()
+			closeList()
+			rd.out.WriteString(renderImport(m[1]))
+			continue
+		}
+		if m := includeDirective.FindStringSubmatch(strings.TrimSpace(line)); m != nil {
+			flush()
+			closeList()
+			rd.include(m[1], m[2])
+			continue
+		}
+		if isToday(line) {
+			flush()
+			closeList()
+			rd.today()
+			continue
+		}
+		if level, text, ok := parseHeading(line); ok {
+			flush()
+			closeList()
+			if level += rd.shift; level > 6 {
+				level = 6
+			}
+			tag := "h" + strconv.Itoa(level)
+			id := rd.prefix + rd.ids.next(text)
+			rd.out.WriteString("<" + tag + ` id="` + id + `">` + rd.inline(text) + headingLink(id) + "</" + tag + ">\n")
+			continue
+		}
+		if strings.TrimSpace(line) == "" {
+			flush()
+			closeList()
+			continue
+		}
+		para = append(para, line)
+	}
+	if inMath {
+		rd.out.WriteString(mathBlock(math))
+	}
+	if inFence {
+		rd.out.WriteString(codeBlock(code))
+	}
+	flushQuote()
+	flush()
+	closeList()
+	rd.footnotes()
+	return template.HTML(rd.out.String())
+}
+
+// footnoteID is the anchor of the footnote labelled label
+func (rd *renderer) footnoteID(label string) string {
+	return "fn-" + rd.prefix + headingID(label)
+}
+
+// footnoteRef renders a reference to the footnote labelled label,
+// numbering footnotes in the order they are first referenced
+// references to undefined footnotes are left as text
+func (rd *renderer) footnoteRef(label string) (string, bool) {
+	if _, ok := rd.notes[label]; !ok {
+		return "", false
+	}
+	n := rd.refs[label]
+	if n == 0 {
+		rd.order = append(rd.order, label)
+	}
+	rd.refs[label] = n + 1
+	num := 0
+	for i, l := range rd.order {
+		if l == label {
+			num = i + 1
+		}
+	}
+	id := "fnref-" + rd.prefix + headingID(label)
+	if n > 0 {
+		id += "-" + strconv.Itoa(n)
+	}
+	return `<sup class="footnote-ref" id="` + id + `"><a href="#` + rd.footnoteID(label) + `">` + strconv.Itoa(num) + "</a></sup>", true
+}
+
+// footnotes writes the list of referenced footnotes, each linking back to
+// its first reference, followed by any footnotes that were never referenced
+func (rd *renderer) footnotes() {
+	if len(rd.notes) == 0 {
+		return
+	}
+	var unreferenced []string
+	for label := range rd.notes {
+		if rd.refs[label] == 0 {
+			unreferenced = append(unreferenced, label)
+		}
+	}
+	sort.Strings(unreferenced)
+
+	rd.out.WriteString("<section class=\"footnotes\">\n<ol>\n")
+	for i := 0; i < len(rd.order); i++ {
+		label := rd.order[i]
+		text := rd.inline(rd.notes[label])
+		backref := "#fnref-" + rd.prefix + headingID(label)
+		rd.out.WriteString(`<li id="` + rd.footnoteID(label) + `">` + text + ` <a href="` + backref + `" class="footnote-backref">&#8617;</a></li>` + "\n")
+	}
+	for _, label := range unreferenced {
+		rd.out.WriteString(`<li id="` + rd.footnoteID(label) + `">` + rd.inline(rd.notes[label]) + "</li>\n")
+	}
+	rd.out.WriteString("</ol>\n</section>\n")
+}
+
+// inline markup recognised within a line of text, matched against
+// already html-escaped text
+var (
+	codeSpan     = regexp.MustCompile("`([^`]+)`")
+	markdownLink = regexp.MustCompile(`\[([^\[\]]+)\]\(([^()\s]+)\)`)
+	boldText     = regexp.MustCompile(`\*\*([^*]+)\*\*`)
+	italicText   = regexp.MustCompile(`\*([^*\s][^*]*)\*`)
+	bareURL      = regexp.MustCompile(`https?://(?:[^\s\x00&]|&amp;)+`)
+)
+
+// noAutolink turns off linking of bare http and https urls in page text
+var noAutolink = flag.Bool("no-autolink", false, "do not turn bare http:// and https:// URLs in pages into links")
+
+// wikiWords turns on linking CamelCase words without brackets
+var wikiWords = flag.Bool("wiki-words", false, "turn CamelCase words such as FrontPage into links to the page of that name")
+
+// wikiWord matches a CamelCase word, two or more capitalized parts run together,
+// a leading ! keeps the word from being linked, as does putting it in `code`
+// or a fenced block of code
+var wikiWord = regexp.MustCompile(`!?\b[A-Z][a-z0-9]+(?:[A-Z][a-z0-9]+)+\b`)
+
+// autolink links a bare url found in escaped text, leaving trailing
+// punctuation that most likely ends the sentence rather than the url outside
+func autolink(m string, in *inlineHTML) string {
+	url := strings.TrimRight(m, ".,;:!?)")
+	return in.hold(`<a href="`+url+`" rel="noopener noreferrer">`+url+"</a>") + m[len(url):]
+}
+
+// inlineHTML collects markup generated while rendering a run of text,
+// generated markup is held behind placeholders so that later passes
+// cannot rewrite the inside of a code span or an href
+type inlineHTML []string
+
+// hold stores html and returns the placeholder standing in for it
+func (in *inlineHTML) hold(html string) string {
+	*in = append(*in, html)
+	return "\x00" + strconv.Itoa(len(*in)-1) + "\x00"
+}
+
+// placeholder matches the markers handed out by inlineHTML.hold
+var placeholder = regexp.MustCompile("\x00([0-9]+)\x00")
+
+// restore swaps every placeholder in s back to the markup it stands for
+func (in inlineHTML) restore(s string) string {
+	return placeholder.ReplaceAllStringFunc(s, func(m string) string {
+		i, _ := strconv.Atoi(m[1 : len(m)-1])
+		return in[i]
+	})
+}
+
+// inline escapes a run of text and renders the inline markup in it:
+// `code`, [^footnote] references, [text](url) links, wikilinks,
+// CamelCase words under -wiki-words, **bold** and *italic*
+func (rd *renderer) inline(text string) string {
+	var in inlineHTML
+	s := escapeInline(text, &in)
+	s = codeSpan.ReplaceAllStringFunc(s, func(m string) string {
+		return in.hold("<code>" + m[1:len(m)-1] + "</code>")
+	})
+	if *enableMath {
+		s = holdMath(s, &in)
+	}
+	s = footnoteRef.ReplaceAllStringFunc(s, func(m string) string {
+		ref, ok := rd.footnoteRef(html.UnescapeString(m[2 : len(m)-1]))
+		if !ok {
+			return m
+		}
+		return in.hold(ref)
+	})
+	s = markdownLink.ReplaceAllStringFunc(s, func(m string) string {
+		sm := markdownLink.FindStringSubmatch(m)
+		if !safeURL(html.UnescapeString(sm[2])) {
+			return m
+		}
+		return in.hold(`<a href="` + sm[2] + `">` + sm[1] + "</a>")
+	})
+	if !*noAutolink {
+		s = bareURL.ReplaceAllStringFunc(s, func(m string) string { return autolink(m, &in) })
+	}
+	s = wikiLink.ReplaceAllStringFunc(s, func(m string) string {
+		sm := wikiLink.FindStringSubmatch(m)
+		if sm[1] == "" && sm[2] == "" {
+			return m
+		}
+		return in.hold(rd.wikiAnchor(sm[1], html.UnescapeString(sm[2]), html.UnescapeString(m[1:len(m)-1])))
+	})
+	if *wikiWords {
+		s = wikiWord.ReplaceAllStringFunc(s, func(m string) string {
+			if m[0] == '!' || len(m) > *maxTitleLen {
+				return strings.TrimPrefix(m, "!")
+			}
+			return in.hold(rd.wikiAnchor(m, "", m))
+		})
+	}
+	s = boldText.ReplaceAllString(s, "<strong>$1</strong>")
+	s = italicText.ReplaceAllString(s, "<em>$1</em>")
+	return in.restore(s)
+}
+
+// rawTag matches a single raw HTML tag within a line of text
+var rawTag = regexp.MustCompile(`</?[a-zA-Z][^<>]*>`)
+
+// escapeInline html-escapes text, except that under a relaxed -html-policy
+// raw tags allowed by the policy are sanitized and held in in instead
+// tags that are not allowed are dropped
+func escapeInline(text string, in *inlineHTML) string {
+	pol := activePolicy()
+	if pol == nil {
+		return template.HTMLEscapeString(text)
+	}
+	var out strings.Builder
+	last := 0
+	for _, m := range rawTag.FindAllStringIndex(text, -1) {
+		out.WriteString(escapeText(text[last:m[0]]))
+		if tag, ok := sanitizeTag(text[m[0]:m[1]], pol); ok {
+			out.WriteString(in.hold(tag))
+		}
+		last = m[1]
+	}
+	out.WriteString(escapeText(text[last:]))
+	return out.String()
+}
+
+// safeURL reports whether u may be used as a link target,
+// only http(s), mailto and relative urls are allowed
+func safeURL(u string) bool {
+	lower := strings.ToLower(u)
+	for _, prefix := range []string{"http://", "https://", "mailto:", "/", "#"} {
+		if strings.HasPrefix(lower, prefix) {
+			return true
+		}
+	}
+	return !strings.Contains(lower, ":")
+}
+
+// wikiAnchor builds the <a> element for a wikilink on the page from to section
+// of page title, an empty title links within the current page and an empty
+// section links to the top of the target page, links to pages that do not
+// exist yet get the "new-page" class and the url given by missingHref
+func wikiAnchor(from, title, section, label string) string {
+	href := ""
+	class := ""
+	if title != "" {
+		href = "/view/" + title
+		if !pageExists(title) {
+			href = missingHref(title, from)
+			class = ` class="new-page"`
+		}
+	}
+	if section != "" && !strings.HasPrefix(href, "/edit/") {
+		href += "#" + headingID(section)
+	}
+	return `<a href="` + template.HTMLEscapeString(href) + `"` + class + ">" + template.HTMLEscapeString(label) + "</a>"
+}
+
+// wikiAnchor is wikiAnchor for links rendered by rd, which when rendering
+// a book point within the book if it includes the target page
+func (rd *renderer) wikiAnchor(title, section, label string) string {
+	from := rd.stack[len(rd.stack)-1]
+	if rd.book == nil {
+		return wikiAnchor(from, title, section, label)
+	}
+	href := ""
+	if title == "" {
+		href = "#" + rd.prefix + headingID(section)
+	} else if prefix, ok := rd.book[title]; !ok {
+		return wikiAnchor(from, title, section, label)
+	} else if section != "" {
+		href = "#" + prefix + headingID(section)
+	} else {
+		href = "#" + bookChapterID(title)
+	}
+	return `<a href="` + template.HTMLEscapeString(href) + `">` + template.HTMLEscapeString(label) + "</a>"
+}
+
+// pageLink is the target of a wikilink, Exists is false for
+// links to pages that have not been created yet
+type pageLink struct {
+	Title  string
+	Exists bool
+}
+
+// Href is the url the link points to on the page from, the page's view
+// if it exists and the url given by missingHref if not
+func (l pageLink) Href(from string) string {
+	if l.Exists {
+		return "/view/" + l.Title
+	}
+	return missingHref(l.Title, from)
+}
+
+// pageLinks lists the distinct pages that body links to, sorted by title
+// links inside code spans and fenced blocks, Markdown links and links to
+// sections of the same page are ignored, CamelCase words count as links
+// under -wiki-words
+func pageLinks(body []byte) []pageLink {
+	seen := map[string]bool{}
+	for _, line := range proseLines(body) {
+		line = markdownLink.ReplaceAllString(codeSpan.ReplaceAllString(line, ""), "")
+		for _, m := range wikiLink.FindAllStringSubmatch(line, -1) {
+			if m[1] != "" {
+				seen[m[1]] = true
+			}
+		}
+		if *wikiWords {
+			line = bareURL.ReplaceAllString(wikiLink.ReplaceAllString(line, ""), "")
+			for _, w := range wikiWord.FindAllString(line, -1) {
+				if w[0] != '!' && len(w) <= *maxTitleLen {
+					seen[w] = true
+				}
+			}
+		}
+	}
+	links := make([]pageLink, 0, len(seen))
+	for title := range seen {
+		links = append(links, pageLink{Title: title, Exists: pageExists(title)})
+	}
+	sort.Slice(links, func(i, j int) bool { return links[i].Title < links[j].Title })
+	return links
+}
+
+// submatch returns the i'th submatch of a FindSubmatchIndex result,
+// or an empty string if that group did not participate in the match
+func submatch(s string, m []int, i int) string {
+	if m[2*i] < 0 {
+		return ""
+	}
+	return s[m[2*i]:m[2*i+1]]
+}
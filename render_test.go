@@ -0,0 +1,249 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// renderString renders body as the page Test
+func renderString(body string) string {
+	return string(renderBody("Test", []byte(body)))
+}
+
+func TestAutolink(t *testing.T) {
+	newTestWiki(t)
+	for _, tc := range []struct {
+		name, body, want string
+	}{
+		{"bare url", "see https://example.com/a?b=1&c=2.", `see <a href="https://example.com/a?b=1&amp;c=2" rel="noopener noreferrer">https://example.com/a?b=1&amp;c=2</a>.`},
+		{"code span", "run `curl https://example.com`", "run <code>curl https://example.com</code>"},
+		{"markdown link", "[docs](https://example.com/docs)", `<a href="https://example.com/docs">docs</a>`},
+		{"fenced block", "```\ncurl https://example.com\n```", "<pre><code>curl https://example.com</code></pre>"},
+	} {
+		if got := renderString(tc.body); !strings.Contains(got, tc.want) {
+			t.Errorf("%s: rendered\n%s\nwant it to contain\n%s", tc.name, got, tc.want)
+		}
+	}
+	setFlag(t, "no-autolink", "true")
+	if got := renderString("see https://example.com"); strings.Contains(got, "<a") {
+		t.Errorf("-no-autolink linked a bare url: %s", got)
+	}
+}
+
+func TestFencedBlock(t *testing.T) {
+	newTestWiki(t)
+	got := renderString("before\n```go\n# not a heading\n[^1]: not a footnote\n<b>x</b> [Page]\n```\nafter")
+	want := "<p>before</p>\n<pre><code># not a heading\n[^1]: not a footnote\n&lt;b&gt;x&lt;/b&gt; [Page]</code></pre>\n<p>after</p>\n"
+	if got != want {
+		t.Errorf("rendered\n%q\nwant\n%q", got, want)
+	}
+	if got := renderString("```\nopen https://example.com"); !strings.Contains(got, "<pre><code>open https://example.com</code></pre>") {
+		t.Errorf("unclosed fence rendered %s", got)
+	}
+	if links := externalLinks([]byte("```\nhttps://example.com/code\n```\nhttps://example.com/prose")); len(links) != 1 || links[0] != "https://example.com/prose" {
+		t.Errorf("external links %v, want only the one outside the fence", links)
+	}
+}
+
+func TestWikiWords(t *testing.T) {
+	newTestWiki(t, "wiki-words=true")
+	writePage(t, "FrontPage", "home")
+	for _, tc := range []struct {
+		name, body, want string
+	}{
+		{"linked", "see FrontPage", `see <a href="/view/FrontPage">FrontPage</a>`},
+		{"missing page", "see MyNotes", `see <a href="/view/MyNotes" class="new-page">MyNotes</a>`},
+		{"suppressed", "see !FrontPage", "<p>see FrontPage</p>"},
+		{"code span", "see `FrontPage`", "<p>see <code>FrontPage</code></p>"},
+		{"fenced block", "```\nFrontPage\n```", "<pre><code>FrontPage</code></pre>"},
+		{"url", "https://example.com/FrontPage", `<a href="https://example.com/FrontPage" rel="noopener noreferrer">https://example.com/FrontPage</a>`},
+		{"wikilink", "[FrontPage]", `<p><a href="/view/FrontPage">FrontPage</a></p>`},
+	} {
+		if got := renderString(tc.body); !strings.Contains(got, tc.want) {
+			t.Errorf("%s: rendered\n%s\nwant it to contain\n%s", tc.name, got, tc.want)
+		}
+	}
+
+	links := pageLinks([]byte("MyNotes and !OtherPage `CodeWord`\n```\nFencedWord\n```"))
+	if len(links) != 1 || links[0].Title != "MyNotes" {
+		t.Errorf("page links %v, want only MyNotes", links)
+	}
+	setFlag(t, "wiki-words", "false")
+	if got := renderString("see FrontPage"); strings.Contains(got, "<a") {
+		t.Errorf("CamelCase linked without -wiki-words: %s", got)
+	}
+}
+
+func TestFencedBlockHeadingsAndTasks(t *testing.T) {
+	newTestWiki(t)
+	body := "# Real\n~~~sh\n# comment\n- [ ] not a task\n~~~\n- [ ] task\n"
+	if hs := pageHeadings([]byte(body)); len(hs) != 1 || hs[0].ID != "real" {
+		t.Errorf("headings %v, want only Real", hs)
+	}
+	if got := renderString(body); !strings.Contains(got, `data-task="0" data-text="task"`) || strings.Count(got, "data-task") != 1 {
+		t.Errorf("rendered tasks\n%s", got)
+	}
+	if tasks := taskLines([]byte(body)); len(tasks) != 1 || tasks[0] != 5 {
+		t.Errorf("task lines %v, want [5]", tasks)
+	}
+}
+
+func TestSectionLinks(t *testing.T) {
+	newTestWiki(t)
+	writePage(t, "Other", "# My Section\ntext")
+	for _, tc := range []struct {
+		name, body, want string
+	}{
+		{"same page", "# My Section\n[#My Section]", `<a href="#my-section">#My Section</a>`},
+		{"cross page", "[Other#My Section]", `<a href="/view/Other#my-section">Other#My Section</a>`},
+		{"missing section", "[Other#Nowhere]", `<a href="/view/Other#nowhere">Other#Nowhere</a>`},
+		{"missing page", "[Gone#Part]", `<a href="/view/Gone#part" class="new-page">Gone#Part</a>`},
+	} {
+		if got := renderString(tc.body); !strings.Contains(got, tc.want) {
+			t.Errorf("%s: rendered\n%s\nwant it to contain\n%s", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestHeadingIDsMatchTOC(t *testing.T) {
+	newTestWiki(t)
+	body := "# Intro\n## Setup & Use\n# Intro\n"
+	got := renderString(body)
+	for _, h := range pageHeadings([]byte(body)) {
+		if !strings.Contains(got, `id="`+h.ID+`"`) {
+			t.Errorf("TOC entry %q has no heading with its id in\n%s", h.ID, got)
+		}
+	}
+	if hs := pageHeadings([]byte(body)); len(hs) != 3 || hs[1].ID != "setup-use" || hs[2].ID != "intro-1" {
+		t.Errorf("headings %v", hs)
+	}
+}
+
+func TestFootnotes(t *testing.T) {
+	newTestWiki(t)
+	for _, tc := range []struct {
+		name, body, want string
+	}{
+		{"numbered by first reference",
+			"One[^b] and two[^a] and one again[^b].\n\n[^a]: Second note\n[^b]: First note",
+			"<p>One<sup class=\"footnote-ref\" id=\"fnref-b\"><a href=\"#fn-b\">1</a></sup> and two<sup class=\"footnote-ref\" id=\"fnref-a\"><a href=\"#fn-a\">2</a></sup> and one again<sup class=\"footnote-ref\" id=\"fnref-b-1\"><a href=\"#fn-b\">1</a></sup>.</p>\n" +
+				"<section class=\"footnotes\">\n<ol>\n" +
+				"<li id=\"fn-b\">First note <a href=\"#fnref-b\" class=\"footnote-backref\">&#8617;</a></li>\n" +
+				"<li id=\"fn-a\">Second note <a href=\"#fnref-a\" class=\"footnote-backref\">&#8617;</a></li>\n" +
+				"</ol>\n</section>\n"},
+		{"undefined reference", "Missing[^x] note.", "<p>Missing[^x] note.</p>\n"},
+	} {
+		if got := renderString(tc.body); got != tc.want {
+			t.Errorf("%s: rendered\n%q\nwant\n%q", tc.name, got, tc.want)
+		}
+	}
+	got := renderString("[^early]: Defined first\n\nText[^early].\n\n[^spare]: Never used")
+	if !strings.Contains(got, `<a href="#fn-early">1</a>`) || !strings.Contains(got, "Never used") || strings.Contains(got, "<p>[^early]") {
+		t.Errorf("footnotes defined before use or never used rendered as\n%s", got)
+	}
+}
+
+func TestDefinitionLists(t *testing.T) {
+	newTestWiki(t)
+	for _, tc := range []struct {
+		name, body, want string
+	}{
+		{"terms", "Term\n: Definition one\n: Definition two\nOther\n: Its *own*",
+			"<dl>\n<dt>Term</dt>\n<dd>Definition one</dd>\n<dd>Definition two</dd>\n<dt>Other</dt>\n<dd>Its <em>own</em></dd>\n</dl>\n"},
+		{"no term", "Not a term\n\n: lone colon", "<p>Not a term</p>\n<p>: lone colon</p>\n"},
+	} {
+		if got := renderString(tc.body); got != tc.want {
+			t.Errorf("%s: rendered\n%q\nwant\n%q", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestIncludeSections(t *testing.T) {
+	newTestWiki(t)
+	writePage(t, "Guide", "intro\n\n## Install\n\nrun it\n\n### Details\n\nmore\n\n## Use\n\nuse it")
+	for _, tc := range []struct {
+		name, body, want string
+	}{
+		{"existing section", "{{include:Guide#Install}}",
+			"<div class=\"include\" data-page=\"Guide\">\n" +
+				"<h2 id=\"inc1-install\">Install <a class=\"heading-link\" href=\"#inc1-install\" aria-label=\"Link to this section\">#</a></h2>\n" +
+				"<p>run it</p>\n" +
+				"<h3 id=\"inc1-details\">Details <a class=\"heading-link\" href=\"#inc1-details\" aria-label=\"Link to this section\">#</a></h3>\n" +
+				"<p>more</p>\n</div>\n"},
+		{"missing section", "{{include:Guide#Nope}}", "<p class=\"include-error\">cannot include Guide#Nope: section does not exist</p>\n"},
+		{"missing page", "{{include:Nothing#Install}}", "<p class=\"include-error\">cannot include Nothing: page does not exist</p>\n"},
+	} {
+		if got := renderString(tc.body); got != tc.want {
+			t.Errorf("%s: rendered\n%q\nwant\n%q", tc.name, got, tc.want)
+		}
+	}
+	if got := renderString("{{include:Guide#Use}}"); strings.Contains(got, "run it") || !strings.Contains(got, "use it") {
+		t.Errorf("the last section included as\n%s", got)
+	}
+}
+
+func TestMath(t *testing.T) {
+	newTestWiki(t, "math=true")
+	for _, tc := range []struct {
+		name, body, want string
+	}{
+		{"inline", "where $a_1 < b_2 * c$ holds", "<p>where <span class=\"math inline\">a_1 &lt; b_2 * c</span> holds</p>\n"},
+		{"inline display", "$$x^2 & <y>$$ shown", "<p><span class=\"math display\">x^2 &amp; &lt;y&gt;</span> shown</p>\n"},
+		{"display block", "$$\n\\sum_{i<n} a_i & b\n$$", "<div class=\"math display\">\\sum_{i&lt;n} a_i &amp; b</div>\n"},
+		{"markup around math", "**bold** $a*b*c$", "<p><strong>bold</strong> <span class=\"math inline\">a*b*c</span></p>\n"},
+		{"prices", "costs $5 and $10", "<p>costs $5 and $10</p>\n"},
+		{"spaced dollars", "$ not math $", "<p>$ not math $</p>\n"},
+		{"script", "$<script>alert(1)</script>$", "<p><span class=\"math inline\">&lt;script&gt;alert(1)&lt;/script&gt;</span></p>\n"},
+	} {
+		if got := renderString(tc.body); got != tc.want {
+			t.Errorf("%s: rendered\n%q\nwant\n%q", tc.name, got, tc.want)
+		}
+	}
+	setFlag(t, "math", "false")
+	if got := renderString("$a_b$"); strings.Contains(got, "math") {
+		t.Errorf("math rendered without -math: %s", got)
+	}
+}
+
+func TestHeadingLinks(t *testing.T) {
+	newTestWiki(t)
+	for _, tc := range []struct {
+		name, body, want string
+	}{
+		{"heading", "## Getting Started", "<h2 id=\"getting-started\">Getting Started <a class=\"heading-link\" href=\"#getting-started\" aria-label=\"Link to this section\">#</a></h2>\n"},
+		{"repeated heading", "# Notes\n# Notes", "<h1 id=\"notes-1\">Notes <a class=\"heading-link\" href=\"#notes-1\" aria-label=\"Link to this section\">#</a></h1>\n"},
+		{"markup in heading", "### A *big* <deal>", "<h3 id=\"a-big-deal\">A <em>big</em> &lt;deal&gt; <a class=\"heading-link\" href=\"#a-big-deal\" aria-label=\"Link to this section\">#</a></h3>\n"},
+	} {
+		if got := renderString(tc.body); !strings.Contains(got, tc.want) {
+			t.Errorf("%s: rendered\n%q\nwant it to contain\n%q", tc.name, got, tc.want)
+		}
+	}
+	setFlag(t, "no-heading-links", "true")
+	if got, want := renderString("## Getting Started"), "<h2 id=\"getting-started\">Getting Started</h2>\n"; got != want {
+		t.Errorf("with -no-heading-links rendered %q, want %q", got, want)
+	}
+}
+
+func TestCallouts(t *testing.T) {
+	newTestWiki(t)
+	for kind, label := range calloutTypes {
+		body := "> [!" + strings.ToUpper(kind) + "]\n> Mind the gap"
+		want := "<div class=\"callout callout-" + kind + "\" role=\"note\">\n<p class=\"callout-title\">" + label + "</p>\n<p>Mind the gap</p>\n</div>\n"
+		if got := renderString(body); got != want {
+			t.Errorf("%s: rendered\n%q\nwant\n%q", kind, got, want)
+		}
+	}
+	for _, tc := range []struct {
+		name, body, want string
+	}{
+		{"lower case marker", "> [!tip]\n> text", "<div class=\"callout callout-tip\" role=\"note\">\n<p class=\"callout-title\">Tip</p>\n<p>text</p>\n</div>\n"},
+		{"own title", "> [!WARNING] Hot *surface*\n> text", "<div class=\"callout callout-warning\" role=\"note\">\n<p class=\"callout-title\">Hot <em>surface</em></p>\n<p>text</p>\n</div>\n"},
+		{"unknown type", "> [!TODO]\n> text", "<div class=\"callout callout-generic\" role=\"note\">\n<p class=\"callout-title\">Todo</p>\n<p>text</p>\n</div>\n"},
+		{"plain blockquote", "> first\n> still first\n>\n> second", "<blockquote>\n<p>first\nstill first</p>\n<p>second</p>\n</blockquote>\n"},
+		{"marker not on the first line", "> text\n> [!NOTE]", "<blockquote>\n<p>text\n[!NOTE]</p>\n</blockquote>\n"},
+	} {
+		if got := renderString(tc.body); got != tc.want {
+			t.Errorf("%s: rendered\n%q\nwant\n%q", tc.name, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"time"
+)
+
+// replaceTimeout bounds how long a find and replace may spend matching
+// go's regexp package runs in linear time so no pattern can backtrack forever,
+// but a costly pattern over a large wiki can still take a long time
+var replaceTimeout = flag.Duration("replace-timeout", 10*time.Second, "maximum time /admin/replace may spend matching before giving up")
+
+// replacement is the outcome of a find and replace on a single page
+type replacement struct {
+	Title string `json:"title"`
+	Count int    `json:"count"`
+
+	body, updated []byte
+}
+
+// replaceReport is the JSON response of /admin/replace
+type replaceReport struct {
+	Applied      bool          `json:"applied"`
+	Pages        []replacement `json:"pages"`
+	Replacements int           `json:"replacements"`
+}
+
+// replacer substitutes every match in a body and reports how many there were
+type replacer func(body []byte) ([]byte, int)
+
+// newReplacer builds the replacer for find, which is a regular expression
+// when regex is true, in which case repl may refer to groups as $1 or ${name}
+func newReplacer(find, repl string, regex bool) (replacer, error) {
+	if find == "" {
+		return nil, fmt.Errorf("find must not be empty")
+	}
+	if !regex {
+		old, new := []byte(find), []byte(repl)
+		return func(body []byte) ([]byte, int) {
+			n := bytes.Count(body, old)
+			if n == 0 {
+				return body, 0
+			}
+			return bytes.Replace(body, old, new, -1), n
+		}, nil
+	}
+	re, err := regexp.Compile(find)
+	if err != nil {
+		return nil, err
+	}
+	return func(body []byte) ([]byte, int) {
+		n := len(re.FindAllIndex(body, -1))
+		if n == 0 {
+			return body, 0
+		}
+		return re.ReplaceAll(body, []byte(repl)), n
+	}, nil
+}
+
+// previewReplace runs fn over every page and returns the pages it would change,
+// giving up with an error once ctx is done
+func previewReplace(ctx context.Context, fn replacer) ([]replacement, error) {
+	titles, err := listPages()
+	if err != nil {
+		return nil, err
+	}
+	changes := []replacement{}
+	for _, title := range titles {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		p, err := loadPage(title)
+		if err != nil {
+			return nil, err
+		}
+		if updated, n := fn(p.Body); n > 0 {
+			changes = append(changes, replacement{Title: title, Count: n, body: p.Body, updated: updated})
+		}
+	}
+	return changes, nil
+}
+
+// applyReplace writes the change to a page under its lock, attributed to
+// editor with summary, redoing the substitution if the page was edited
+// since it was previewed
+func applyReplace(c *replacement, fn replacer, editor, summary string) error {
+	unlock := lockPage(c.Title)
+	defer unlock()
+	p, err := loadPage(c.Title)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(p.Body, c.body) {
+		c.updated, c.Count = fn(p.Body)
+		if c.Count == 0 {
+			return nil
+		}
+	}
+	p = newPage(c.Title, c.updated)
+	p.Summary, p.Editor = editSummary(summary), editor
+	return p.write()
+}
+
+// replaceHandler substitutes the form value replace for every occurrence of find
+// across all pages, treating find as a regular expression when regex=1
+// it only reports what would change unless apply=1 is given,
+// and nothing is written if matching does not finish within -replace-timeout
+func replaceHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		errorHandler(w, r, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	fn, err := newReplacer(r.FormValue("find"), r.FormValue("replace"), r.FormValue("regex") == "1")
+	if err != nil {
+		errorHandler(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), *replaceTimeout)
+	defer cancel()
+	type result struct {
+		changes []replacement
+		err     error
+	}
+	done := make(chan result, 1)
+	go func() {
+		changes, err := previewReplace(ctx, fn)
+		done <- result{changes, err}
+	}()
+	var res result
+	select {
+	case res = <-done:
+	case <-ctx.Done():
+		res.err = ctx.Err()
+	}
+	if res.err == context.DeadlineExceeded {
+		errorHandler(w, r, http.StatusServiceUnavailable, fmt.Sprintf("find and replace did not finish within %s, nothing was changed", *replaceTimeout))
+		return
+	}
+	if res.err != nil {
+		errorHandler(w, r, http.StatusInternalServerError, res.err.Error())
+		return
+	}
+
+	report := replaceReport{Applied: r.FormValue("apply") == "1", Pages: res.changes}
+	if report.Applied {
+		summary := fmt.Sprintf("replaced %q with %q", r.FormValue("find"), r.FormValue("replace"))
+		for i := range report.Pages {
+			c := &report.Pages[i]
+			if err := applyReplace(c, fn, editorName(r), summary); err != nil {
+				errorHandler(w, r, http.StatusInternalServerError, err.Error())
+				return
+			}
+			if c.Count > 0 {
+				recordAudit(r, auditReplace, c.Title)
+			}
+		}
+	}
+	for _, c := range report.Pages {
+		report.Replacements += c.Count
+	}
+	if report.Applied {
+		log.Printf("replaced %d occurrences across %d pages", report.Replacements, len(report.Pages))
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
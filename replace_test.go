@@ -0,0 +1,69 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"testing"
+)
+
+func TestReplaceAttributesEdits(t *testing.T) {
+	h := newTestWiki(t, "admin-user=admin", "admin-pass=pass")
+	writePage(t, "Notes", "colour and colour")
+
+	form := url.Values{"find": {"colour"}, "replace": {"color"}, "apply": {"1"}}
+	wantStatus(t, do(h, asAdmin(postForm("/admin/replace", form))), http.StatusOK)
+	if got := readPage(t, "Notes"); got != "color and color" {
+		t.Fatalf("body %q after replacing", got)
+	}
+	if got := readEditor("Notes"); got != "admin" {
+		t.Errorf("editor %q, want admin", got)
+	}
+	vs, _ := versions("Notes")
+	if v := vs[len(vs)-1]; v.Editor != "admin" || v.Summary != `replaced "colour" with "color"` {
+		t.Errorf("newest version by %q with summary %q", v.Editor, v.Summary)
+	}
+}
+
+func TestMergeAttributesRelinks(t *testing.T) {
+	h := newTestWiki(t, "admin-user=admin", "admin-pass=pass")
+	writePage(t, "Old", "old content")
+	writePage(t, "Links", "see [Old]")
+
+	form := url.Values{"source": {"Old"}, "target": {"New"}}
+	wantStatus(t, do(h, asAdmin(postForm("/admin/merge", form))), http.StatusOK)
+	if got := readPage(t, "Links"); got != "see [New]" {
+		t.Fatalf("body %q after relinking", got)
+	}
+	vs, _ := versions("Links")
+	if v := vs[len(vs)-1]; v.Editor != "admin" || v.Summary != "relinked Old to New" {
+		t.Errorf("newest version by %q with summary %q", v.Editor, v.Summary)
+	}
+}
+
+func TestMergeAppendsContent(t *testing.T) {
+	h := newTestWiki(t, "admin-user=admin", "admin-pass=pass")
+	writePage(t, "Old", "old content\n")
+	writePage(t, "New", "new content\n")
+	writePage(t, "Links", "see [Old#Part] and {{include:Old}}\n\n```\n[Old] stays in code\n```\n\nand `[Old]` too")
+
+	form := url.Values{"source": {"Old"}, "target": {"New"}, "redirect": {"1"}}
+	w := do(h, asAdmin(postForm("/admin/merge", form)))
+	wantStatus(t, w, http.StatusOK)
+	var report mergeReport
+	if err := json.NewDecoder(w.Body).Decode(&report); err != nil {
+		t.Fatal(err)
+	}
+	if report.Renamed || !report.Redirected || len(report.Relinked) != 1 {
+		t.Errorf("merge report %+v", report)
+	}
+	if got := readPage(t, "New"); got != "new content\n\n## Old\n\nold content\n" {
+		t.Errorf("merged body %q", got)
+	}
+	if got := readPage(t, "Old"); got != "#REDIRECT [New]\n" {
+		t.Errorf("source left as %q, want a redirect", got)
+	}
+	if got, want := readPage(t, "Links"), "see [New#Part] and {{include:New}}\n\n```\n[Old] stays in code\n```\n\nand `[Old]` too"; got != want {
+		t.Errorf("relinked body %q, want %q", got, want)
+	}
+}
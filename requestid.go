@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"flag"
+	"log"
+	"net/http"
+	"time"
+)
+
+// request tracing settings
+var (
+	requestIDHeader = flag.String("request-id-header", "X-Request-ID", "header carrying the request ID, taken from the request when present and set on every response")
+	accessLog       = flag.Bool("access-log", false, "log a line for every request served, including its request ID")
+)
+
+// maxRequestIDLen caps the length of request IDs accepted from clients
+const maxRequestIDLen = 128
+
+// requestIDKey is the context key under which the request ID is stored
+type requestIDKey struct{}
+
+// requestID returns the ID assigned to the request ctx belongs to, or ""
+func requestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// newRequestID generates a random request ID
+func newRequestID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// validRequestID reports whether an incoming request ID is safe to reuse,
+// which rules out anything that could forge lines in the logs
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLen {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] <= ' ' || id[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+// statusRecorder remembers the status code and size of a response
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+	size   int
+}
+
+func (s *statusRecorder) WriteHeader(code int) {
+	if s.status == 0 {
+		s.status = code
+	}
+	s.ResponseWriter.WriteHeader(code)
+}
+
+func (s *statusRecorder) Write(b []byte) (int, error) {
+	if s.status == 0 {
+		s.status = http.StatusOK
+	}
+	n, err := s.ResponseWriter.Write(b)
+	s.size += n
+	return n, err
+}
+
+// Unwrap gives http.ResponseController access to the underlying ResponseWriter
+func (s *statusRecorder) Unwrap() http.ResponseWriter {
+	return s.ResponseWriter
+}
+
+// withRequestID assigns each request an ID, reusing the one in
+// -request-id-header when the client or a proxy supplied one,
+// stores it in the request context, echoes it in the response
+// and writes the access log line when -access-log is set
+func withRequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(*requestIDHeader)
+		if !validRequestID(id) {
+			id = newRequestID()
+		}
+		w.Header().Set(*requestIDHeader, id)
+		r = r.WithContext(context.WithValue(r.Context(), requestIDKey{}, id))
+		if !*accessLog {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w}
+		next.ServeHTTP(rec, r)
+		if rec.status == 0 {
+			rec.status = http.StatusOK
+		}
+		log.Printf("%s %s %s %d %d %s id=%s", clientIP(r), r.Method, r.URL.RequestURI(), rec.status, rec.size, time.Since(start).Round(time.Microsecond), id)
+	})
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"html"
+	"html/template"
+	"regexp"
+	"strings"
+)
+
+// htmlPolicy selects how raw HTML written in page bodies is treated
+//
+//	strict:  no raw HTML at all, it is escaped and shown as text,
+//	         only markup produced by the renderer reaches the browser
+//	relaxed: raw HTML is passed through relaxedPolicy, which allows common
+//	         formatting and table elements plus embedded iframe, video and
+//	         audio players, meant for trusted single-user wikis
+//
+// under either policy scripts, styles, event handler attributes and
+// javascript: urls never make it into a rendered page
+var htmlPolicy = flag.String("html-policy", "strict", `how raw HTML in pages is treated: "strict" escapes it, "relaxed" allows a safe subset including iframes and video`)
+
+// sanitizePolicy maps each allowed HTML element to the attributes allowed on it,
+// elements that are not listed are dropped while their text content is kept
+type sanitizePolicy map[string][]string
+
+// relaxedPolicy is the allowlist used by -html-policy=relaxed
+var relaxedPolicy = sanitizePolicy{
+	"a": {"href"}, "abbr": nil, "b": nil, "blockquote": nil, "br": nil, "code": nil,
+	"dd": nil, "del": nil, "details": nil, "div": nil, "dl": nil, "dt": nil, "em": nil,
+	"figcaption": nil, "figure": nil, "h1": nil, "h2": nil, "h3": nil, "h4": nil, "h5": nil,
+	"h6": nil, "hr": nil, "i": nil, "ins": nil, "kbd": nil, "li": nil, "mark": nil, "ol": nil,
+	"p": nil, "pre": nil, "s": nil, "small": nil, "span": nil, "strong": nil, "sub": nil,
+	"summary": nil, "sup": nil, "table": nil, "tbody": nil, "thead": nil, "tr": nil, "u": nil, "ul": nil,
+
+	"td":     {"colspan", "rowspan"},
+	"th":     {"colspan", "rowspan"},
+	"img":    {"src", "alt", "width", "height"},
+	"iframe": {"src", "width", "height", "allow", "allowfullscreen", "frameborder"},
+	"video":  {"src", "width", "height", "controls", "poster", "loop", "muted"},
+	"audio":  {"src", "controls", "loop", "muted"},
+	"source": {"src", "type"},
+	"track":  {"src", "kind", "srclang", "label"},
+}
+
+// globalAttrs may appear on any allowed element
+var globalAttrs = []string{"title", "class", "lang", "dir"}
+
+// urlAttrs hold urls, which must pass safeURL to be kept
+var urlAttrs = map[string]bool{"href": true, "src": true, "poster": true}
+
+// voidElements never have a closing tag
+var voidElements = map[string]bool{"br": true, "hr": true, "img": true, "source": true, "track": true}
+
+// dropContent are elements removed together with everything inside them
+var dropContent = map[string]bool{"script": true, "style": true, "noscript": true, "template": true, "textarea": true, "title": true}
+
+// raw HTML tag syntax
+var (
+	tagPattern  = regexp.MustCompile(`^<(/?)([a-zA-Z][a-zA-Z0-9]*)((?:\s+[^\s"'>/=]+(?:\s*=\s*(?:"[^"]*"|'[^']*'|[^\s"'=<>` + "`" + `]+))?)*)\s*/?>`)
+	attrPattern = regexp.MustCompile(`([^\s"'>/=]+)(?:\s*=\s*(?:"([^"]*)"|'([^']*)'|([^\s"'=<>` + "`" + `]+)))?`)
+)
+
+// activePolicy returns the sanitizePolicy selected by -html-policy,
+// nil meaning raw HTML is not allowed at all
+func activePolicy() sanitizePolicy {
+	if *htmlPolicy == "relaxed" {
+		return relaxedPolicy
+	}
+	return nil
+}
+
+// checkHTMLPolicy validates the -html-policy flag
+func checkHTMLPolicy() error {
+	if *htmlPolicy != "strict" && *htmlPolicy != "relaxed" {
+		return fmt.Errorf("unknown html policy %q", *htmlPolicy)
+	}
+	return nil
+}
+
+// sanitizeHTML cleans a fragment of raw HTML according to pol,
+// escaping all text, dropping disallowed elements and attributes
+// and closing any allowed elements left open at the end of the fragment
+func sanitizeHTML(src string, pol sanitizePolicy) string {
+	var out strings.Builder
+	var open []string
+	for len(src) > 0 {
+		i := strings.IndexByte(src, '<')
+		if i < 0 {
+			out.WriteString(escapeText(src))
+			break
+		}
+		out.WriteString(escapeText(src[:i]))
+		src = src[i:]
+
+		if strings.HasPrefix(src, "<!--") {
+			end := strings.Index(src, "-->")
+			if end < 0 {
+				break
+			}
+			src = src[end+3:]
+			continue
+		}
+		m := tagPattern.FindStringSubmatch(src)
+		if m == nil {
+			out.WriteString("&lt;")
+			src = src[1:]
+			continue
+		}
+		src = src[len(m[0]):]
+		name := strings.ToLower(m[2])
+		if m[1] == "" && dropContent[name] {
+			end := strings.Index(strings.ToLower(src), "</"+name)
+			if end < 0 {
+				break
+			}
+			src = src[end:]
+			if close := strings.IndexByte(src, '>'); close >= 0 {
+				src = src[close+1:]
+			}
+			continue
+		}
+		tag, ok := sanitizeTag(m[0], pol)
+		if !ok {
+			continue
+		}
+		switch {
+		case m[1] == "/":
+			// only close elements that are actually open
+			for j := len(open) - 1; j >= 0; j-- {
+				if open[j] == name {
+					for _, n := range reverse(open[j:]) {
+						out.WriteString("</" + n + ">")
+					}
+					open = open[:j]
+					break
+				}
+			}
+		case voidElements[name]:
+			out.WriteString(tag)
+		default:
+			out.WriteString(tag)
+			open = append(open, name)
+		}
+	}
+	for _, n := range reverse(open) {
+		out.WriteString("</" + n + ">")
+	}
+	return out.String()
+}
+
+// sanitizeTag rebuilds a single opening or closing tag with only the
+// attributes pol allows, ok is false if the element is not allowed
+func sanitizeTag(raw string, pol sanitizePolicy) (string, bool) {
+	m := tagPattern.FindStringSubmatch(raw)
+	if m == nil || len(m[0]) != len(raw) {
+		return "", false
+	}
+	name := strings.ToLower(m[2])
+	allowed, ok := pol[name]
+	if !ok {
+		return "", false
+	}
+	if m[1] == "/" {
+		return "</" + name + ">", true
+	}
+
+	var b strings.Builder
+	b.WriteString("<" + name)
+	for _, a := range attrPattern.FindAllStringSubmatch(m[3], -1) {
+		attr := strings.ToLower(a[1])
+		if !contains(allowed, attr) && !contains(globalAttrs, attr) {
+			continue
+		}
+		value := html.UnescapeString(a[2] + a[3] + a[4])
+		if urlAttrs[attr] && !safeURL(strings.TrimSpace(value)) {
+			continue
+		}
+		b.WriteString(" " + attr + `="` + template.HTMLEscapeString(value) + `"`)
+	}
+	b.WriteString(">")
+	return b.String(), true
+}
+
+// escapeText escapes a run of text from raw HTML, keeping entities it already uses
+func escapeText(s string) string {
+	return template.HTMLEscapeString(html.UnescapeString(s))
+}
+
+// contains reports whether list holds s
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// reverse returns a reversed copy of names
+func reverse(names []string) []string {
+	r := make([]string, len(names))
+	for i, n := range names {
+		r[len(names)-1-i] = n
+	}
+	return r
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// hostile is page text trying to run script through raw HTML
+const hostile = `<script>alert(1)</script><img src="x.png" onerror="alert(2)"> <a href="javascript:alert(3)">click</a> <style>body{}</style>`
+
+func TestStrictPolicyEscapesHTML(t *testing.T) {
+	newTestWiki(t)
+	got := renderString("text " + hostile)
+	if strings.Contains(got, "<script") || strings.Contains(got, "<img") || strings.Contains(got, "<a href=\"javascript") {
+		t.Errorf("strict policy let raw HTML through:\n%s", got)
+	}
+	if !strings.Contains(got, "&lt;script&gt;alert(1)&lt;/script&gt;") {
+		t.Errorf("strict policy does not show raw HTML as text:\n%s", got)
+	}
+}
+
+func TestRelaxedPolicySanitizes(t *testing.T) {
+	newTestWiki(t, "html-policy=relaxed")
+	got := renderString(hostile + `<iframe src="https://video.example/embed" allowfullscreen></iframe>`)
+	for _, banned := range []string{"<script", "alert(1)", "onerror", "javascript:", "<style", "body{}"} {
+		if strings.Contains(got, banned) {
+			t.Errorf("relaxed policy kept %q:\n%s", banned, got)
+		}
+	}
+	for _, kept := range []string{`<img src="x.png">`, `<a>click</a>`, `<iframe src="https://video.example/embed" allowfullscreen="">`} {
+		if !strings.Contains(got, kept) {
+			t.Errorf("relaxed policy dropped %s:\n%s", kept, got)
+		}
+	}
+}
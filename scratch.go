@@ -0,0 +1,104 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"html/template"
+	"io/ioutil"
+	"net/http"
+	"strings"
+)
+
+// maxBodySize caps the size of a page body submitted for saving or rendering
+var maxBodySize = flag.Int64("max-body-size", 1<<20, "maximum size in bytes of a submitted page body")
+
+// bodyTooLarge is the error shown for bodies over -max-body-size
+func bodyTooLarge(w http.ResponseWriter, r *http.Request) {
+	errorHandler(w, r, http.StatusRequestEntityTooLarge, fmt.Sprintf("the page body must be at most %d bytes", *maxBodySize))
+}
+
+// parseBody parses r's form, reading no more than -max-body-size of its body
+// it returns false after writing an error response if that fails
+func parseBody(w http.ResponseWriter, r *http.Request) bool {
+	r.Body = http.MaxBytesReader(w, r.Body, *maxBodySize)
+	err := r.ParseForm()
+	var tooLarge *http.MaxBytesError
+	switch {
+	case errors.As(err, &tooLarge):
+		bodyTooLarge(w, r)
+	case err != nil:
+		errorHandler(w, r, http.StatusBadRequest, err.Error())
+	default:
+		return true
+	}
+	return false
+}
+
+// renderHandler renders a page body POSTed without saving it anywhere,
+// returning the HTML fragment a page with that body would show
+// the body is the form value body, or the whole request body when it is
+// sent as text/plain or text/markdown
+// only public pages can be included, as the fragment belongs to no page
+// given the page being edited as ?title= it previews the edit instead,
+// returning a JSON renderPreview with the diff against the saved page
+func renderHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		errorHandler(w, r, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	var raw string
+	if ct := r.Header.Get("Content-Type"); strings.HasPrefix(ct, "text/plain") || strings.HasPrefix(ct, "text/markdown") {
+		data, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, *maxBodySize))
+		if err != nil {
+			bodyTooLarge(w, r)
+			return
+		}
+		raw = string(data)
+	} else {
+		if !parseBody(w, r) {
+			return
+		}
+		raw = r.PostFormValue("body")
+	}
+	body, err := cleanBody(raw)
+	if err != nil {
+		errorHandler(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	p := newPage("", body)
+	w.Header().Set("Cache-Control", "no-store")
+	title := r.URL.Query().Get("title")
+	if title == "" {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write([]byte(renderBody("", p.Content)))
+		return
+	}
+	if err := validateTitle(title); err != nil {
+		errorHandler(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	var saved []byte
+	if old, err := loadPage(title); err == nil {
+		if denyView(w, r, old) {
+			return
+		}
+		saved = old.Body
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(renderPreview{
+		HTML: renderBody("", p.Content),
+		Diff: renderDiff(saved, body),
+		New:  saved == nil,
+	})
+}
+
+// renderPreview is the preview of an edit, the rendered body and its diff
+// against the saved page, which is all additions for a page that is New
+type renderPreview struct {
+	HTML template.HTML `json:"html"`
+	Diff template.HTML `json:"diff"`
+	New  bool          `json:"new"`
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// addr is where the server listens, either a TCP host:port
+// or a Unix domain socket given as "unix:/path/to.sock"
+var addr = flag.String("addr", ":8080", `address to listen on, host:port for TCP or "unix:/path/to.sock" for a Unix domain socket`)
+
+// h2c enables cleartext HTTP/2 alongside HTTP/1.1, for proxies that speak it
+var h2c = flag.Bool("h2c", false, "also accept cleartext HTTP/2 (h2c) connections")
+
+// limits on how clients send their request headers, which stop slowloris style
+// clients from holding connections open by trickling headers in byte by byte
+// a header taking longer than -read-header-timeout to arrive closes the
+// connection, and one over -max-header-bytes is refused with
+// 431 Request Header Fields Too Large
+// the defaults of 10 seconds and 64 KiB leave ample room for real browsers,
+// whose headers are rarely more than a few KiB even with cookies
+var (
+	readHeaderTimeout = flag.Duration("read-header-timeout", 10*time.Second, "how long a client may take to send its request headers")
+	maxHeaderBytes    = flag.Int("max-header-bytes", 64<<10, "maximum size in bytes of the request line and headers")
+)
+
+// socketMode is the permission of a Unix domain socket file,
+// letting a reverse proxy in the same group connect to it
+const socketMode = 0660
+
+// shutdownTimeout bounds how long in-flight requests get to finish on shutdown
+const shutdownTimeout = 10 * time.Second
+
+// listen opens the listener described by addr
+// a stale socket file left behind by an unclean exit is removed first,
+// the socket file is removed again when the listener is closed
+func listen(addr string) (net.Listener, error) {
+	path := strings.TrimPrefix(addr, "unix:")
+	if path == addr {
+		return net.Listen("tcp", addr)
+	}
+	if fi, err := os.Stat(path); err == nil && fi.Mode()&os.ModeSocket != 0 {
+		if err := os.Remove(path); err != nil {
+			return nil, err
+		}
+	}
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(path, socketMode); err != nil {
+		l.Close()
+		return nil, err
+	}
+	return l, nil
+}
+
+// serve runs srv on l until ctx is done,
+// then shuts it down gracefully, closing the listener
+// applying the header limits above
+func serve(ctx context.Context, srv *http.Server, l net.Listener) {
+	srv.ReadHeaderTimeout = *readHeaderTimeout
+	srv.MaxHeaderBytes = *maxHeaderBytes
+	if *h2c {
+		srv.Protocols = new(http.Protocols)
+		srv.Protocols.SetHTTP1(true)
+		srv.Protocols.SetUnencryptedHTTP2(true)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		<-ctx.Done()
+		log.Print("shutting down")
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		if err := srv.Shutdown(shutdownCtx); err != nil {
+			log.Printf("shutdown: %v", err)
+		}
+	}()
+
+	log.Printf("listening on %s", l.Addr())
+	if err := srv.Serve(l); err != http.ErrServerClosed {
+		log.Fatal(err)
+	}
+	<-done
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// startServer serves h through serve on a loopback port until the test ends
+// and returns the server's url
+func startServer(t *testing.T, h http.Handler) string {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+	go func() { serve(ctx, &http.Server{Handler: h}, l); close(stopped) }()
+	t.Cleanup(func() { cancel(); <-stopped })
+	return "http://" + l.Addr().String()
+}
+
+// h2cClient speaks only cleartext HTTP/2, with prior knowledge
+func h2cClient() *http.Client {
+	tr := &http.Transport{Protocols: new(http.Protocols)}
+	tr.Protocols.SetUnencryptedHTTP2(true)
+	return &http.Client{Transport: tr}
+}
+
+func TestH2C(t *testing.T) {
+	h := newTestWiki(t, "h2c=true")
+	writePage(t, "Notes", "over HTTP/2")
+	base := startServer(t, h)
+
+	c := h2cClient()
+	defer c.CloseIdleConnections()
+	resp, err := c.Get(base + "/view/Notes")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.ProtoMajor != 2 || resp.StatusCode != http.StatusOK || !strings.Contains(string(body), "over HTTP/2") {
+		t.Errorf("h2c request answered %s %s:\n%s", resp.Proto, resp.Status, body)
+	}
+
+	resp, err = http.Get(base + "/view/Notes")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.ProtoMajor != 1 || resp.StatusCode != http.StatusOK {
+		t.Errorf("HTTP/1.1 request alongside h2c answered %s %s", resp.Proto, resp.Status)
+	}
+}
+
+func TestNoH2C(t *testing.T) {
+	h := newTestWiki(t)
+	writePage(t, "Notes", "text")
+	base := startServer(t, h)
+	c := h2cClient()
+	defer c.CloseIdleConnections()
+	if resp, err := c.Get(base + "/view/Notes"); err == nil {
+		resp.Body.Close()
+		t.Errorf("h2c request without -h2c answered %s %s", resp.Proto, resp.Status)
+	}
+}
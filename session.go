@@ -0,0 +1,180 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"flag"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// settings for cookie based logins, which are off unless -session-secret is set
+// once they are on, pages can only be changed by logged in users
+var (
+	sessionSecret = flag.String("session-secret", "", "secret used to sign login session cookies, enables /login and requires logging in to edit (empty disables sessions)")
+	sessionTTL    = flag.Duration("session-ttl", 24*time.Hour, "how long a login session lasts")
+)
+
+// sessionCookie holds the signed session of a logged in user
+const sessionCookie = "session"
+
+// signSession returns the cookie value for a session of user lasting until expires,
+// "user|expiry|signature", so sessions need no server side state
+func signSession(user string, expires time.Time) string {
+	payload := base64.RawURLEncoding.EncodeToString([]byte(user)) + "|" + strconv.FormatInt(expires.Unix(), 10)
+	return payload + "|" + sessionSignature(payload)
+}
+
+// sessionSignature is the HMAC of payload under -session-secret
+func sessionSignature(payload string) string {
+	mac := hmac.New(sha256.New, []byte(*sessionSecret))
+	mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// sessionUser returns the user whose valid, unexpired session r carries, or ""
+func sessionUser(r *http.Request) string {
+	if *sessionSecret == "" {
+		return ""
+	}
+	c, err := r.Cookie(sessionCookie)
+	if err != nil {
+		return ""
+	}
+	parts := strings.Split(c.Value, "|")
+	if len(parts) != 3 || !hmac.Equal([]byte(parts[2]), []byte(sessionSignature(parts[0]+"|"+parts[1]))) {
+		return ""
+	}
+	expires, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil || time.Now().Unix() >= expires {
+		return ""
+	}
+	user, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return ""
+	}
+	return string(user)
+}
+
+// validLogin reports whether user and pass are the credentials of an account
+func validLogin(user, pass string) bool {
+	if *adminUser == "" || *adminPass == "" {
+		return false
+	}
+	userOK := subtle.ConstantTimeCompare([]byte(user), []byte(*adminUser)) == 1
+	passOK := subtle.ConstantTimeCompare([]byte(pass), []byte(*adminPass)) == 1
+	return userOK && passOK
+}
+
+// sameOrigin reports whether r, if it comes from a browser, was sent by one of
+// the wiki's own pages, since the session cookie is sent along with requests
+// other sites make too
+// SameSite=Lax on the cookie already stops most such requests,
+// this also covers older browsers and same-site hosts
+func sameOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		origin = r.Header.Get("Referer")
+	}
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	if base, err := url.Parse(absoluteURL(r, "/")); err == nil && u.Host == base.Host {
+		return true
+	}
+	return u.Host == r.Host
+}
+
+// localPath returns next if it is a path on this site, "/" otherwise,
+// so that logging in cannot be used to redirect somewhere else
+func localPath(next string) string {
+	if !strings.HasPrefix(next, "/") || strings.HasPrefix(next, "//") || strings.HasPrefix(next, "/\\") {
+		return "/"
+	}
+	return next
+}
+
+// loginPage is the data for the login template
+type loginPage struct {
+	Next  string
+	Error string
+}
+
+// loginHandler shows the login form and on POST checks the submitted
+// credentials, starting a session and going back to ?next= if they are valid
+func loginHandler(w http.ResponseWriter, r *http.Request) {
+	if *sessionSecret == "" {
+		notFound(w, r)
+		return
+	}
+	next := localPath(r.FormValue("next"))
+	if r.Method != http.MethodPost {
+		renderTemplate(w, r, "login", &loginPage{Next: next})
+		return
+	}
+	if !sameOrigin(r) {
+		errorHandler(w, r, http.StatusForbidden, "cross-site login refused")
+		return
+	}
+	user := r.FormValue("user")
+	if !validLogin(user, r.FormValue("pass")) {
+		renderTemplateStatus(w, r, "login", http.StatusUnauthorized, &loginPage{Next: next, Error: "wrong user name or password"})
+		return
+	}
+	expires := time.Now().Add(*sessionTTL)
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookie,
+		Value:    signSession(user, expires),
+		Path:     "/",
+		Expires:  expires,
+		HttpOnly: true,
+		Secure:   secureOrigin(r),
+		SameSite: http.SameSiteLaxMode,
+	})
+	http.Redirect(w, r, next, http.StatusFound)
+}
+
+// logoutHandler ends the session
+func logoutHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		errorHandler(w, r, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	http.SetCookie(w, &http.Cookie{Name: sessionCookie, Value: "", Path: "/", MaxAge: -1, HttpOnly: true, SameSite: http.SameSiteLaxMode})
+	http.Redirect(w, r, "/", http.StatusFound)
+}
+
+// requireSession only lets logged in users through to fn when sessions are enabled,
+// sending everyone else to the login page, and refuses cross-site changes
+// admins using Basic Auth get through as well
+func requireSession(fn http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if *sessionSecret == "" {
+			fn(w, r)
+			return
+		}
+		if sessionUser(r) == "" && !isAdmin(r) {
+			if r.Method == http.MethodGet || r.Method == http.MethodHead {
+				http.Redirect(w, r, "/login?next="+url.QueryEscape(r.URL.RequestURI()), http.StatusFound)
+				return
+			}
+			errorHandler(w, r, http.StatusUnauthorized, "you need to log in to do that")
+			return
+		}
+		if r.Method != http.MethodGet && r.Method != http.MethodHead && !sameOrigin(r) {
+			errorHandler(w, r, http.StatusForbidden, "cross-site request refused")
+			return
+		}
+		fn(w, r)
+	}
+}
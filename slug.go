@@ -0,0 +1,128 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// validSlug matches the slugs a page may give itself in its frontmatter:
+// lower case words of letters and digits joined by hyphens
+var validSlug = regexp.MustCompile("^[a-z0-9]+(?:-[a-z0-9]+)*$")
+
+// maxSlugLen caps the length of a slug
+const maxSlugLen = 100
+
+// validateSlug checks that slug can be the slug of the page title, being well
+// formed and neither the slug nor the title of any other page, and that a page
+// title that does not exist yet is not already another page's slug
+func validateSlug(title, slug string) error {
+	if other := slugTitle(title); other != "" && other != title && !pageExists(title) {
+		return fmt.Errorf("%s is already the slug of %s", title, other)
+	}
+	if slug == "" {
+		return nil
+	}
+	if len(slug) > maxSlugLen || !validSlug.MatchString(slug) {
+		return fmt.Errorf("slug %q must be at most %d lower case letters, digits and single hyphens", slug, maxSlugLen)
+	}
+	if other := slugTitle(slug); other != "" && other != title {
+		return fmt.Errorf("slug %q is already used by %s", slug, other)
+	}
+	if slug != title && pageExists(slug) {
+		return fmt.Errorf("slug %q is the title of another page", slug)
+	}
+	return nil
+}
+
+// titleInUse reports whether title is the title or the slug of a page
+func titleInUse(title string) bool {
+	return pageExists(title) || slugTitle(title) != ""
+}
+
+// slugify turns free text such as a page's display title into a slug,
+// e.g. "How Go works" becomes how-go-works, "" if it has no letters or digits
+// CamelCase titles are split into their words
+func slugify(s string) string {
+	var words []string
+	var word []rune
+	prev := rune(0)
+	for _, c := range s {
+		switch {
+		case c > unicode.MaxASCII || !unicode.IsLetter(c) && !unicode.IsDigit(c):
+			if len(word) > 0 {
+				words, word = append(words, string(word)), nil
+			}
+		case unicode.IsUpper(c) && unicode.IsLower(prev) && len(word) > 0:
+			words, word = append(words, string(word)), []rune{unicode.ToLower(c)}
+		default:
+			word = append(word, unicode.ToLower(c))
+		}
+		prev = c
+	}
+	if len(word) > 0 {
+		words = append(words, string(word))
+	}
+	slug := strings.Join(words, "-")
+	for len(slug) > maxSlugLen {
+		i := strings.LastIndex(slug, "-")
+		if i < 0 {
+			slug = slug[:maxSlugLen]
+			break
+		}
+		slug = slug[:i]
+	}
+	return slug
+}
+
+// slugPreview reports how a title typed by a user would be stored and served
+type slugPreview struct {
+	Input     string `json:"input"`
+	Title     string `json:"title"`
+	Valid     bool   `json:"valid"`
+	Error     string `json:"error,omitempty"`
+	Path      string `json:"path"`
+	File      string `json:"file"`
+	Exists    bool   `json:"exists"`
+	Slug      string `json:"slug"`
+	SlugTaken bool   `json:"slug_taken"`
+}
+
+// slugHandler serves /api/slug?title=, previewing the page a title would
+// become: the title itself if it is valid or else the CamelCase title made
+// of its words, or the page whose slug it is, the url and file that page is
+// served from and stored in and whether it exists, as well as the slug
+// suggested for it and whether that slug is already used by a page
+func slugHandler(w http.ResponseWriter, r *http.Request) {
+	input := r.FormValue("title")
+	if strings.TrimSpace(input) == "" {
+		errorHandler(w, r, http.StatusBadRequest, "title must not be empty")
+		return
+	}
+	sp := slugPreview{Input: input, Title: input, Valid: true}
+	if err := validateTitle(input); err != nil {
+		sp.Valid, sp.Error = false, err.Error()
+		sp.Title = titleFrom(input)
+	}
+	if t := slugTitle(sp.Title); t != "" && !pageExists(sp.Title) {
+		sp.Title = t
+	}
+	if sp.Title != "" {
+		sp.Path = "/view/" + sp.Title
+		sp.File = pageFile(sp.Title)
+		if p, err := loadPage(sp.Title); err == nil {
+			sp.Exists = true
+			if slug := p.Meta.Slug; slugTitle(slug) == sp.Title {
+				sp.Path = "/view/" + slug
+			}
+		}
+	}
+	if sp.Slug = slugify(input); sp.Slug != "" {
+		sp.SlugTaken = slugTitle(sp.Slug) != "" || pageExists(sp.Slug)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(sp)
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+)
+
+// slugged is the body of a page with the slug faq
+const slugged = "---\nslug: faq\n---\nQuestions and answers\n"
+
+func TestSlugResolution(t *testing.T) {
+	h := newTestWiki(t)
+	writePage(t, "GettingStarted", slugged)
+
+	w := do(h, get("/view/faq"))
+	wantStatus(t, w, http.StatusOK)
+	if !strings.Contains(w.Body.String(), "Questions and answers") {
+		t.Errorf("/view/faq does not show GettingStarted:\n%s", w.Body)
+	}
+
+	w = do(h, get("/view/GettingStarted?changes=1"))
+	wantStatus(t, w, http.StatusMovedPermanently)
+	if loc := w.Header().Get("Location"); loc != "/view/faq?changes=1" {
+		t.Errorf("title url redirects to %q, want /view/faq?changes=1", loc)
+	}
+}
+
+func TestSlugCollisions(t *testing.T) {
+	h := newTestWiki(t)
+	writePage(t, "GettingStarted", slugged)
+	writePage(t, "news", "Latest news")
+	writePage(t, "Plain", "A plain page")
+
+	for _, tc := range []struct {
+		name, title, body string
+		want              int
+	}{
+		{"slug taken by another page", "Other", slugged, http.StatusConflict},
+		{"slug is another page's title", "Other", "---\nslug: news\n---\nbody", http.StatusConflict},
+		{"new title is another page's slug", "faq", "body", http.StatusConflict},
+		{"page keeps its own slug", "GettingStarted", slugged + "more\n", http.StatusFound},
+		{"unused slug", "Other", "---\nslug: other-page\n---\nbody", http.StatusFound},
+	} {
+		w := do(h, postForm("/save/"+tc.title, url.Values{"body": {tc.body}}))
+		if w.Code != tc.want {
+			t.Errorf("%s: status %d, want %d", tc.name, w.Code, tc.want)
+		}
+	}
+	if pageExists("faq") {
+		t.Error("a page titled faq was created over GettingStarted's slug")
+	}
+
+	wantStatus(t, do(h, postForm("/copy/Plain", url.Values{"dest": {"faq"}})), http.StatusConflict)
+	wantStatus(t, do(h, postForm("/copy/GettingStarted", url.Values{"dest": {"Copy"}})), http.StatusConflict)
+	wantStatus(t, do(h, postForm("/copy/Plain", url.Values{"dest": {"Copy"}})), http.StatusFound)
+}
+
+func TestImportSlugCollisions(t *testing.T) {
+	remote := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, strings.TrimPrefix(r.URL.Path, "/"))
+	}))
+	defer remote.Close()
+	u, _ := url.Parse(remote.URL)
+	h := newTestWiki(t, "import-hosts="+u.Hostname())
+	writePage(t, "GettingStarted", slugged)
+
+	w := do(h, postForm("/import-url", url.Values{"url": {remote.URL + "/text"}, "title": {"faq"}}))
+	wantStatus(t, w, http.StatusConflict)
+	w = do(h, postForm("/import-url", url.Values{"url": {remote.URL + "/" + url.PathEscape(slugged)}, "title": {"Imported"}}))
+	wantStatus(t, w, http.StatusConflict)
+	if pageExists("faq") || pageExists("Imported") {
+		t.Error("an import collided with GettingStarted's slug")
+	}
+	w = do(h, postForm("/import-url", url.Values{"url": {remote.URL + "/text"}, "title": {"Imported"}}))
+	wantStatus(t, w, http.StatusFound)
+}
+
+// previewSlug asks /api/slug about title
+func previewSlug(t *testing.T, h http.Handler, title string) slugPreview {
+	t.Helper()
+	w := do(h, get("/api/slug?title="+url.QueryEscape(title)))
+	wantStatus(t, w, http.StatusOK)
+	var sp slugPreview
+	if err := json.NewDecoder(w.Body).Decode(&sp); err != nil {
+		t.Fatal(err)
+	}
+	return sp
+}
+
+func TestSlugPreviewMatchesStorage(t *testing.T) {
+	for _, flags := range [][]string{nil, {"shard-pages=true"}, {"compress-pages=true"}} {
+		h := newTestWiki(t, flags...)
+		for _, input := range []string{"Notes", "release notes, 2024"} {
+			before := previewSlug(t, h, input)
+			if before.Exists {
+				t.Fatalf("%v: %q previews as an existing page", flags, input)
+			}
+			wantStatus(t, do(h, postForm("/save/"+before.Title, url.Values{"body": {"text"}})), http.StatusFound)
+			if _, err := os.Stat(before.File); err != nil {
+				t.Errorf("%v: %q previewed file %s but saving did not create it: %v", flags, input, before.File, err)
+			}
+			wantStatus(t, do(h, get(before.Path)), http.StatusOK)
+			if after := previewSlug(t, h, input); !after.Exists || after.File != before.File || after.Path != before.Path {
+				t.Errorf("%v: %q previews as %+v once saved, %+v before", flags, input, after, before)
+			}
+		}
+	}
+}
+
+func TestSlugPreviewOfSluggedPages(t *testing.T) {
+	h := newTestWiki(t)
+	writePage(t, "GettingStarted", slugged)
+	for _, input := range []string{"GettingStarted", "faq"} {
+		sp := previewSlug(t, h, input)
+		if sp.Title != "GettingStarted" || !sp.Exists || sp.Path != "/view/faq" || sp.File != pageFile("GettingStarted") {
+			t.Errorf("%q previews as %+v", input, sp)
+		}
+		wantStatus(t, do(h, get(sp.Path)), http.StatusOK)
+	}
+	if sp := previewSlug(t, h, "Getting started"); sp.Valid || sp.Error == "" || sp.Title != "GettingStarted" || !sp.Exists || sp.Slug != "getting-started" || sp.SlugTaken {
+		t.Errorf("free text previews as %+v", sp)
+	}
+	if sp := previewSlug(t, h, "FAQ"); sp.Slug != "faq" || !sp.SlugTaken {
+		t.Errorf("the taken slug faq previews as %+v", sp)
+	}
+	wantStatus(t, do(h, get("/api/slug?title=+")), http.StatusBadRequest)
+}
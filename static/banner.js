@@ -0,0 +1,17 @@
+// hides the site banner once it has been dismissed, remembering that in a cookie
+// this runs right after the banner so a dismissed banner is never painted
+(function () {
+  var el = document.getElementById("banner");
+  if (!el) {
+    return;
+  }
+  var id = el.getAttribute("data-banner");
+  if (document.cookie.split("; ").indexOf("banner=" + id) >= 0) {
+    el.hidden = true;
+    return;
+  }
+  el.querySelector("button").addEventListener("click", function () {
+    document.cookie = "banner=" + id + "; path=/; max-age=31536000; SameSite=Lax";
+    el.hidden = true;
+  });
+})();
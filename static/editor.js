@@ -0,0 +1,23 @@
+// editor.js wires up the Markdown toolbar on the edit form
+// each toolbar button wraps the current selection (or inserts at the cursor)
+// with its data-before and data-after markers
+(function () {
+  document.querySelectorAll(".toolbar[data-editor]").forEach(function (toolbar) {
+    var textarea = document.getElementById(toolbar.dataset.editor);
+    if (!textarea) {
+      return;
+    }
+    toolbar.querySelectorAll("button[data-before]").forEach(function (button) {
+      button.addEventListener("click", function () {
+        var start = textarea.selectionStart;
+        var end = textarea.selectionEnd;
+        var before = button.dataset.before;
+        var after = button.dataset.after || "";
+        var text = textarea.value;
+        textarea.value = text.slice(0, start) + before + text.slice(start, end) + after + text.slice(end);
+        textarea.focus();
+        textarea.setSelectionRange(start + before.length, end + before.length);
+      });
+    });
+  });
+})();
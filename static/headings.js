@@ -0,0 +1,11 @@
+// copies the address of a heading's section when its "#" link is clicked,
+// still following the link so the address bar shows it too
+(function () {
+  document.querySelectorAll("a.heading-link").forEach(function (link) {
+    link.addEventListener("click", function () {
+      if (navigator.clipboard) {
+        navigator.clipboard.writeText(link.href).catch(function () {});
+      }
+    });
+  });
+})();
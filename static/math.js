@@ -0,0 +1,17 @@
+// typesets the math left in place by the renderer when -math is set,
+// using KaTeX from static/katex/
+document.addEventListener("DOMContentLoaded", function () {
+  if (!window.katex) {
+    return;
+  }
+  document.querySelectorAll(".math").forEach(function (el) {
+    try {
+      katex.render(el.textContent, el, {
+        displayMode: el.classList.contains("display"),
+        throwOnError: false
+      });
+    } catch (e) {
+      el.title = e.message;
+    }
+  });
+});
@@ -0,0 +1,33 @@
+// paste.js converts rich text pasted into the edit form to Markdown
+// when the clipboard holds HTML it is sent to the textarea's data-paste url
+// and the Markdown returned is inserted instead, falling back to the plain text
+(function () {
+  document.querySelectorAll("textarea[data-paste]").forEach(function (textarea) {
+    textarea.addEventListener("paste", function (event) {
+      var data = event.clipboardData;
+      if (!data || data.types.indexOf("text/html") < 0) {
+        return;
+      }
+      event.preventDefault();
+      var start = textarea.selectionStart;
+      var end = textarea.selectionEnd;
+      var insert = function (text) {
+        var value = textarea.value;
+        textarea.value = value.slice(0, start) + text + value.slice(end);
+        textarea.focus();
+        textarea.setSelectionRange(start + text.length, start + text.length);
+      };
+      var plain = data.getData("text/plain");
+      fetch(textarea.dataset.paste, {
+        method: "POST",
+        headers: { "Content-Type": "text/html; charset=utf-8" },
+        body: data.getData("text/html"),
+        credentials: "same-origin"
+      }).then(function (resp) {
+        return resp.ok ? resp.text() : plain;
+      }).then(insert, function () {
+        insert(plain);
+      });
+    });
+  });
+})();
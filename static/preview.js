@@ -0,0 +1,32 @@
+// preview.js shows the edit form's body rendered next to its diff against
+// the saved page when the Preview button is pressed, without saving anything
+(function () {
+  document.querySelectorAll("button[data-preview]").forEach(function (button) {
+    var textarea = document.getElementById(button.dataset.editor);
+    var pane = document.getElementById(button.dataset.pane);
+    if (!textarea || !pane) {
+      return;
+    }
+    button.addEventListener("click", function () {
+      var form = new URLSearchParams();
+      form.set("body", textarea.value);
+      fetch(button.dataset.preview, { method: "POST", body: form, credentials: "same-origin" })
+        .then(function (resp) {
+          if (!resp.ok) {
+            throw new Error(resp.statusText);
+          }
+          return resp.json();
+        })
+        .then(function (preview) {
+          pane.querySelector(".preview-body").innerHTML = preview.html;
+          pane.querySelector(".preview-diff").innerHTML = preview.diff;
+          pane.hidden = false;
+        })
+        .catch(function (err) {
+          pane.querySelector(".preview-body").textContent = "Preview failed: " + err.message;
+          pane.querySelector(".preview-diff").textContent = "";
+          pane.hidden = false;
+        });
+    });
+  });
+})();
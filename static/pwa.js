@@ -0,0 +1,4 @@
+// registers the offline service worker, included when the wiki runs with -pwa
+if ("serviceWorker" in navigator) {
+  navigator.serviceWorker.register("/sw.js");
+}
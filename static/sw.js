@@ -0,0 +1,43 @@
+// offline support for the wiki, installed when it runs with -pwa
+// static files are served cache-first, pages network-first falling back
+// to the copy cached when they were last viewed
+var CACHE = "gowiki-v1";
+
+self.addEventListener("install", function () {
+  self.skipWaiting();
+});
+
+self.addEventListener("activate", function (event) {
+  event.waitUntil(caches.keys().then(function (names) {
+    return Promise.all(names.filter(function (n) { return n !== CACHE; }).map(function (n) { return caches.delete(n); }));
+  }).then(function () { return self.clients.claim(); }));
+});
+
+function store(request, response) {
+  if (response.ok) {
+    var copy = response.clone();
+    caches.open(CACHE).then(function (cache) { cache.put(request, copy); });
+  }
+  return response;
+}
+
+self.addEventListener("fetch", function (event) {
+  var request = event.request;
+  var url = new URL(request.url);
+  if (request.method !== "GET" || url.origin !== self.location.origin) {
+    return;
+  }
+  if (url.pathname.indexOf("/static/") === 0) {
+    event.respondWith(caches.match(request).then(function (cached) {
+      return cached || fetch(request).then(function (response) { return store(request, response); });
+    }));
+  } else if (url.pathname === "/" || url.pathname.indexOf("/view/") === 0) {
+    event.respondWith(fetch(request).then(function (response) {
+      return store(request, response);
+    }).catch(function () {
+      return caches.match(request).then(function (cached) {
+        return cached || new Response("This page has not been saved for offline reading.", {status: 503, headers: {"Content-Type": "text/plain; charset=utf-8"}});
+      });
+    }));
+  }
+});
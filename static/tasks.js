@@ -0,0 +1,27 @@
+// saves the checking and unchecking of task list items on the page being viewed
+// a change that cannot be saved is undone so the page never shows unsaved state
+(function () {
+  var page = document.currentScript.getAttribute("data-page");
+  document.querySelectorAll("input[data-task]").forEach(function (box) {
+    box.addEventListener("change", function () {
+      var form = new URLSearchParams();
+      form.set("task", box.getAttribute("data-task"));
+      form.set("text", box.getAttribute("data-text"));
+      form.set("done", box.checked ? "1" : "0");
+      box.disabled = true;
+      fetch("/task/" + page, { method: "POST", body: form, credentials: "same-origin" })
+        .then(function (resp) {
+          if (!resp.ok) {
+            return resp.text().then(function (msg) { throw new Error(msg); });
+          }
+        })
+        .catch(function (err) {
+          box.checked = !box.checked;
+          alert("Could not save the task: " + err.message);
+        })
+        .finally(function () {
+          box.disabled = false;
+        });
+    });
+  });
+})();
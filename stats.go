@@ -0,0 +1,131 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// statsJSON enables /stats.json, a summary of the wiki for dashboards
+var statsJSON = flag.Bool("stats", false, "serve a JSON summary of the wiki's size and activity at /stats.json")
+
+// stats feed settings
+const (
+	statsTTL        = 30 * time.Second
+	statsMostViewed = 10
+)
+
+// wikiStats is the document served at /stats.json
+// MostViewed is left out unless page views are counted, and only lists public
+// pages as the same document is served to every client
+type wikiStats struct {
+	Generated  time.Time   `json:"generated"`
+	Pages      int         `json:"pages"`
+	Bytes      int64       `json:"bytes"`
+	Edits24h   int         `json:"edits_24h"`
+	Edits7d    int         `json:"edits_7d"`
+	MostViewed []pageViews `json:"most_viewed,omitempty"`
+}
+
+// statsCache holds the last /stats.json document, reused for statsTTL
+var statsCache = struct {
+	sync.Mutex
+	doc  []byte
+	when time.Time
+}{}
+
+// recentEdits counts the edits made within a day and a week of now,
+// from the audit log or, when there is none, from the pages' modification
+// times which only count the latest edit of each page
+// pages is the list of titles to fall back on
+func recentEdits(now time.Time, pages []string) (day, week int, err error) {
+	dayAgo, weekAgo := now.Add(-24*time.Hour), now.Add(-7*24*time.Hour)
+	count := func(t time.Time) {
+		if t.After(weekAgo) {
+			week++
+			if t.After(dayAgo) {
+				day++
+			}
+		}
+	}
+
+	auditMu.Lock()
+	defer auditMu.Unlock()
+	f, err := os.Open(*auditLogPath)
+	if os.IsNotExist(err) {
+		for _, title := range pages {
+			if fi, err := os.Stat(pageFile(title)); err == nil {
+				count(fi.ModTime())
+			}
+		}
+		return day, week, nil
+	}
+	if err != nil {
+		return 0, 0, err
+	}
+	defer f.Close()
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		var e auditEntry
+		if json.Unmarshal(s.Bytes(), &e) == nil {
+			count(e.Time)
+		}
+	}
+	return day, week, s.Err()
+}
+
+// collectStats gathers the current statistics of the wiki
+func collectStats(now time.Time) (*wikiStats, error) {
+	titles, err := listPages()
+	if err != nil {
+		return nil, err
+	}
+	st := &wikiStats{Generated: now.UTC(), Pages: len(titles)}
+	for _, title := range titles {
+		if fi, err := os.Stat(pageFile(title)); err == nil {
+			st.Bytes += fi.Size()
+		}
+	}
+	if st.Edits24h, st.Edits7d, err = recentEdits(now, titles); err != nil {
+		return nil, err
+	}
+	if *countViews {
+		for _, pv := range popularPages() {
+			if len(st.MostViewed) == statsMostViewed {
+				break
+			}
+			if p, err := loadPage(pv.Title); err == nil && p.Meta.public() {
+				st.MostViewed = append(st.MostViewed, pv)
+			}
+		}
+	}
+	return st, nil
+}
+
+// statsHandler serves the wiki's statistics as JSON, recomputed at most
+// every statsTTL however often it is polled
+func statsHandler(w http.ResponseWriter, r *http.Request) {
+	statsCache.Lock()
+	defer statsCache.Unlock()
+	now := time.Now()
+	if statsCache.doc == nil || now.Sub(statsCache.when) >= statsTTL {
+		st, err := collectStats(now)
+		if err != nil {
+			errorHandler(w, r, http.StatusInternalServerError, err.Error())
+			return
+		}
+		doc, err := json.Marshal(st)
+		if err != nil {
+			errorHandler(w, r, http.StatusInternalServerError, err.Error())
+			return
+		}
+		statsCache.doc, statsCache.when = append(doc, '\n'), now
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", cacheControl(statsTTL-now.Sub(statsCache.when)))
+	w.Write(statsCache.doc)
+}
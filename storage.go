@@ -0,0 +1,172 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// compressPages stores page files gzip compressed as data/{Page.Title}.txt.gz
+// pages are read in either form whatever the setting, and are converted
+// to the configured form the next time they are saved
+var compressPages = flag.Bool("compress-pages", false, "store pages gzip compressed as .txt.gz files")
+
+// shardPages stores pages in subdirectories of data/ named after the first
+// letter of their title, for filesystems that slow down with many files in
+// one directory, -migrate-pages moves existing pages into the selected layout
+var (
+	shardPages   = flag.Bool("shard-pages", false, "store pages as data/{first letter}/{Page.Title}.txt rather than all in data/")
+	migratePages = flag.Bool("migrate-pages", false, "move page files stored in the other layout into the one selected by -shard-pages at startup")
+)
+
+// page file extensions
+const (
+	plainExt      = ".txt"
+	compressedExt = ".txt.gz"
+)
+
+// shardDir returns the directory the pages starting with the same letter as
+// title are kept in under -shard-pages, the letter lower cased so that
+// shards do not clash on case-insensitive filesystems
+func shardDir(title string) string {
+	return filepath.Join("data", strings.ToLower(title[:1]))
+}
+
+// pageDir returns the directory holding the files of title
+func pageDir(title string) string {
+	if *shardPages {
+		return shardDir(title)
+	}
+	return "data"
+}
+
+// pageFile returns the name of the file holding title,
+// or the name it would be saved under if there is none
+func pageFile(title string) string {
+	base := filepath.Join(pageDir(title), title)
+	preferred, other := base+plainExt, base+compressedExt
+	if *compressPages {
+		preferred, other = other, preferred
+	}
+	if _, err := os.Stat(preferred); err != nil {
+		if _, err := os.Stat(other); err == nil {
+			return other
+		}
+	}
+	return preferred
+}
+
+// readPageFile reads a page file, decompressing it if it is gzipped
+func readPageFile(filename string) ([]byte, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil || !strings.HasSuffix(filename, ".gz") {
+		return data, err
+	}
+	zr, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+	return ioutil.ReadAll(zr)
+}
+
+// writePageFile stores body as title in the form selected by -compress-pages,
+// removing the file of the other form if the page was stored that way before
+func writePageFile(title string, body []byte) error {
+	base := filepath.Join(pageDir(title), title)
+	filename, stale := base+plainExt, base+compressedExt
+	if *shardPages {
+		if err := os.MkdirAll(pageDir(title), 0755); err != nil {
+			return err
+		}
+	}
+	data := body
+	if *compressPages {
+		filename, stale = stale, filename
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		zw.Write(body)
+		if err := zw.Close(); err != nil {
+			return err
+		}
+		data = buf.Bytes()
+	}
+	if err := ioutil.WriteFile(filename, data, 0600); err != nil {
+		return err
+	}
+	if err := os.Remove(stale); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// pageTitle returns the title stored in the page file named name,
+// ok is false for files that are not page files
+func pageTitle(name string) (string, bool) {
+	for _, ext := range []string{compressedExt, plainExt} {
+		if strings.HasSuffix(name, ext) {
+			title := strings.TrimSuffix(name, ext)
+			return title, validTitle.MatchString(title)
+		}
+	}
+	return "", false
+}
+
+// pageFilePattern matches the page files of the layout selected by -shard-pages
+func pageFilePattern() string {
+	if *shardPages {
+		return "data/?/*" + plainExt + "*"
+	}
+	return "data/*" + plainExt + "*"
+}
+
+// migratePageFiles moves the files of pages stored in the layout that
+// -shard-pages did not select, page and editor files alike, into the one it did
+// it returns the number of pages moved and stops at a page found in both layouts
+// shards left empty by moving pages back to data/ are removed
+func migratePageFiles() (int, error) {
+	pattern := "data/*"
+	if !*shardPages {
+		pattern = "data/?/*"
+	}
+	files, err := filepath.Glob(pattern)
+	if err != nil {
+		return 0, err
+	}
+	moved := 0
+	for _, f := range files {
+		name := filepath.Base(f)
+		title, ok := pageTitle(name)
+		if !ok {
+			if title = strings.TrimSuffix(name, ".editor"); title == name || !validTitle.MatchString(title) {
+				continue
+			}
+		}
+		to := filepath.Join(pageDir(title), name)
+		if to == f {
+			continue
+		}
+		if _, err := os.Stat(to); err == nil {
+			return moved, fmt.Errorf("%s is stored as both %s and %s", title, f, to)
+		}
+		if err := os.MkdirAll(pageDir(title), 0755); err != nil {
+			return moved, err
+		}
+		if err := os.Rename(f, to); err != nil {
+			return moved, err
+		}
+		if ok {
+			moved++
+		}
+		if !*shardPages {
+			// leaves shards that still hold other files alone
+			os.Remove(filepath.Dir(f))
+		}
+	}
+	return moved, nil
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// wantFile fails the test unless name exists
+func wantFile(t *testing.T, name string) {
+	t.Helper()
+	if _, err := os.Stat(name); err != nil {
+		t.Errorf("%s: %v", name, err)
+	}
+}
+
+func TestShardedPageFiles(t *testing.T) {
+	h := newTestWiki(t, "shard-pages=true")
+	for _, tc := range []struct{ title, file string }{
+		{"Apple", "data/a/Apple.txt"},
+		{"apricot", "data/a/apricot.txt"},
+		{"Zebra", "data/z/Zebra.txt"},
+		{"9Lives", "data/9/9Lives.txt"},
+	} {
+		if got := pageFile(tc.title); got != filepath.FromSlash(tc.file) {
+			t.Errorf("pageFile(%q) = %q, want %q", tc.title, got, tc.file)
+		}
+		wantStatus(t, do(h, postForm("/save/"+tc.title, url.Values{"body": {"text"}, "editor": {"ann"}})), http.StatusFound)
+		wantFile(t, tc.file)
+	}
+	wantFile(t, "data/a/Apple.editor")
+	if _, err := os.Stat("data/Apple.txt"); err == nil {
+		t.Error("a sharded page was saved in data/")
+	}
+
+	titles, err := listPages()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(titles, ","); got != "9Lives,Apple,Zebra,apricot" {
+		t.Errorf("listPages() = %s", got)
+	}
+	w := do(h, get("/view/apricot"))
+	wantStatus(t, w, http.StatusOK)
+}
+
+func TestMigratePageFiles(t *testing.T) {
+	h := newTestWiki(t)
+	wantStatus(t, do(h, postForm("/save/Apple", url.Values{"body": {"flat apple"}, "editor": {"ann"}})), http.StatusFound)
+	writePage(t, "Banana", "flat banana")
+
+	setFlag(t, "shard-pages", "true")
+	if titles, _ := listPages(); len(titles) != 0 {
+		t.Errorf("sharded layout lists the flat pages %v before migrating", titles)
+	}
+	if n, err := migratePageFiles(); n != 2 || err != nil {
+		t.Fatalf("migrating to shards moved %d pages: %v", n, err)
+	}
+	wantFile(t, "data/a/Apple.txt")
+	wantFile(t, "data/a/Apple.editor")
+	wantFile(t, "data/b/Banana.txt")
+	if got := readPage(t, "Apple"); got != "flat apple" {
+		t.Errorf("migrated body %q", got)
+	}
+	if n, err := migratePageFiles(); n != 0 || err != nil {
+		t.Errorf("migrating again moved %d pages: %v", n, err)
+	}
+
+	setFlag(t, "shard-pages", "false")
+	if n, err := migratePageFiles(); n != 2 || err != nil {
+		t.Fatalf("migrating back to flat moved %d pages: %v", n, err)
+	}
+	wantFile(t, "data/Apple.txt")
+	wantFile(t, "data/Apple.editor")
+	wantFile(t, "data/Banana.txt")
+	if _, err := os.Stat("data/a"); !os.IsNotExist(err) {
+		t.Errorf("the emptied shard data/a was left behind: %v", err)
+	}
+}
+
+func TestMigratePageFilesConflict(t *testing.T) {
+	newTestWiki(t)
+	writePage(t, "Apple", "flat apple")
+	setFlag(t, "shard-pages", "true")
+	writePage(t, "Apple", "sharded apple")
+	if _, err := migratePageFiles(); err == nil {
+		t.Error("a page stored in both layouts migrated without an error")
+	}
+	if got := readPage(t, "Apple"); got != "sharded apple" {
+		t.Errorf("the conflicting migration changed the page to %q", got)
+	}
+}
+
+func TestCompressedPages(t *testing.T) {
+	newTestWiki(t)
+	writePage(t, "Plain", "stored plain")
+	setFlag(t, "compress-pages", "true")
+	writePage(t, "Packed", "stored compressed")
+
+	wantFile(t, "data/Plain.txt")
+	wantFile(t, "data/Packed.txt.gz")
+	if _, err := os.Stat("data/Packed.txt"); err == nil {
+		t.Error("a compressed page was also stored plain")
+	}
+	raw, err := os.ReadFile("data/Packed.txt.gz")
+	if err != nil || !strings.HasPrefix(string(raw), "\x1f\x8b") {
+		t.Errorf("data/Packed.txt.gz is not gzipped: %q, %v", raw, err)
+	}
+
+	for _, compress := range []string{"true", "false"} {
+		setFlag(t, "compress-pages", compress)
+		if got, got2 := readPage(t, "Plain"), readPage(t, "Packed"); got != "stored plain" || got2 != "stored compressed" {
+			t.Errorf("-compress-pages=%s reads %q and %q", compress, got, got2)
+		}
+		titles, err := listPages()
+		if err != nil || strings.Join(titles, ",") != "Packed,Plain" {
+			t.Errorf("-compress-pages=%s lists %v, %v", compress, titles, err)
+		}
+	}
+
+	// saving converts a page to the configured form
+	writePage(t, "Packed", "now plain")
+	wantFile(t, "data/Packed.txt")
+	if _, err := os.Stat("data/Packed.txt.gz"); !os.IsNotExist(err) {
+		t.Errorf("the compressed file was left behind: %v", err)
+	}
+	if got := readPage(t, "Packed"); got != "now plain" {
+		t.Errorf("body %q after saving plain", got)
+	}
+}
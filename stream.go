@@ -0,0 +1,68 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"net/http"
+)
+
+// streamThreshold is the size above which a rendered template is streamed to
+// the client as it is rendered rather than buffered whole, which keeps large
+// pages such as books from being held in memory before the first byte is sent
+var streamThreshold = flag.Int("stream-threshold", 256<<10, "size in bytes beyond which rendered pages are streamed instead of buffered (0 = always buffer)")
+
+// streamFlushSize is how much streamed output is written between flushes
+const streamFlushSize = 32 << 10
+
+// streamWriter buffers a response until it grows past -stream-threshold,
+// then sends the status and what it has so far and streams the rest
+// until then nothing has been sent, so the response can still be replaced
+// by an error page
+type streamWriter struct {
+	w         http.ResponseWriter
+	status    int
+	buf       bytes.Buffer
+	streaming bool
+	unflushed int
+}
+
+// newStreamWriter returns a streamWriter answering w with status
+func newStreamWriter(w http.ResponseWriter, status int) *streamWriter {
+	return &streamWriter{w: w, status: status}
+}
+
+func (sw *streamWriter) Write(p []byte) (int, error) {
+	if sw.streaming {
+		n, err := sw.w.Write(p)
+		if sw.unflushed += n; sw.unflushed >= streamFlushSize {
+			sw.flush()
+		}
+		return n, err
+	}
+	sw.buf.Write(p)
+	if *streamThreshold <= 0 || sw.buf.Len() < *streamThreshold {
+		return len(p), nil
+	}
+	sw.streaming = true
+	sw.w.WriteHeader(sw.status)
+	if _, err := sw.buf.WriteTo(sw.w); err != nil {
+		return 0, err
+	}
+	sw.flush()
+	return len(p), nil
+}
+
+// flush pushes streamed output to the client
+func (sw *streamWriter) flush() {
+	sw.unflushed = 0
+	http.NewResponseController(sw.w).Flush()
+}
+
+// close sends a response that was never large enough to be streamed
+func (sw *streamWriter) close() {
+	if sw.streaming {
+		return
+	}
+	sw.w.WriteHeader(sw.status)
+	sw.buf.WriteTo(sw.w)
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// benchmarkView views a page of about a megabyte with -stream-threshold
+// set to threshold
+func benchmarkView(b *testing.B, threshold string) {
+	h := newTestWiki(b, "stream-threshold="+threshold)
+	var body strings.Builder
+	for i := 0; body.Len() < 1<<20; i++ {
+		body.WriteString("## Section " + strconv.Itoa(i) + "\n\nSome *text* with a [link](https://example.com/) and `code`.\n\n")
+	}
+	writePage(b, "Big", body.String())
+	b.ReportAllocs()
+	for b.Loop() {
+		if w := do(h, get("/view/Big")); w.Code != http.StatusOK {
+			b.Fatalf("status %d", w.Code)
+		}
+	}
+}
+
+func BenchmarkViewStreamed(b *testing.B) { benchmarkView(b, "65536") }
+
+func BenchmarkViewBuffered(b *testing.B) { benchmarkView(b, "0") }
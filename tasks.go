@@ -0,0 +1,169 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"html/template"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// taskItem matches a task list item, "- [ ] to do" or "- [x] done"
+var taskItem = regexp.MustCompile(`^\s*[-*] \[([ xX])\] (.*)$`)
+
+// taskPath matches /task/{Page.Title}
+var taskPath = regexp.MustCompile("^/task/([a-zA-Z0-9]+)$")
+
+// errTaskMoved is returned when a task cannot be found again in the page
+var errTaskMoved = errors.New("the task list has changed, reload the page and try again")
+
+// task renders the checkbox of the next task list item, whose source is text
+// items of the page being viewed carry their position among its tasks and
+// their text for tasks.js to toggle them, those of included pages are read only
+func (rd *renderer) task(done bool, text string) string {
+	checked := ""
+	if done {
+		checked = " checked"
+	}
+	if len(rd.stack) > 1 {
+		return `<input type="checkbox" disabled` + checked + `>`
+	}
+	n := rd.tasks
+	rd.tasks++
+	return `<input type="checkbox" data-task="` + strconv.Itoa(n) + `" data-text="` + template.HTMLEscapeString(strings.TrimSpace(text)) + `"` + checked + `>`
+}
+
+// taskLines returns the indexes in bodyLines(content) of its task list items,
+// numbered the same way the renderer numbers their checkboxes
+func taskLines(content []byte) []int {
+	var tasks []int
+	inMath, inFence := false, false
+	for i, line := range bodyLines(content) {
+		if !inMath && isFence(line) {
+			inFence = !inFence
+			continue
+		}
+		if inFence {
+			continue
+		}
+		if *enableMath && strings.TrimSpace(line) == mathDelim {
+			inMath = !inMath
+			continue
+		}
+		if !inMath && taskItem.MatchString(line) {
+			tasks = append(tasks, i)
+		}
+	}
+	return tasks
+}
+
+// setTask checks or unchecks task n of body, whose text must be text
+// if the page was edited since it was rendered and task n is no longer that item,
+// the single item with that text is updated instead
+// changed is false if the task already had the requested state
+func setTask(body []byte, n int, text string, done bool) (updated []byte, changed bool, err error) {
+	_, content, _ := parseFrontmatter(body)
+	if !bytes.HasSuffix(body, content) {
+		return nil, false, errTaskMoved
+	}
+	lines := bodyLines(content)
+	tasks := taskLines(content)
+	match := func(i int) bool { return strings.TrimSpace(taskItem.FindStringSubmatch(lines[i])[2]) == text }
+
+	line := -1
+	if n >= 0 && n < len(tasks) && match(tasks[n]) {
+		line = tasks[n]
+	} else {
+		for _, i := range tasks {
+			if match(i) {
+				if line >= 0 {
+					return nil, false, errTaskMoved
+				}
+				line = i
+			}
+		}
+	}
+	if line < 0 {
+		return nil, false, errTaskMoved
+	}
+
+	m := taskItem.FindStringSubmatchIndex(lines[line])
+	mark := " "
+	if done {
+		mark = "x"
+	}
+	if (lines[line][m[2]:m[3]] != " ") == done {
+		return body, false, nil
+	}
+	lines[line] = lines[line][:m[2]] + mark + lines[line][m[3]:]
+	head := body[:len(body)-len(content)]
+	return append(append([]byte(nil), head...), strings.Join(lines, "\n")...), true, nil
+}
+
+// taskHandler checks or unchecks a task list item of a page, POSTed by tasks.js
+// as the form values task, its position among the page's tasks, text, its source
+// text, and done=1 or done=0, answering 204 No Content on success
+// the item is re-found under the page's lock so that a concurrent edit
+// is neither lost nor has the wrong item toggled, 409 Conflict is returned
+// when that is not possible
+func taskHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		errorHandler(w, r, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	m := taskPath.FindStringSubmatch(r.URL.Path)
+	if m == nil {
+		notFound(w, r)
+		return
+	}
+	n, err := strconv.Atoi(r.FormValue("task"))
+	if err != nil {
+		errorHandler(w, r, http.StatusBadRequest, "task must be a number")
+		return
+	}
+	if overQuota(w, r) {
+		return
+	}
+	title, text, done := m[1], strings.TrimSpace(r.FormValue("text")), r.FormValue("done") == "1"
+
+	unlock := lockPage(title)
+	defer unlock()
+	p, err := loadPage(title)
+	if err != nil {
+		notFound(w, r)
+		return
+	}
+	if denyView(w, r, p) {
+		return
+	}
+	body, changed, err := setTask(p.Body, n, text, done)
+	if err != nil {
+		errorHandler(w, r, http.StatusConflict, err.Error())
+		return
+	}
+	if changed {
+		p = newPage(title, body)
+		p.Editor = editorName(r)
+		p.Summary = "unchecked " + text
+		if done {
+			p.Summary = "checked " + text
+		}
+		p.Summary = editSummary(p.Summary)
+		if err := p.write(); err != nil {
+			errorHandler(w, r, http.StatusInternalServerError, err.Error())
+			return
+		}
+		recordEdit(r, time.Now())
+		recordAudit(r, auditEdit, title)
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// hasTasks reports whether the rendered page html contains toggleable tasks
+func hasTasks(html template.HTML) bool {
+	return strings.Contains(string(html), ` data-task="`)
+}
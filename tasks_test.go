@@ -0,0 +1,49 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// taskBody is a page with two tasks spread among other lines
+const taskBody = "# Chores\n\n- [ ] wash up\n- plain item\n- [x] sweep\n\n```\n- [ ] not a task\n```\n\ntrailing text"
+
+func TestTaskToggle(t *testing.T) {
+	h := newTestWiki(t)
+	writePage(t, "Chores", taskBody)
+
+	w := do(h, postForm("/task/Chores", url.Values{"task": {"1"}, "text": {"sweep"}, "done": {"0"}}))
+	wantStatus(t, w, http.StatusNoContent)
+	want := strings.Replace(taskBody, "- [x] sweep", "- [ ] sweep", 1)
+	if got := readPage(t, "Chores"); got != want {
+		t.Errorf("after unchecking sweep the page is\n%q\nwant\n%q", got, want)
+	}
+
+	w = do(h, postForm("/task/Chores", url.Values{"task": {"0"}, "text": {"wash up"}, "done": {"1"}}))
+	wantStatus(t, w, http.StatusNoContent)
+	want = strings.Replace(want, "- [ ] wash up", "- [x] wash up", 1)
+	if got := readPage(t, "Chores"); got != want {
+		t.Errorf("after checking wash up the page is\n%q\nwant\n%q", got, want)
+	}
+}
+
+func TestTaskToggleMovedTask(t *testing.T) {
+	h := newTestWiki(t)
+	writePage(t, "Chores", taskBody)
+
+	// a stale position still finds the task by its text
+	w := do(h, postForm("/task/Chores", url.Values{"task": {"0"}, "text": {"sweep"}, "done": {"0"}}))
+	wantStatus(t, w, http.StatusNoContent)
+	want := strings.Replace(taskBody, "- [x] sweep", "- [ ] sweep", 1)
+	if got := readPage(t, "Chores"); got != want {
+		t.Errorf("page is\n%q\nwant\n%q", got, want)
+	}
+
+	w = do(h, postForm("/task/Chores", url.Values{"task": {"0"}, "text": {"mop"}, "done": {"1"}}))
+	wantStatus(t, w, http.StatusConflict)
+	if got := readPage(t, "Chores"); got != want {
+		t.Errorf("a conflicting toggle changed the page to\n%q", got)
+	}
+}
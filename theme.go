@@ -0,0 +1,126 @@
+package main
+
+import (
+	"flag"
+	"html/template"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+)
+
+// defaultTheme is the theme used when a request does not pick a known one
+var defaultTheme = flag.String("theme", "classic", "default template theme, one of the subdirectories of tmpl/")
+
+// themeCookie remembers the theme a user picked with ?theme=
+const themeCookie = "theme"
+
+// templates pre-loads the html templates of every theme at startup,
+// keyed by theme name and then template file name,
+// where each subdirectory of tmpl/ is one theme
+// this will panic if an error occurs and will exit the program
+var templates = loadThemes("tmpl")
+
+// templateFuncs are the helper functions available to every template
+//
+//	static        fingerprinted url of a file in static/, see staticURL
+//	bundle        fingerprinted url of a bundle of static files, see bundleURL
+//	pwa           whether -pwa is set
+//	themeColor    the -theme-color of the web app manifest
+//	banner        the site banner, see currentBanner
+//	formatDate    a time as text, see formatDate
+//	relativeTime  how long ago a time was, see relativeTime
+//	formatNumber  a number with grouped thousands, see formatNumber
+//	truncate      text cut to a length, see truncate
+//	urlFor        a wiki path under -base-url, see urlFor
+var templateFuncs = template.FuncMap{
+	"static":       staticURL,
+	"bundle":       bundleURL,
+	"pwa":          func() bool { return *enablePWA },
+	"themeColor":   func() string { return *appThemeColor },
+	"banner":       currentBanner,
+	"formatDate":   formatDate,
+	"relativeTime": relativeTime,
+	"formatNumber": formatNumber,
+	"truncate":     truncate,
+	"urlFor":       urlFor,
+}
+
+// layoutFile holds a theme's shared page layout and partials
+// a page template defining "content" is rendered through the "layout" template,
+// any other page template is a complete document on its own
+const layoutFile = "layout.html"
+
+// loadThemes parses every subdirectory of dir as a theme's template set,
+// each page template being parsed together with the theme's layout
+func loadThemes(dir string) map[string]map[string]*template.Template {
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		panic(err)
+	}
+	themes := map[string]map[string]*template.Template{}
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		base := template.New(layoutFile).Funcs(templateFuncs)
+		if _, err := os.Stat(filepath.Join(dir, e.Name(), layoutFile)); err == nil {
+			template.Must(base.ParseFiles(filepath.Join(dir, e.Name(), layoutFile)))
+		}
+		pages, err := filepath.Glob(filepath.Join(dir, e.Name(), "*.html"))
+		if err != nil {
+			panic(err)
+		}
+		set := map[string]*template.Template{}
+		for _, page := range pages {
+			name := filepath.Base(page)
+			if name == layoutFile {
+				continue
+			}
+			t := template.Must(template.Must(base.Clone()).ParseFiles(page))
+			if t.Lookup("content") != nil && t.Lookup("layout") != nil {
+				set[name] = t.Lookup("layout")
+			} else {
+				set[name] = t.Lookup(name)
+			}
+		}
+		themes[e.Name()] = set
+	}
+	return themes
+}
+
+// requestTheme picks the theme for r from its ?theme= parameter or theme cookie,
+// falling back to -theme for unknown themes
+// a valid ?theme= parameter is remembered in the theme cookie
+func requestTheme(w http.ResponseWriter, r *http.Request) string {
+	if name := r.URL.Query().Get("theme"); name != "" {
+		if _, ok := templates[name]; ok {
+			http.SetCookie(w, &http.Cookie{Name: themeCookie, Value: name, Path: "/", HttpOnly: true, SameSite: http.SameSiteLaxMode})
+		}
+	}
+	return pickedTheme(r)
+}
+
+// pickedTheme is the theme requestTheme picks for r, without remembering it
+func pickedTheme(r *http.Request) string {
+	if name := r.URL.Query().Get("theme"); name != "" {
+		if _, ok := templates[name]; ok {
+			return name
+		}
+	}
+	if c, err := r.Cookie(themeCookie); err == nil {
+		if _, ok := templates[c.Value]; ok {
+			return c.Value
+		}
+	}
+	return *defaultTheme
+}
+
+// themeTemplate looks up the template named name in theme,
+// themes that do not provide a template borrow it from the default theme
+func themeTemplate(theme, name string) *template.Template {
+	if t := templates[theme][name]; t != nil {
+		return t
+	}
+	return templates[*defaultTheme][name]
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestThemeLayouts(t *testing.T) {
+	h := newTestWiki(t, "pwa=true", "session-secret=secret", "admin-user=admin", "admin-pass=pass")
+	writePage(t, "Notes", "text")
+	wantStatus(t, do(h, asAdmin(postForm("/admin/banner", url.Values{"text": {"down at noon"}}))), http.StatusOK)
+	t.Cleanup(func() { banner.admin = "" })
+
+	for _, theme := range []string{"classic", "modern"} {
+		for _, target := range []string{"/view/Notes", "/edit/Notes", "/history/Notes", "/recent", "/prefix/", "/login", "/view/no.page"} {
+			r := asUser(htmlRequest(target), "alice")
+			r.AddCookie(&http.Cookie{Name: themeCookie, Value: theme})
+			body := do(h, r).Body.String()
+			if !strings.HasPrefix(body, "<!DOCTYPE html>") || strings.Count(body, "<title>") != 1 {
+				t.Errorf("%s %s is not a complete document:\n%s", theme, target, body)
+			}
+			if strings.Count(body, `id="banner"`) != 1 || strings.Count(body, `rel="manifest"`) != 1 {
+				t.Errorf("%s %s does not show the layout's chrome once:\n%s", theme, target, body)
+			}
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"flag"
+	"html/template"
+	"strings"
+	"time"
+)
+
+// todayPlaceholder names the directive that a page, typically FrontPage,
+// puts on a line of its own to show the pages modified today, {{today}}
+// by default
+var todayPlaceholder = flag.String("today-placeholder", "today", "name of the {{name}} line replaced by a list of the pages modified today (empty disables it)")
+
+// isToday reports whether line is the {{today}} directive
+func isToday(line string) bool {
+	return *todayPlaceholder != "" && strings.TrimSpace(line) == "{{"+*todayPlaceholder+"}}"
+}
+
+// hasToday reports whether content uses the {{today}} directive, making its
+// rendering change from one moment to the next without the page changing
+func hasToday(content []byte) bool {
+	for _, line := range proseLines(content) {
+		if isToday(line) {
+			return true
+		}
+	}
+	return false
+}
+
+// today renders the pages modified since midnight, newest first
+// only pages that the page being rendered could include are listed,
+// so that the list shows no page its readers could not see
+func (rd *renderer) today() {
+	now := time.Now()
+	y, m, d := now.Date()
+	changes, err := recentChanges(time.Date(y, m, d, 0, 0, 0, 0, now.Location()))
+	if err != nil {
+		rd.out.WriteString(`<p class="include-error">cannot list the pages modified today</p>` + "\n")
+		return
+	}
+	var outer pageMeta
+	if root, err := loadPage(rd.stack[0]); err == nil {
+		outer = root.Meta
+	}
+	var items []string
+	for i := len(changes) - 1; i >= 0; i-- {
+		c := changes[i]
+		p, err := loadPage(c.Title)
+		if err != nil || !mayInclude(&outer, &p.Meta) {
+			continue
+		}
+		item := `<li><a href="/view/` + c.Title + `">` + template.HTMLEscapeString(p.DisplayTitle()) + `</a> <span class="today-time">` + c.Modified.Local().Format("15:04") + "</span>"
+		if c.Editor != "" {
+			item += " by " + template.HTMLEscapeString(c.Editor)
+		}
+		items = append(items, item+"</li>\n")
+	}
+	if len(items) == 0 {
+		rd.out.WriteString(`<p class="today">nothing today</p>` + "\n")
+		return
+	}
+	rd.out.WriteString(`<ul class="today">` + "\n" + strings.Join(items, "") + "</ul>\n")
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"time"
+)
+
+// history retention, applied by /admin/vacuum and every -vacuum-interval
+// the newest version of a page is always kept whatever its age
+var (
+	historyKeep    = flag.Int("history-keep", 0, "number of versions of each page kept when vacuuming history (0 = keep all)")
+	historyMaxAge  = flag.Duration("history-max-age", 0, "age beyond which versions are pruned when vacuuming history (0 = keep them forever)")
+	vacuumInterval = flag.Duration("vacuum-interval", 0, "how often to vacuum history automatically (0 = only on demand at /admin/vacuum)")
+)
+
+// vacuumedPage is the outcome of vacuuming the history of one page
+type vacuumedPage struct {
+	Title  string `json:"title"`
+	Pruned int    `json:"pruned"`
+	Freed  int64  `json:"freed"`
+}
+
+// vacuumReport is the outcome of vacuuming all of history,
+// listing the pages that had versions pruned
+type vacuumReport struct {
+	Pages  []vacuumedPage `json:"pages"`
+	Pruned int            `json:"pruned"`
+	Freed  int64          `json:"freed"`
+}
+
+// retentionSet reports whether any history retention is configured
+func retentionSet() bool {
+	return *historyKeep > 0 || *historyMaxAge > 0
+}
+
+// vacuumHistory prunes the versions of every page history, deleted pages
+// included, that fall outside the retention flags at now
+func vacuumHistory(now time.Time) (vacuumReport, error) {
+	report := vacuumReport{Pages: []vacuumedPage{}}
+	if !retentionSet() {
+		return report, nil
+	}
+	dirs, err := ioutil.ReadDir(historyDir)
+	if os.IsNotExist(err) {
+		return report, nil
+	}
+	if err != nil {
+		return report, err
+	}
+	for _, d := range dirs {
+		if !d.IsDir() || !validTitle.MatchString(d.Name()) {
+			continue
+		}
+		v, err := vacuumPage(d.Name(), now)
+		if err != nil {
+			return report, err
+		}
+		if v.Pruned > 0 {
+			report.Pages = append(report.Pages, v)
+			report.Pruned += v.Pruned
+			report.Freed += v.Freed
+		}
+	}
+	return report, nil
+}
+
+// vacuumPage prunes the history of title, holding its lock so that
+// a version saved meanwhile is never counted against the retention
+func vacuumPage(title string, now time.Time) (vacuumedPage, error) {
+	v := vacuumedPage{Title: title}
+	unlock := lockPage(title)
+	defer unlock()
+	vs, err := versions(title)
+	if err != nil || len(vs) == 0 {
+		return v, err
+	}
+	for i, ver := range vs[:len(vs)-1] {
+		tooMany := *historyKeep > 0 && i < len(vs)-*historyKeep
+		tooOld := *historyMaxAge > 0 && now.Sub(ver.Time) > *historyMaxAge
+		if !tooMany && !tooOld {
+			continue
+		}
+		for _, path := range []string{versionPath(title, ver.ID), summaryPath(title, ver.ID), versionEditorPath(title, ver.ID)} {
+			fi, err := os.Stat(path)
+			if os.IsNotExist(err) {
+				continue
+			}
+			if err == nil {
+				err = os.Remove(path)
+			}
+			if err != nil {
+				return v, err
+			}
+			v.Freed += fi.Size()
+		}
+		v.Pruned++
+	}
+	return v, nil
+}
+
+// runVacuumer vacuums history every -vacuum-interval until ctx is done
+// it returns immediately when there is no interval or retention to apply
+func runVacuumer(ctx context.Context) {
+	if *vacuumInterval <= 0 || !retentionSet() {
+		return
+	}
+	ticker := time.NewTicker(*vacuumInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if isReadOnly() {
+			continue
+		}
+		report, err := vacuumHistory(time.Now())
+		if err != nil {
+			log.Printf("vacuum: %v", err)
+		} else if report.Pruned > 0 {
+			log.Printf("vacuum: pruned %d versions, freeing %d bytes", report.Pruned, report.Freed)
+		}
+	}
+}
+
+// vacuumHandler prunes history by the retention flags on a POST,
+// responding with a JSON vacuumReport
+func vacuumHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		errorHandler(w, r, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	if !retentionSet() {
+		errorHandler(w, r, http.StatusConflict, "no history retention is configured, set -history-keep or -history-max-age")
+		return
+	}
+	report, err := vacuumHistory(time.Now())
+	if err != nil {
+		errorHandler(w, r, http.StatusInternalServerError, err.Error())
+		return
+	}
+	log.Printf("vacuum: pruned %d versions, freeing %d bytes", report.Pruned, report.Freed)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// settings for page view counting, which is off unless -count-views is set
+var (
+	countViews = flag.Bool("count-views", false, "count page views and show them on each page and in /admin/popular")
+	viewsFile  = flag.String("views-file", "views.json", "file where page view counts are persisted")
+)
+
+// viewsFlushInterval is how often changed view counts are written to disk,
+// batching up the writes caused by a burst of views
+const viewsFlushInterval = 30 * time.Second
+
+// viewCounts holds the number of views of each page, keyed by title
+// dirty is set when the counts have changed since they were last written
+var viewCounts = struct {
+	sync.Mutex
+	counts map[string]int
+	dirty  bool
+}{counts: map[string]int{}}
+
+// loadViews reads the persisted view counts, a missing file means no views yet
+func loadViews() error {
+	data, err := ioutil.ReadFile(*viewsFile)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	viewCounts.Lock()
+	defer viewCounts.Unlock()
+	return json.Unmarshal(data, &viewCounts.counts)
+}
+
+// flushViews writes the view counts to disk if they have changed,
+// going through a temporary file so a crash never leaves a torn file behind
+func flushViews() error {
+	viewCounts.Lock()
+	if !viewCounts.dirty {
+		viewCounts.Unlock()
+		return nil
+	}
+	data, err := json.Marshal(viewCounts.counts)
+	viewCounts.dirty = false
+	viewCounts.Unlock()
+	if err != nil {
+		return err
+	}
+	tmp := *viewsFile + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, *viewsFile)
+}
+
+// runViewFlusher periodically persists view counts until ctx is done,
+// flushing one last time on the way out
+func runViewFlusher(ctx context.Context) {
+	if !*countViews {
+		return
+	}
+	ticker := time.NewTicker(viewsFlushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			if err := flushViews(); err != nil {
+				log.Printf("views: %v", err)
+			}
+			return
+		case <-ticker.C:
+			if err := flushViews(); err != nil {
+				log.Printf("views: %v", err)
+			}
+		}
+	}
+}
+
+// recordView counts a view of title by r and returns the updated count
+// views from bots and prefetches are not counted
+func recordView(r *http.Request, title string) int {
+	viewCounts.Lock()
+	defer viewCounts.Unlock()
+	if !isAutomated(r) {
+		viewCounts.counts[title]++
+		viewCounts.dirty = true
+	}
+	return viewCounts.counts[title]
+}
+
+// isAutomated guesses whether r was made by a crawler or a speculative prefetch
+// rather than by someone actually reading the page
+func isAutomated(r *http.Request) bool {
+	for _, h := range []string{"Purpose", "Sec-Purpose", "X-Purpose", "X-Moz"} {
+		if v := strings.ToLower(r.Header.Get(h)); strings.Contains(v, "prefetch") || strings.Contains(v, "preview") {
+			return true
+		}
+	}
+	ua := strings.ToLower(r.UserAgent())
+	for _, s := range []string{"bot", "crawler", "spider", "slurp"} {
+		if strings.Contains(ua, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// pageViews is the number of times a page has been viewed
+type pageViews struct {
+	Title string `json:"title"`
+	Views int    `json:"views"`
+}
+
+// popularPages lists the viewed pages, most viewed first
+func popularPages() []pageViews {
+	viewCounts.Lock()
+	popular := make([]pageViews, 0, len(viewCounts.counts))
+	for title, n := range viewCounts.counts {
+		popular = append(popular, pageViews{Title: title, Views: n})
+	}
+	viewCounts.Unlock()
+	sort.Slice(popular, func(i, j int) bool {
+		if popular[i].Views != popular[j].Views {
+			return popular[i].Views > popular[j].Views
+		}
+		return popular[i].Title < popular[j].Title
+	})
+	return popular
+}
+
+// popularHandler reports pages by view count, most viewed first, as JSON
+func popularHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(popularPages())
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+)
+
+// webhookURL receives a notification for every page change, which works with
+// Slack and Discord incoming webhooks as well as generic JSON endpoints
+var webhookURL = flag.String("webhook-url", "", "URL that is POSTed a JSON notification whenever a page changes (empty disables notifications)")
+
+// webhook delivery settings
+const (
+	webhookQueue    = 100
+	webhookAttempts = 3
+	webhookTimeout  = 5 * time.Second
+)
+
+// webhookEvent is the JSON payload posted for a page change
+// Text and Content carry a readable summary for Slack and Discord respectively
+type webhookEvent struct {
+	Action  string `json:"action"`
+	Title   string `json:"title"`
+	Who     string `json:"who"`
+	Link    string `json:"link"`
+	Text    string `json:"text"`
+	Content string `json:"content"`
+}
+
+// webhookDrainTimeout bounds the final attempt at the notifications
+// still queued on shutdown, all of them together
+var webhookDrainTimeout = shutdownTimeout
+
+// webhookEvents queues notifications for runNotifier so that
+// a slow webhook never holds up the request that made the change
+var webhookEvents = make(chan webhookEvent, webhookQueue)
+
+// webhookClient posts notifications
+var webhookClient = &http.Client{Timeout: webhookTimeout}
+
+// notifyChange queues a notification of action on title by the client behind r,
+// it is dropped if the queue is full
+func notifyChange(r *http.Request, action, title string) {
+	if *webhookURL == "" {
+		return
+	}
+	ev := webhookEvent{Action: action, Title: title, Who: clientID(r), Link: absoluteURL(r, "/view/"+title)}
+	ev.Text = fmt.Sprintf("%s: %s by %s <%s>", action, title, ev.Who, ev.Link)
+	ev.Content = ev.Text
+	select {
+	case webhookEvents <- ev:
+	default:
+		log.Printf("webhook: queue full, dropped %s of %s", action, title)
+	}
+}
+
+// runNotifier delivers queued notifications until ctx is done, then makes
+// a single attempt at whatever is still queued until webhookDrainTimeout
+// has passed, dropping the rest
+// a delivery cut short by ctx is queued again for that last attempt
+func runNotifier(ctx context.Context) {
+	if *webhookURL == "" {
+		return
+	}
+	for {
+		select {
+		case ev := <-webhookEvents:
+			if !deliver(ctx, ev, webhookAttempts) {
+				select {
+				case webhookEvents <- ev:
+				default:
+				}
+			}
+		case <-ctx.Done():
+			drain, cancel := context.WithTimeout(context.Background(), webhookDrainTimeout)
+			defer cancel()
+			for {
+				select {
+				case ev := <-webhookEvents:
+					if drain.Err() != nil {
+						log.Printf("webhook: shutting down, dropped %d notifications", len(webhookEvents)+1)
+						return
+					}
+					deliver(drain, ev, 1)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+// deliver posts ev to -webhook-url, retrying with a growing delay
+// and giving up with a log message after attempts failures
+// it reports false if ctx was done before ev was delivered or given up on
+func deliver(ctx context.Context, ev webhookEvent, attempts int) bool {
+	payload, err := json.Marshal(ev)
+	if err != nil {
+		log.Printf("webhook: %v", err)
+		return true
+	}
+	for i := 1; ; i++ {
+		err = post(ctx, payload)
+		if err == nil {
+			return true
+		}
+		if ctx.Err() != nil {
+			return false
+		}
+		if i == attempts {
+			break
+		}
+		select {
+		case <-time.After(time.Duration(i) * time.Second):
+		case <-ctx.Done():
+			return false
+		}
+	}
+	log.Printf("webhook: giving up on %s of %s: %v", ev.Action, ev.Title, err)
+	return true
+}
+
+// post makes a single delivery attempt, abandoned once ctx is done
+func post(ctx context.Context, payload []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, *webhookURL, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := webhookClient.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// emptyWebhookQueue drops the notifications left queued when the test ends
+func emptyWebhookQueue(t *testing.T) {
+	t.Cleanup(func() {
+		for {
+			select {
+			case <-webhookEvents:
+			default:
+				return
+			}
+		}
+	})
+}
+
+func TestNotifierDelivers(t *testing.T) {
+	got := make(chan webhookEvent, 1)
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var ev webhookEvent
+		json.NewDecoder(r.Body).Decode(&ev)
+		got <- ev
+	}))
+	defer hook.Close()
+	newTestWiki(t, "webhook-url="+hook.URL)
+	emptyWebhookQueue(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+	go func() { runNotifier(ctx); close(stopped) }()
+	defer func() { cancel(); <-stopped }()
+
+	notifyChange(get("/save/Notes"), auditEdit, "Notes")
+	select {
+	case ev := <-got:
+		if ev.Action != auditEdit || ev.Title != "Notes" || ev.Who != "192.0.2.1" {
+			t.Errorf("delivered %+v", ev)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("the notification was not delivered")
+	}
+}
+
+func TestNotifierShutdownDeadline(t *testing.T) {
+	release := make(chan struct{})
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer hook.Close()
+	defer close(release)
+	newTestWiki(t, "webhook-url="+hook.URL)
+	emptyWebhookQueue(t)
+	old := webhookDrainTimeout
+	webhookDrainTimeout = 100 * time.Millisecond
+	defer func() { webhookDrainTimeout = old }()
+
+	for i := 0; i < 10; i++ {
+		notifyChange(get("/save/Notes"), auditEdit, "Notes")
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	start := time.Now()
+	runNotifier(ctx)
+	if d := time.Since(start); d > time.Second {
+		t.Errorf("draining 10 notifications to a stuck webhook took %s", d)
+	}
+}